@@ -0,0 +1,166 @@
+package main
+
+import (
+	"golang/cache"
+	"golang/config"
+	"golang/controllers"
+	"golang/dao"
+	"golang/health"
+	"golang/idempotency"
+	"golang/initializers"
+	"golang/middleware"
+	"golang/payments"
+	"golang/replicas"
+	"golang/services"
+	"golang/vault"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// app holds every dependency of the server. Everything is built once in
+// newApp and handed to the parts that need it; nothing is looked up globally.
+type app struct {
+	cfg *config.Config
+	db  *gorm.DB
+
+	userDao *dao.UserDao
+	noteDao *dao.NoteDao
+	cardDao *dao.CardDao
+
+	cardVault *vault.Vault
+	cache     cache.Cache
+	userCache *services.UserCache
+	reminder  *services.CardExpiryReminder
+	imports   *services.ImportJobs
+	health    *health.Checker
+
+	auth        *middleware.Authenticator
+	idempotency idempotency.Store
+
+	users    *controllers.UserController
+	notes    *controllers.NoteController
+	cards    *controllers.CardController
+	vault    *controllers.VaultController
+	payments *controllers.PaymentController
+	importer *controllers.ImportController
+	exports  *controllers.ExportController
+	privacy  *controllers.PrivacyController
+	login    *controllers.AuthController
+	caches   *controllers.CacheController
+	probes   *controllers.HealthController
+}
+
+func newApp(cfg *config.Config, db *gorm.DB, replicaRouter *replicas.Router) *app {
+	a := &app{
+		cfg:     cfg,
+		db:      db,
+		userDao: dao.NewUserDao(db),
+		noteDao: dao.NewNoteDao(db),
+		cardDao: dao.NewCardDao(db),
+	}
+	a.cardVault = vault.NewVault(dao.NewVaultDao(db))
+	if a.cache = initializers.InitializeCache(cfg.Cache); a.cache != nil {
+		a.userCache = services.NewUserCache(a.cache, cfg.Cache.TTL)
+	}
+	replicaRouter.Writes = a.cache
+	a.caches = controllers.NewCacheController(a.userCache)
+	a.health = initializers.InitializeHealth(db, replicaRouter, a.cache)
+	a.probes = controllers.NewHealthController(a.health)
+
+	userService := services.NewUserService(a.userDao, a.cardVault)
+	userService.Transactions = services.NewTransactionManager(db)
+	userService.Cache = a.userCache
+	a.users = controllers.NewUserController(userService)
+	a.users.RequireIfMatch = cfg.Server.RequireIfMatch
+	noteService := services.NewNoteService(a.noteDao)
+	noteService.Users = a.userCache
+	a.notes = controllers.NewNoteController(noteService)
+	a.notes.RequireIfMatch = cfg.Server.RequireIfMatch
+	cardService := services.NewCardService(a.cardDao, a.cardVault)
+	cardService.Users = a.userCache
+	a.cards = controllers.NewCardController(cardService)
+	a.vault = controllers.NewVaultController(a.cardVault)
+
+	gateway := payments.NewFakeGateway(cfg.Payments.WebhookSecret)
+	a.payments = controllers.NewPaymentController(payments.NewChargeService(dao.NewChargeDao(db), a.cardDao, a.cardVault, gateway))
+
+	a.reminder = services.NewCardExpiryReminder(a.cardDao, services.LogNotifier{})
+
+	importService := services.NewUserImportService(a.userDao, services.LogNotifier{})
+	importService.Users = a.userCache
+	a.imports = services.NewImportJobs(importService)
+	a.importer = controllers.NewImportController(importService, a.imports)
+	a.exports = controllers.NewExportController(services.NewExportService(a.userDao, a.noteDao))
+	privacyService := services.NewPrivacyService(dao.NewPrivacyDao(db))
+	privacyService.Users = a.userCache
+	a.privacy = controllers.NewPrivacyController(privacyService)
+
+	a.login = controllers.NewAuthController(*a.userDao, cfg.Auth)
+	a.auth = middleware.NewAuthenticator(cfg.Auth.JWTSecret, userService)
+
+	a.idempotency = dao.NewIdempotencyDao(db)
+	if cfg.Idempotency.Store == "memory" {
+		a.idempotency = idempotency.NewMemoryStore()
+	}
+	return a
+}
+
+func (a *app) router() *gin.Engine {
+	// Probes every few seconds would drown the request log.
+	router := gin.New()
+	router.Use(gin.LoggerWithConfig(gin.LoggerConfig{SkipPaths: []string{"/healthz", "/readyz"}}), gin.Recovery())
+	auth := a.auth.RequireAuth
+	// Creating POSTs accept an Idempotency-Key. Payments keep their own keys.
+	idempotent := middleware.Idempotency(a.idempotency, a.cfg.Idempotency.TTL)
+
+	router.GET("/healthz", a.probes.Live)
+	router.GET("/readyz", a.probes.Ready)
+
+	// Streaming exports and synchronous imports run as long as they need.
+	unbounded := middleware.NoDeadlines()
+	router.POST("/admin/users/import", unbounded, auth("RoleAdmin"), a.importer.ImportUsers)
+	router.GET("/admin/export/users", unbounded, auth("RoleAdmin"), a.exports.ExportUsers)
+	router.GET("/admin/export/notes", unbounded, auth("RoleAdmin"), a.exports.ExportNotes)
+
+	api := router.Group("/", middleware.Timeout(a.cfg.Database.RequestTimeout))
+
+	api.POST("/users", auth("RoleUser", "RoleAdmin"), idempotent, a.users.CreateUser)
+	api.GET("/users/:id", auth("RoleUser", "RoleAdmin"), a.users.GetUserById)
+	api.GET("/users", auth("RoleUser", "RoleAdmin"), a.users.GetAllUsers)
+	api.PUT("/users/:id", auth("RoleUser", "RoleAdmin"), a.users.UpdateUser)
+	api.PATCH("/users/:id", auth("RoleUser", "RoleAdmin"), a.users.PatchUser)
+	api.DELETE("/users/:id", auth("RoleUser", "RoleAdmin"), a.users.DeleteUser)
+	api.POST("/users/:id/restore", auth("RoleAdmin"), a.users.RestoreUser)
+	api.GET("/users/:id/data-export", auth("RoleUser", "RoleAdmin"), a.privacy.DataExport)
+	api.POST("/users/:id/erase", auth("RoleUser", "RoleAdmin"), a.privacy.Erase)
+
+	api.GET("/admin/users/import/:jobId", auth("RoleAdmin"), a.importer.GetImportJob)
+
+	api.GET("/users/:id/notes", auth("RoleUser", "RoleAdmin"), a.notes.ListNotes)
+	api.POST("/users/:id/notes", auth("RoleUser", "RoleAdmin"), idempotent, a.notes.CreateNote)
+	api.GET("/users/:id/notes/:noteId", auth("RoleUser", "RoleAdmin"), a.notes.GetNote)
+	api.PUT("/users/:id/notes/:noteId", auth("RoleUser", "RoleAdmin"), a.notes.UpdateNote)
+	api.DELETE("/users/:id/notes/:noteId", auth("RoleUser", "RoleAdmin"), a.notes.DeleteNote)
+
+	api.GET("/users/:id/cards", auth("RoleUser", "RoleAdmin"), a.cards.ListCards)
+	api.POST("/users/:id/cards", auth("RoleUser", "RoleAdmin"), idempotent, a.cards.AddCard)
+	api.PUT("/users/:id/cards/:cardId/default", auth("RoleUser", "RoleAdmin"), a.cards.SetDefaultCard)
+	api.DELETE("/users/:id/cards/:cardId", auth("RoleUser", "RoleAdmin"), a.cards.RemoveCard)
+
+	api.POST("/payments/charges", auth("RoleUser", "RoleAdmin"), a.payments.CreateCharge)
+	api.GET("/payments/charges/:id", auth("RoleUser", "RoleAdmin"), a.payments.GetCharge)
+	api.POST("/payments/charges/:id/capture", auth("RoleAdmin"), a.payments.CaptureCharge)
+	api.POST("/payments/charges/:id/refund", auth("RoleAdmin"), a.payments.RefundCharge)
+	api.POST("/payments/charges/:id/void", auth("RoleAdmin"), a.payments.VoidCharge)
+	api.POST("/payments/webhooks/:gateway", a.payments.Webhook)
+
+	api.POST("/vault/detokenize", auth("RoleAdmin"), a.vault.Detokenize)
+
+	api.GET("/admin/cache/stats", auth("RoleAdmin"), a.caches.Stats)
+
+	api.POST("/signup", idempotent, a.users.Signup)
+	api.POST("/login", a.login.Login)
+
+	return router
+}
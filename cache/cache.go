@@ -0,0 +1,18 @@
+// Package cache keeps serialized values for a limited time, either in the
+// process or in Redis, and reads values through it with Loader.
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Cache stores values under string keys.
+type Cache interface {
+	// Get returns the value stored under key and whether there was one.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value under key for ttl. A zero ttl keeps it until it is
+	// deleted or evicted.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestBackends runs the same checks against every Cache. advance moves the
+// backend's clock.
+func TestBackends(t *testing.T) {
+	lru := NewLRU(2)
+	now := time.Now()
+	lru.now = func() time.Time { return now }
+
+	server := miniredis.RunT(t)
+	redis, err := NewRedis("redis://" + server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { redis.Close() })
+
+	backends := map[string]struct {
+		cache   Cache
+		advance func(time.Duration)
+	}{
+		"LRU":   {lru, func(d time.Duration) { now = now.Add(d) }},
+		"Redis": {redis, server.FastForward},
+	}
+	ctx := context.Background()
+
+	for name, backend := range backends {
+		cache := backend.cache
+		t.Run(name, func(t *testing.T) {
+			_, found, err := cache.Get(ctx, "missing")
+			assert.NoError(t, err)
+			assert.False(t, found)
+
+			assert.NoError(t, cache.Set(ctx, "a", []byte("1"), time.Minute))
+			assert.NoError(t, cache.Set(ctx, "b", []byte("2"), 0))
+			value, found, err := cache.Get(ctx, "a")
+			assert.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, []byte("1"), value)
+
+			backend.advance(time.Minute)
+			_, found, _ = cache.Get(ctx, "a")
+			assert.False(t, found, "expired")
+			_, found, _ = cache.Get(ctx, "b")
+			assert.True(t, found, "kept without ttl")
+
+			assert.NoError(t, cache.Delete(ctx, "b", "missing"))
+			_, found, _ = cache.Get(ctx, "b")
+			assert.False(t, found, "deleted")
+			assert.NoError(t, cache.Delete(ctx))
+		})
+	}
+}
+
+func TestLRU_Evicts(t *testing.T) {
+	lru := NewLRU(2)
+	ctx := context.Background()
+
+	lru.Set(ctx, "a", []byte("1"), 0)
+	lru.Set(ctx, "b", []byte("2"), 0)
+	lru.Get(ctx, "a")
+	lru.Set(ctx, "c", []byte("3"), 0)
+
+	_, found, _ := lru.Get(ctx, "b")
+	assert.False(t, found, "least recently used")
+	for _, key := range []string{"a", "c"} {
+		_, found, _ := lru.Get(ctx, key)
+		assert.True(t, found, key)
+	}
+
+	lru.Set(ctx, "a", []byte("4"), 0)
+	value, _, _ := lru.Get(ctx, "a")
+	assert.Equal(t, []byte("4"), value)
+	assert.Equal(t, 2, lru.Len())
+}
@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Loader reads values of type T through a cache, stored as JSON. On a miss
+// the value is loaded and stored for ttl; concurrent misses of one key share
+// a single load. A failing cache is logged and bypassed, so it never fails a
+// read.
+type Loader[T any] struct {
+	cache Cache
+	ttl   time.Duration
+	group singleflight.Group
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+	loads  atomic.Uint64
+	errors atomic.Uint64
+}
+
+// Stats counts the lookups of a Loader. Misses that joined a load already
+// in progress are not counted in Loads.
+type Stats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	Loads  uint64 `json:"loads"`
+	Errors uint64 `json:"errors"`
+}
+
+func NewLoader[T any](cache Cache, ttl time.Duration) *Loader[T] {
+	return &Loader[T]{cache: cache, ttl: ttl}
+}
+
+// Get returns the value cached under key, or the one load returns. Every
+// caller gets a copy of its own.
+func (l *Loader[T]) Get(ctx context.Context, key string, load func(ctx context.Context) (*T, error)) (*T, error) {
+	data, found, err := l.cache.Get(ctx, key)
+	if err != nil {
+		l.failed("read", key, err)
+	}
+	if found {
+		var value T
+		err := json.Unmarshal(data, &value)
+		if err == nil {
+			l.hits.Add(1)
+			return &value, nil
+		}
+		l.failed("decode", key, err)
+	}
+
+	l.misses.Add(1)
+	loaded, err, _ := l.group.Do(key, func() (interface{}, error) {
+		l.loads.Add(1)
+		value, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		if err := l.cache.Set(ctx, key, data, l.ttl); err != nil {
+			l.failed("write", key, err)
+		}
+		return data, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var value T
+	if err := json.Unmarshal(loaded.([]byte), &value); err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
+
+// Invalidate drops the values under keys. Loads in progress for them are
+// not shared with later callers, which load again.
+func (l *Loader[T]) Invalidate(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		l.group.Forget(key)
+	}
+	return l.cache.Delete(ctx, keys...)
+}
+
+func (l *Loader[T]) Stats() Stats {
+	return Stats{Hits: l.hits.Load(), Misses: l.misses.Load(), Loads: l.loads.Load(), Errors: l.errors.Load()}
+}
+
+func (l *Loader[T]) failed(operation string, key string, err error) {
+	l.errors.Add(1)
+	log.Printf("Cache %s of %s failed: %v", operation, key, err)
+}
@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type item struct {
+	ID   int
+	Name string
+}
+
+// brokenCache fails every operation, like an unreachable Redis.
+type brokenCache struct{}
+
+func (brokenCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	return nil, false, errors.New("connection refused")
+}
+
+func (brokenCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return errors.New("connection refused")
+}
+
+func (brokenCache) Delete(ctx context.Context, keys ...string) error {
+	return errors.New("connection refused")
+}
+
+func TestLoader(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Reads Through", func(t *testing.T) {
+		loader := NewLoader[item](NewLRU(10), time.Minute)
+		loads := 0
+		load := func(ctx context.Context) (*item, error) {
+			loads++
+			return &item{ID: 1, Name: "first"}, nil
+		}
+
+		first, err := loader.Get(ctx, "item:1", load)
+		assert.NoError(t, err)
+		first.Name = "changed by the caller"
+		second, err := loader.Get(ctx, "item:1", load)
+		assert.NoError(t, err)
+		assert.Equal(t, &item{ID: 1, Name: "first"}, second)
+		assert.Equal(t, 1, loads)
+
+		assert.NoError(t, loader.Invalidate(ctx, "item:1"))
+		_, err = loader.Get(ctx, "item:1", load)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, loads)
+		assert.Equal(t, Stats{Hits: 1, Misses: 2, Loads: 2}, loader.Stats())
+	})
+
+	t.Run("Does Not Cache Errors", func(t *testing.T) {
+		loader := NewLoader[item](NewLRU(10), time.Minute)
+		failure := errors.New("not found")
+		load := func(ctx context.Context) (*item, error) {
+			return nil, failure
+		}
+
+		for i := 0; i < 2; i++ {
+			_, err := loader.Get(ctx, "item:2", load)
+			assert.ErrorIs(t, err, failure)
+		}
+		assert.Equal(t, Stats{Misses: 2, Loads: 2}, loader.Stats())
+	})
+
+	t.Run("Concurrent Misses Share One Load", func(t *testing.T) {
+		loader := NewLoader[item](NewLRU(10), time.Minute)
+		release := make(chan struct{})
+		var mu sync.Mutex
+		loads := 0
+		load := func(ctx context.Context) (*item, error) {
+			mu.Lock()
+			loads++
+			mu.Unlock()
+			<-release
+			return &item{ID: 3}, nil
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				value, err := loader.Get(ctx, "item:3", load)
+				assert.NoError(t, err)
+				assert.Equal(t, 3, value.ID)
+			}()
+		}
+		// Wait until every caller missed before the load may finish.
+		for loader.Stats().Misses < 10 {
+			time.Sleep(time.Millisecond)
+		}
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, 1, loads)
+		assert.Equal(t, uint64(1), loader.Stats().Loads)
+	})
+
+	t.Run("Bypasses A Failing Cache", func(t *testing.T) {
+		loader := NewLoader[item](brokenCache{}, time.Minute)
+		value, err := loader.Get(ctx, "item:4", func(ctx context.Context) (*item, error) {
+			return &item{ID: 4}, nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 4, value.ID)
+		assert.Equal(t, Stats{Misses: 1, Loads: 1, Errors: 2}, loader.Stats())
+	})
+}
@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// LRU is an in-process cache of at most size entries. When it is full the
+// least recently used entry makes room.
+type LRU struct {
+	size int
+	now  func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+func NewLRU(size int) *LRU {
+	return &LRU{size: size, now: time.Now, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+func (l *LRU) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	element, ok := l.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := element.Value.(*lruEntry)
+	if !entry.expires.IsZero() && !l.now().Before(entry.expires) {
+		l.remove(element)
+		return nil, false, nil
+	}
+	l.order.MoveToFront(element)
+	return entry.value, true, nil
+}
+
+func (l *LRU) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	entry := &lruEntry{key: key, value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		entry.expires = l.now().Add(ttl)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if element, ok := l.entries[key]; ok {
+		element.Value = entry
+		l.order.MoveToFront(element)
+		return nil
+	}
+	l.entries[key] = l.order.PushFront(entry)
+	if l.order.Len() > l.size {
+		l.remove(l.order.Back())
+	}
+	return nil
+}
+
+func (l *LRU) Delete(ctx context.Context, keys ...string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, key := range keys {
+		if element, ok := l.entries[key]; ok {
+			l.remove(element)
+		}
+	}
+	return nil
+}
+
+// Len returns the number of entries, expired ones included.
+func (l *LRU) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
+
+func (l *LRU) remove(element *list.Element) {
+	l.order.Remove(element)
+	delete(l.entries, element.Value.(*lruEntry).key)
+}
@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis keeps the cache in Redis, or any server speaking its protocol, so
+// every instance of the service shares it.
+type Redis struct {
+	client *redis.Client
+}
+
+// NewRedis connects lazily to the server at url, e.g.
+// redis://:password@localhost:6379/0.
+func NewRedis(url string) (*Redis, error) {
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	return &Redis{client: redis.NewClient(options)}, nil
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := r.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (r *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return r.client.Del(ctx, keys...).Err()
+}
+
+func (r *Redis) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"golang/dao"
+	"golang/encryption"
+	"golang/initializers"
+	"golang/migrations"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// runCommand runs one of the maintenance subcommands instead of the server.
+func runCommand(name string, args []string) {
+	switch name {
+	case "gen-master-key":
+		genMasterKey(args)
+	case "reencrypt-vault":
+		reencryptVault(args)
+	case "migrate":
+		migrate(args)
+	default:
+		log.Fatalf("Unknown command %q", name)
+	}
+}
+
+func genMasterKey(args []string) {
+	flags := flag.NewFlagSet("gen-master-key", flag.ExitOnError)
+	out := flags.String("out", "master.key", "file to write the key to")
+	keyID := flags.String("id", "k1", "id of the new key")
+	flags.Parse(args)
+
+	if err := encryption.GenerateKeyFile(*out, *keyID); err != nil {
+		log.Fatal("Failed to generate master key: ", err)
+	}
+	log.Printf("Master key %s written to %s", *keyID, *out)
+}
+
+// migrate applies or reverts schema migrations:
+//
+//	migrate up             apply every pending migration
+//	migrate down [-steps]  revert the last applied migrations (default 1)
+//	migrate status         list migrations and when they were applied
+//	migrate redo           revert and reapply the last applied migration
+func migrate(args []string) {
+	if len(args) == 0 {
+		log.Fatal("Usage: migrate up|down|status|redo")
+	}
+	flags := flag.NewFlagSet("migrate "+args[0], flag.ExitOnError)
+	steps := flags.Int("steps", 1, "number of migrations to revert")
+	flags.Parse(args[1:])
+
+	cfg := loadConfig(nil)
+	db, err := initializers.OpenDB(cfg.Database)
+	if err != nil {
+		log.Fatal("Failed to connect to the Database: ", err)
+	}
+	migrator, err := migrations.New(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up()
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Applied %d migrations", len(applied))
+	case "down":
+		reverted, err := migrator.Down(*steps)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Reverted %d migrations", len(reverted))
+	case "redo":
+		redone, err := migrator.Redo()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if redone == nil {
+			log.Print("No migration has been applied")
+		}
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			log.Fatal(err)
+		}
+		out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(out, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(out, "%04d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		out.Flush()
+	default:
+		log.Fatalf("Unknown migrate command %q", args[0])
+	}
+}
+
+// reencryptVault moves every vaulted card number from the master key(s) in
+// -from to the active key in -to. MASTER_KEY_FILE should point at -to once
+// the command has finished.
+func reencryptVault(args []string) {
+	flags := flag.NewFlagSet("reencrypt-vault", flag.ExitOnError)
+	fromFile := flags.String("from", "", "key file currently protecting the data")
+	toFile := flags.String("to", "", "key file holding the new master key")
+	flags.Parse(args)
+
+	if *fromFile == "" || *toFile == "" {
+		log.Fatal("Both -from and -to are required")
+	}
+	from, err := encryption.NewFileKeyProvider(*fromFile)
+	if err != nil {
+		log.Fatal("Failed to load old master key: ", err)
+	}
+	to, err := encryption.NewFileKeyProvider(*toFile)
+	if err != nil {
+		log.Fatal("Failed to load new master key: ", err)
+	}
+
+	cfg := loadConfig(nil)
+	encryption.SetKeyProvider(from)
+	db := initializers.InitializeDB(cfg.Database)
+
+	count, err := dao.ReencryptVaultNumbers(db, from, to)
+	if err != nil {
+		log.Fatal("Failed to re-encrypt vault: ", err)
+	}
+	log.Printf("Re-encrypted %d vaulted card numbers under key %s", count, to.KeyID())
+}
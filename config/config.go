@@ -0,0 +1,301 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the complete configuration of the service. Every field can be
+// set in the config file under its yaml/toml name, in the environment
+// variable named by its env tag and with the command-line flag named by its
+// flag tag, in increasing order of precedence. Fields tagged secret are
+// redacted when the config is printed.
+type Config struct {
+	// Dev runs the server without external services: the database is a
+	// local SQLite file unless another SQLite database is configured, and
+	// development secrets fill in for missing ones.
+	Dev         bool              `yaml:"dev" env:"DEV" flag:"dev" usage:"run on SQLite with development secrets"`
+	Server      ServerConfig      `yaml:"server"`
+	Database    DatabaseConfig    `yaml:"database"`
+	Auth        AuthConfig        `yaml:"auth"`
+	Encryption  EncryptionConfig  `yaml:"encryption"`
+	OAuth       OAuthConfig       `yaml:"oauth"`
+	Payments    PaymentsConfig    `yaml:"payments"`
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+	Cache       CacheConfig       `yaml:"cache"`
+}
+
+type ServerConfig struct {
+	Port int `yaml:"port" env:"PORT" flag:"port" usage:"port to listen on"`
+	// Address is host:port to listen on. It overrides Port, e.g. to listen
+	// on localhost only.
+	Address string `yaml:"address" env:"ADDRESS" flag:"address" usage:"host:port to listen on, overrides port"`
+	// RequireIfMatch rejects writes without an If-Match header with 428.
+	RequireIfMatch bool `yaml:"require_if_match" env:"REQUIRE_IF_MATCH" flag:"require-if-match" usage:"require If-Match on writes"`
+
+	// Zero timeouts leave that part of a request unbounded. Streaming
+	// exports and imports are exempt from ReadTimeout and WriteTimeout.
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"READ_TIMEOUT" flag:"read-timeout" usage:"time limit for reading a request, 0 for none"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"READ_HEADER_TIMEOUT" flag:"read-header-timeout" usage:"time limit for reading request headers, 0 for none"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"WRITE_TIMEOUT" flag:"write-timeout" usage:"time limit for writing a response, 0 for none"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"IDLE_TIMEOUT" flag:"idle-timeout" usage:"how long an idle keep-alive connection is kept, 0 for none"`
+	MaxHeaderBytes    int           `yaml:"max_header_bytes" env:"MAX_HEADER_BYTES" flag:"max-header-bytes" usage:"maximum size of request headers"`
+	// ShutdownTimeout is how long requests in flight and background jobs
+	// may finish after SIGTERM or SIGINT.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" usage:"time allowed for draining on shutdown"`
+	// ShutdownDelay is how long /readyz fails before the server stops
+	// accepting connections, so load balancers take it out of rotation
+	// first. It should exceed the readiness probe interval.
+	ShutdownDelay time.Duration `yaml:"shutdown_delay" env:"SHUTDOWN_DELAY" flag:"shutdown-delay" usage:"time between failing readiness and shutting down"`
+
+	// With a certificate and key the server only speaks HTTPS. Renewed
+	// files are picked up without a restart.
+	TLSCertFile string `yaml:"tls_cert_file" env:"TLS_CERT_FILE" flag:"tls-cert-file" usage:"TLS certificate file (PEM)"`
+	TLSKeyFile  string `yaml:"tls_key_file" env:"TLS_KEY_FILE" flag:"tls-key-file" usage:"TLS private key file (PEM)"`
+}
+
+// Addr is the address to listen on.
+func (s ServerConfig) Addr() string {
+	if s.Address != "" {
+		return s.Address
+	}
+	return fmt.Sprintf(":%d", s.Port)
+}
+
+func (s ServerConfig) TLSEnabled() bool {
+	return s.TLSCertFile != ""
+}
+
+type DatabaseConfig struct {
+	// Driver is postgres, mysql or sqlite. For sqlite, URL is the database
+	// file or :memory:.
+	Driver string `yaml:"driver" env:"DB_DRIVER" flag:"db-driver" usage:"database driver: postgres, mysql or sqlite"`
+	URL    string `yaml:"url" env:"DB_URL" flag:"db-url" secret:"true" usage:"database connection string"`
+
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" flag:"db-max-open-conns" usage:"maximum open connections, 0 for no limit"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" flag:"db-max-idle-conns" usage:"maximum idle connections"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" flag:"db-conn-max-lifetime" usage:"maximum age of a connection, 0 for no limit"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME" flag:"db-conn-max-idle-time" usage:"maximum idle time of a connection, 0 for no limit"`
+	// ConnectTimeout is how long startup keeps retrying to reach the
+	// database, e.g. while its container is starting.
+	ConnectTimeout time.Duration `yaml:"connect_timeout" env:"DB_CONNECT_TIMEOUT" flag:"db-connect-timeout" usage:"how long to retry connecting at startup"`
+	// MigrateOnStart applies pending migrations at startup. Otherwise the
+	// server refuses to start until "migrate up" has been run.
+	MigrateOnStart bool `yaml:"migrate_on_start" env:"DB_MIGRATE_ON_START" flag:"db-migrate-on-start" usage:"apply pending migrations at startup"`
+	// RequestTimeout bounds the database work of one request. Streaming
+	// exports and synchronous imports are exempt.
+	RequestTimeout time.Duration `yaml:"request_timeout" env:"DB_REQUEST_TIMEOUT" flag:"db-request-timeout" usage:"time limit for the database work of a request, 0 for none"`
+	Replicas       ReplicaConfig `yaml:"replicas"`
+}
+
+// ReplicaConfig lists read replicas of the database. Reads go to a healthy
+// replica and writes to the primary.
+type ReplicaConfig struct {
+	// URLs is a comma-separated list of connection strings for the same
+	// driver as the primary.
+	URLs string `yaml:"urls" env:"DB_REPLICA_URLS" flag:"db-replica-urls" secret:"true" usage:"comma-separated read replica connection strings"`
+	// ReadYourWrites is how long a user's reads stay on the primary after
+	// they wrote something, so they see their own changes. Instances share
+	// the writes through the Redis cache backend; with any other backend
+	// only the instance that wrote knows about them.
+	ReadYourWrites time.Duration `yaml:"read_your_writes" env:"DB_READ_YOUR_WRITES" flag:"db-read-your-writes" usage:"how long a user reads from the primary after a write"`
+	// MaxLag is how far a replica may fall behind before reads leave it.
+	MaxLag        time.Duration `yaml:"max_lag" env:"DB_REPLICA_MAX_LAG" flag:"db-replica-max-lag" usage:"replication lag at which a replica stops serving reads, 0 for no limit"`
+	CheckInterval time.Duration `yaml:"check_interval" env:"DB_REPLICA_CHECK_INTERVAL" flag:"db-replica-check-interval" usage:"how often replica health and lag are checked"`
+}
+
+// List returns the replica connection strings.
+func (r ReplicaConfig) List() []string {
+	var urls []string
+	for _, url := range strings.Split(r.URLs, ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+type AuthConfig struct {
+	JWTSecret string        `yaml:"jwt_secret" env:"SECRET" flag:"jwt-secret" secret:"true" usage:"key signing access tokens"`
+	TokenTTL  time.Duration `yaml:"token_ttl" env:"TOKEN_TTL" flag:"token-ttl" usage:"lifetime of access tokens"`
+}
+
+type EncryptionConfig struct {
+	MasterKeyFile string `yaml:"master_key_file" env:"MASTER_KEY_FILE" flag:"master-key-file" usage:"file holding the master encryption keys"`
+}
+
+// OAuthConfig configures sign-in with Google. It is optional, but either all
+// or none of the fields must be set.
+type OAuthConfig struct {
+	ClientID     string `yaml:"client_id" env:"CLIENT_ID" flag:"oauth-client-id" usage:"Google OAuth client id"`
+	ClientSecret string `yaml:"client_secret" env:"CLIENT_SECRET" flag:"oauth-client-secret" secret:"true" usage:"Google OAuth client secret"`
+	CallbackURL  string `yaml:"callback_url" env:"CLIENT_CALLBACK_URL" flag:"oauth-callback-url" usage:"Google OAuth callback URL"`
+}
+
+type PaymentsConfig struct {
+	WebhookSecret string `yaml:"webhook_secret" env:"FAKE_GATEWAY_WEBHOOK_SECRET" flag:"webhook-secret" secret:"true" usage:"key verifying payment gateway webhooks"`
+}
+
+// CacheConfig sets up the cache of users looked up by ID.
+type CacheConfig struct {
+	// Backend is "redis" for a cache shared by every instance, "memory" for
+	// a cache per instance, or "none". Left empty it is redis when RedisURL
+	// is set and none otherwise. Use memory only with a single instance: the
+	// others keep serving a user changed or deleted elsewhere for up to TTL.
+	Backend  string `yaml:"backend" env:"CACHE_BACKEND" flag:"cache-backend" usage:"where users are cached: memory, redis or none"`
+	RedisURL string `yaml:"redis_url" env:"REDIS_URL" flag:"redis-url" secret:"true" usage:"redis://[:password@]host:port/db for the redis backend"`
+	// Size is how many entries the memory backend keeps.
+	Size int           `yaml:"size" env:"CACHE_SIZE" flag:"cache-size" usage:"entries kept by the memory backend"`
+	TTL  time.Duration `yaml:"ttl" env:"CACHE_TTL" flag:"cache-ttl" usage:"how long a cached user is served"`
+}
+
+type IdempotencyConfig struct {
+	// Store is "db", shared by every instance, or "memory".
+	Store string        `yaml:"store" env:"IDEMPOTENCY_STORE" flag:"idempotency-store" usage:"where idempotent responses are kept: db or memory"`
+	TTL   time.Duration `yaml:"ttl" env:"IDEMPOTENCY_TTL" flag:"idempotency-ttl" usage:"how long idempotent responses are replayed"`
+}
+
+// Default returns the configuration used for every field that is not set
+// anywhere else.
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Port:              8080,
+			RequireIfMatch:    true,
+			ReadTimeout:       30 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      60 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			MaxHeaderBytes:    1 << 20,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Driver:          "postgres",
+			MaxOpenConns:    25,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+			ConnectTimeout:  30 * time.Second,
+			RequestTimeout:  10 * time.Second,
+			Replicas: ReplicaConfig{
+				ReadYourWrites: 5 * time.Second,
+				MaxLag:         5 * time.Second,
+				CheckInterval:  10 * time.Second,
+			},
+		},
+		Auth:        AuthConfig{TokenTTL: 30 * 24 * time.Hour},
+		Idempotency: IdempotencyConfig{Store: "db", TTL: 24 * time.Hour},
+		Cache:       CacheConfig{Size: 10000, TTL: time.Minute},
+	}
+}
+
+// Validate checks the whole configuration and reports every problem at once.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	server := c.Server
+	check(server.Port > 0 && server.Port <= 65535, "server.port must be between 1 and 65535, got %d", server.Port)
+	check(server.ReadTimeout >= 0 && server.ReadHeaderTimeout >= 0 && server.WriteTimeout >= 0 && server.IdleTimeout >= 0, "server timeouts must not be negative")
+	check(server.MaxHeaderBytes > 0, "server.max_header_bytes must be positive")
+	check(server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+	check(server.ShutdownDelay >= 0, "server.shutdown_delay must not be negative")
+	check((server.TLSCertFile == "") == (server.TLSKeyFile == ""), "server.tls_cert_file and server.tls_key_file must be set together")
+	db := c.Database
+	check(db.Driver == "postgres" || db.Driver == "mysql" || db.Driver == "sqlite", "database.driver must be postgres, mysql or sqlite, got %q", db.Driver)
+	check(db.URL != "", "database.url (DB_URL) is required")
+	check(db.MaxOpenConns >= 0 && db.MaxIdleConns >= 0, "database connection limits must not be negative")
+	check(db.MaxOpenConns == 0 || db.MaxIdleConns <= db.MaxOpenConns, "database.max_idle_conns must not exceed database.max_open_conns")
+	check(db.ConnMaxLifetime >= 0 && db.ConnMaxIdleTime >= 0 && db.ConnectTimeout >= 0 && db.RequestTimeout >= 0, "database durations must not be negative")
+	check(db.Replicas.ReadYourWrites >= 0 && db.Replicas.MaxLag >= 0, "database replica durations must not be negative")
+	check(db.Replicas.CheckInterval > 0, "database.replicas.check_interval must be positive")
+	check(c.Auth.JWTSecret != "", "auth.jwt_secret (SECRET) is required")
+	check(c.Auth.TokenTTL > 0, "auth.token_ttl must be positive")
+	check(c.Encryption.MasterKeyFile != "", "encryption.master_key_file (MASTER_KEY_FILE) is required")
+
+	oauth := c.OAuth
+	set := 0
+	for _, value := range []string{oauth.ClientID, oauth.ClientSecret, oauth.CallbackURL} {
+		if value != "" {
+			set++
+		}
+	}
+	check(set == 0 || set == 3, "oauth.client_id, oauth.client_secret and oauth.callback_url must be set together")
+
+	check(c.Idempotency.Store == "db" || c.Idempotency.Store == "memory", "idempotency.store must be db or memory, got %q", c.Idempotency.Store)
+	check(c.Idempotency.TTL > 0, "idempotency.ttl must be positive")
+
+	cache := c.Cache
+	check(cache.Backend == "memory" || cache.Backend == "redis" || cache.Backend == "none", "cache.backend must be memory, redis or none, got %q", cache.Backend)
+	check(cache.Backend != "redis" || cache.RedisURL != "", "cache.redis_url (REDIS_URL) is required for the redis backend")
+	check(cache.Backend != "memory" || cache.Size > 0, "cache.size must be positive")
+	check(cache.TTL > 0, "cache.ttl must be positive")
+
+	return errors.Join(errs...)
+}
+
+// applyCacheDefault picks the cache backend when none was configured.
+func (c *Config) applyCacheDefault() {
+	if c.Cache.Backend != "" {
+		return
+	}
+	c.Cache.Backend = "none"
+	if c.Cache.RedisURL != "" {
+		c.Cache.Backend = "redis"
+	}
+}
+
+// applyDev fills in what a development server needs and has no external
+// service for.
+func (c *Config) applyDev() {
+	if !c.Dev {
+		return
+	}
+	if c.Database.Driver != "sqlite" {
+		c.Database.Driver = "sqlite"
+		c.Database.URL = "dev.db"
+		c.Database.Replicas.URLs = ""
+	}
+	c.Database.MigrateOnStart = true
+	if c.Auth.JWTSecret == "" {
+		c.Auth.JWTSecret = "dev-secret"
+	}
+	if c.Encryption.MasterKeyFile == "" {
+		c.Encryption.MasterKeyFile = "dev-master.key"
+	}
+}
+
+// OAuthEnabled reports whether sign-in with Google is configured.
+func (c Config) OAuthEnabled() bool {
+	return c.OAuth.ClientID != ""
+}
+
+// Redacted returns a copy of the config with every secret that is set
+// replaced by a placeholder.
+func (c Config) Redacted() Config {
+	eachField(&c, func(field field) {
+		if field.secret && field.value.String() != "" {
+			field.value.SetString(redacted)
+		}
+	})
+	return c
+}
+
+const redacted = "[redacted]"
+
+// String prints the config as YAML, without secrets.
+func (c Config) String() string {
+	out, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return err.Error()
+	}
+	return string(out)
+}
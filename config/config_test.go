@@ -0,0 +1,209 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func env(values map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := values[name]
+		return value, ok
+	}
+}
+
+func writeFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+var required = map[string]string{
+	"DB_URL":          "host=db",
+	"SECRET":          "jwt-secret",
+	"MASTER_KEY_FILE": "master.key",
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		cfg, err := Load(nil, env(required))
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, 8080, cfg.Server.Port)
+		assert.True(t, cfg.Server.RequireIfMatch)
+		assert.Equal(t, "db", cfg.Idempotency.Store)
+		assert.Equal(t, "host=db", cfg.Database.URL)
+		assert.False(t, cfg.OAuthEnabled())
+		assert.Equal(t, ":8080", cfg.Server.Addr())
+		assert.Equal(t, 30*time.Second, cfg.Server.ShutdownTimeout)
+		assert.False(t, cfg.Server.TLSEnabled())
+		assert.Equal(t, "none", cfg.Cache.Backend)
+	})
+
+	t.Run("Redis URL Enables The Redis Cache", func(t *testing.T) {
+		cfg, err := Load([]string{"-redis-url", "redis://redis:6379/0"}, env(required))
+		if assert.NoError(t, err) {
+			assert.Equal(t, "redis", cfg.Cache.Backend)
+		}
+	})
+
+	t.Run("Precedence", func(t *testing.T) {
+		file := writeFile(t, "config.yaml", `
+server:
+  port: 9000
+  require_if_match: false
+auth:
+  token_ttl: 1h
+idempotency:
+  store: memory
+  ttl: 2h
+`)
+		values := map[string]string{"CONFIG_FILE": file, "PORT": "9100", "IDEMPOTENCY_TTL": "3h"}
+		for name, value := range required {
+			values[name] = value
+		}
+
+		cfg, err := Load([]string{"-port", "9200"}, env(values))
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, 9200, cfg.Server.Port)
+		assert.Equal(t, ":9200", cfg.Server.Addr())
+		assert.False(t, cfg.Server.RequireIfMatch)
+		assert.Equal(t, time.Hour, cfg.Auth.TokenTTL)
+		assert.Equal(t, "memory", cfg.Idempotency.Store)
+		assert.Equal(t, 3*time.Hour, cfg.Idempotency.TTL)
+	})
+
+	t.Run("TOML", func(t *testing.T) {
+		file := writeFile(t, "config.toml", `
+[database]
+url = "host=toml"
+
+[auth]
+jwt_secret = "from-file"
+token_ttl = "90m"
+`)
+		cfg, err := Load([]string{"-config", file, "-master-key-file", "keys"}, env(nil))
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "host=toml", cfg.Database.URL)
+		assert.Equal(t, "from-file", cfg.Auth.JWTSecret)
+		assert.Equal(t, 90*time.Minute, cfg.Auth.TokenTTL)
+		assert.Equal(t, "keys", cfg.Encryption.MasterKeyFile)
+	})
+
+	t.Run("Replicas", func(t *testing.T) {
+		file := writeFile(t, "config.yaml", `
+database:
+  replicas:
+    urls: "host=replica1, host=replica2,"
+    max_lag: 2s
+`)
+		values := map[string]string{"CONFIG_FILE": file, "DB_READ_YOUR_WRITES": "1s"}
+		for name, value := range required {
+			values[name] = value
+		}
+
+		cfg, err := Load(nil, env(values))
+		if !assert.NoError(t, err) {
+			return
+		}
+		replicas := cfg.Database.Replicas
+		assert.Equal(t, []string{"host=replica1", "host=replica2"}, replicas.List())
+		assert.Equal(t, 2*time.Second, replicas.MaxLag)
+		assert.Equal(t, time.Second, replicas.ReadYourWrites)
+		assert.Equal(t, 10*time.Second, replicas.CheckInterval)
+		assert.NotContains(t, cfg.String(), "replica1")
+	})
+
+	t.Run("Reports Every Error", func(t *testing.T) {
+		file := writeFile(t, "config.yml", "server:\n  prot: 1\n")
+		_, err := Load([]string{"-config", file, "-token-ttl", "soon"}, env(map[string]string{
+			"PORT":          "http",
+			"CLIENT_ID":     "google",
+			"CACHE_BACKEND": "redis",
+			"TLS_CERT_FILE": "cert.pem",
+		}))
+		if !assert.Error(t, err) {
+			return
+		}
+
+		message := err.Error()
+		for _, expected := range []string{
+			"unknown setting server.prot",
+			`PORT: invalid server.port "http": not an integer`,
+			`flag -token-ttl: invalid auth.token_ttl "soon": not a duration`,
+			"database.url (DB_URL) is required",
+			"auth.jwt_secret (SECRET) is required",
+			"encryption.master_key_file (MASTER_KEY_FILE) is required",
+			"must be set together",
+			"server.tls_cert_file and server.tls_key_file must be set together",
+			"cache.redis_url (REDIS_URL) is required",
+		} {
+			assert.Contains(t, message, expected)
+		}
+	})
+
+	t.Run("Unknown Flag", func(t *testing.T) {
+		_, err := Load([]string{"-verbose"}, env(required))
+		assert.Error(t, err)
+	})
+}
+
+func TestConfig_String(t *testing.T) {
+	cfg, err := Load([]string{"-db-url", "postgres://app:hunter2@db/app"}, env(required))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	printed := cfg.String()
+	assert.NotContains(t, printed, "hunter2")
+	assert.NotContains(t, printed, "jwt-secret")
+	assert.Contains(t, printed, "url: '[redacted]'")
+	assert.Contains(t, printed, "master_key_file: master.key")
+	assert.Contains(t, printed, "client_secret: \"\"")
+	assert.Contains(t, printed, "token_ttl: 720h0m0s")
+	assert.Equal(t, "postgres://app:hunter2@db/app", cfg.Database.URL)
+}
+
+func TestLoad_Dev(t *testing.T) {
+	cfg, err := Load([]string{"-dev", "-port", "3000"}, env(map[string]string{"DB_URL": "host=db"}))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 3000, cfg.Server.Port)
+	assert.Equal(t, DatabaseConfig{
+		Driver:          "sqlite",
+		URL:             "dev.db",
+		MaxOpenConns:    25,
+		MaxIdleConns:    10,
+		ConnMaxLifetime: 30 * time.Minute,
+		ConnMaxIdleTime: 5 * time.Minute,
+		ConnectTimeout:  30 * time.Second,
+		RequestTimeout:  10 * time.Second,
+		MigrateOnStart:  true,
+		Replicas: ReplicaConfig{
+			ReadYourWrites: 5 * time.Second,
+			MaxLag:         5 * time.Second,
+			CheckInterval:  10 * time.Second,
+		},
+	}, cfg.Database)
+	assert.NotEmpty(t, cfg.Auth.JWTSecret)
+	assert.Equal(t, "dev-master.key", cfg.Encryption.MasterKeyFile)
+
+	t.Run("Keeps A Configured SQLite Database", func(t *testing.T) {
+		cfg, err := Load([]string{"-dev=true", "-db-driver", "sqlite", "-db-url", ":memory:"}, env(nil))
+		if assert.NoError(t, err) {
+			assert.Equal(t, ":memory:", cfg.Database.URL)
+		}
+	})
+}
@@ -0,0 +1,240 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Load builds the config from the defaults, the optional config file, the
+// environment and the command-line flags in args, each overriding the one
+// before, applies dev mode and validates the result. The config file is named by the -config
+// flag or the CONFIG_FILE environment variable; its format follows the
+// extension (.yaml, .yml or .toml).
+//
+// All invalid values and validation failures are returned together.
+func Load(args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	cfg := Default()
+
+	flags := flag.NewFlagSet("server", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	configFile := flags.String("config", "", "YAML or TOML config file")
+	var flagValues []setting
+	eachField(&cfg, func(field field) {
+		flags.Var(&flagValue{field: field, collect: func(value string) {
+			flagValues = append(flagValues, setting{field.path, value, "flag -" + field.flag})
+		}}, field.flag, field.usage)
+	})
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments %q", flags.Args())
+	}
+
+	var errs []error
+	if *configFile == "" {
+		*configFile, _ = lookupEnv("CONFIG_FILE")
+	}
+	if *configFile != "" {
+		settings, err := readFile(*configFile)
+		if err != nil {
+			return nil, err
+		}
+		errs = append(errs, apply(&cfg, settings)...)
+	}
+
+	var envValues []setting
+	eachField(&cfg, func(field field) {
+		if value, ok := lookupEnv(field.env); ok {
+			envValues = append(envValues, setting{field.path, value, field.env})
+		}
+	})
+	errs = append(errs, apply(&cfg, envValues)...)
+	errs = append(errs, apply(&cfg, flagValues)...)
+	cfg.applyDev()
+	cfg.applyCacheDefault()
+
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Usage lists the command-line flags.
+func Usage(out io.Writer) {
+	flags := flag.NewFlagSet("server", flag.ContinueOnError)
+	flags.SetOutput(out)
+	flags.String("config", "", "YAML or TOML config file")
+	cfg := Default()
+	eachField(&cfg, func(field field) {
+		flags.String(field.flag, fmt.Sprint(field.value.Interface()), fmt.Sprintf("%s (env %s)", field.usage, field.env))
+	})
+	flags.PrintDefaults()
+}
+
+// setting is one value for the field at path, as a string from source.
+type setting struct {
+	path   string
+	value  string
+	source string
+}
+
+func apply(cfg *Config, settings []setting) []error {
+	fields := map[string]field{}
+	eachField(cfg, func(field field) {
+		fields[field.path] = field
+	})
+
+	var errs []error
+	for _, setting := range settings {
+		field, ok := fields[setting.path]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown setting %s", setting.source, setting.path))
+			continue
+		}
+		if err := field.set(setting.value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid %s %q: %w", setting.source, setting.path, setting.value, err))
+		}
+	}
+	return errs
+}
+
+// readFile flattens the config file to one setting per field, so file values
+// are parsed exactly like environment variables.
+func readFile(path string) ([]setting, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var tree map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return nil, fmt.Errorf("config file %s must end in .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	var settings []setting
+	var flatten func(prefix string, tree map[string]interface{})
+	flatten = func(prefix string, tree map[string]interface{}) {
+		keys := make([]string, 0, len(tree))
+		for key := range tree {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value := tree[key]
+			if nested, ok := value.(map[string]interface{}); ok {
+				flatten(prefix+key+".", nested)
+				continue
+			}
+			settings = append(settings, setting{prefix + key, fmt.Sprint(value), path})
+		}
+	}
+	flatten("", tree)
+	return settings, nil
+}
+
+// flagValue collects a flag for later, so flags override the environment no
+// matter when they are parsed. Boolean fields make boolean flags, which need
+// no value.
+type flagValue struct {
+	field   field
+	collect func(string)
+}
+
+func (f *flagValue) String() string {
+	return ""
+}
+
+func (f *flagValue) Set(value string) error {
+	f.collect(value)
+	return nil
+}
+
+func (f *flagValue) IsBoolFlag() bool {
+	return f.field.value.Kind() == reflect.Bool
+}
+
+// field is a settable leaf of Config.
+type field struct {
+	// path is the dotted yaml name, e.g. database.url.
+	path   string
+	env    string
+	flag   string
+	usage  string
+	secret bool
+	value  reflect.Value
+}
+
+func (f field) set(value string) error {
+	switch f.value.Interface().(type) {
+	case string:
+		f.value.SetString(value)
+	case int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("not an integer")
+		}
+		f.value.SetInt(int64(n))
+	case bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("not a boolean")
+		}
+		f.value.SetBool(b)
+	case time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.New("not a duration")
+		}
+		f.value.SetInt(int64(d))
+	default:
+		return fmt.Errorf("unsupported type %s", f.value.Type())
+	}
+	return nil
+}
+
+func eachField(cfg *Config, fn func(field)) {
+	var walk func(prefix string, value reflect.Value)
+	walk = func(prefix string, value reflect.Value) {
+		for i := 0; i < value.NumField(); i++ {
+			structField := value.Type().Field(i)
+			name := strings.Split(structField.Tag.Get("yaml"), ",")[0]
+			if structField.Type.Kind() == reflect.Struct {
+				walk(prefix+name+".", value.Field(i))
+				continue
+			}
+			fn(field{
+				path:   prefix + name,
+				env:    structField.Tag.Get("env"),
+				flag:   structField.Tag.Get("flag"),
+				usage:  structField.Tag.Get("usage"),
+				secret: structField.Tag.Get("secret") == "true",
+				value:  value.Field(i),
+			})
+		}
+	}
+	walk("", reflect.ValueOf(cfg).Elem())
+}
@@ -1,12 +1,12 @@
 package controllers
 
 import (
-	"fmt"
+	"golang/config"
 	"golang/dao"
 	"golang/models"
-	"log"
+	"golang/replicas"
+	"golang/services"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -14,11 +14,13 @@ import (
 )
 
 type AuthController struct {
-	userDao dao.UserDao
+	userDao  dao.UserDao
+	secret   []byte
+	tokenTTL time.Duration
 }
 
-func NewAuthController(dao dao.UserDao) *AuthController {
-	return &AuthController{userDao: dao}
+func NewAuthController(dao dao.UserDao, cfg config.AuthConfig) *AuthController {
+	return &AuthController{userDao: dao, secret: []byte(cfg.JWTSecret), tokenTTL: cfg.TokenTTL}
 }
 
 func (ac *AuthController) Login(c *gin.Context) {
@@ -34,13 +36,9 @@ func (ac *AuthController) Login(c *gin.Context) {
 	var user *models.User
 	var err error
 	// initializers.DB.First(&user, "email = ?", requestBody.Email)
-	user, err = ac.userDao.FindByEmail(requestBody.Email)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err,
-		})
-	}
-	if user.ID == 0 {
+	// A user who just signed up may not have reached the replicas yet.
+	user, err = ac.userDao.FindByEmail(replicas.Primary(c.Request.Context()), requestBody.Email)
+	if err != nil || user.ID == 0 || !services.CheckPassword(user.Password, requestBody.Password) {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Invalid email or password",
 		})
@@ -51,15 +49,11 @@ func (ac *AuthController) Login(c *gin.Context) {
 	// you would like it to contain.
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"sub":  user.ID,
-		"exp":  time.Now().Add(time.Hour * 24 * 30).Unix(),
+		"exp":  time.Now().Add(ac.tokenTTL).Unix(),
 		"role": models.Role.String(user.Role),
 	})
 	// Sign and get the complete encoded token as a string using the secret
-	tokenString, err := token.SignedString([]byte(os.Getenv("SECRET")))
-
-	log.Println("token string : ", tokenString)
-	fmt.Println(tokenString, err)
-
+	tokenString, err := token.SignedString(ac.secret)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "error generating token",
@@ -67,12 +61,6 @@ func (ac *AuthController) Login(c *gin.Context) {
 		return
 	}
 
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err,
-		})
-	}
-
 	// c.SetSameSite(http.SameSiteLaxMode)
 	// c.SetCookie("Authorization", tokenString, 3600*24*30, "", "", false, true)
 
@@ -0,0 +1,22 @@
+package controllers
+
+import (
+	"golang/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CacheController struct {
+	users *services.UserCache
+}
+
+func NewCacheController(users *services.UserCache) *CacheController {
+	return &CacheController{users: users}
+}
+
+// Stats returns the hits, misses, loads and errors of every cache since the
+// server started.
+func (cc *CacheController) Stats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"users": cc.users.Stats()})
+}
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"errors"
+	"golang/dto"
+	"golang/models"
+	"golang/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CardController struct {
+	cardService services.ICardService
+}
+
+func NewCardController(cardService services.ICardService) *CardController {
+	return &CardController{cardService: cardService}
+}
+
+func (cc *CardController) ListCards(c *gin.Context) {
+	userID, ok := ownerParam(c)
+	if !ok {
+		return
+	}
+
+	cards, err := cc.cardService.List(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewCardResponses(cards, dto.ViewFor(currentUser(c), userID)))
+}
+
+func (cc *CardController) AddCard(c *gin.Context) {
+	userID, ok := ownerParam(c)
+	if !ok {
+		return
+	}
+
+	var request dto.CardRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	card := request.ToModel()
+	if err := cc.cardService.Add(c.Request.Context(), userID, &card); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, dto.NewCardResponse(&card, dto.ViewFor(currentUser(c), userID)))
+}
+
+func (cc *CardController) SetDefaultCard(c *gin.Context) {
+	userID, ok := ownerParam(c)
+	if !ok {
+		return
+	}
+	cardID, err := strconv.ParseUint(c.Param("cardId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid card ID"})
+		return
+	}
+
+	err = cc.cardService.SetDefault(c.Request.Context(), userID, cardID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Card not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (cc *CardController) RemoveCard(c *gin.Context) {
+	userID, ok := ownerParam(c)
+	if !ok {
+		return
+	}
+	cardID, err := strconv.ParseUint(c.Param("cardId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid card ID"})
+		return
+	}
+
+	err = cc.cardService.Remove(c.Request.Context(), userID, cardID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Card not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ownerParam parses the :id of the user whose cards or notes are addressed
+// and checks that the caller is that user or an admin. It writes the error response
+// itself when it returns false.
+func ownerParam(c *gin.Context) (uint64, bool) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return 0, false
+	}
+
+	user := currentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return 0, false
+	}
+	if user.ID != userID && user.Role != models.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this resource"})
+		return 0, false
+	}
+
+	return userID, true
+}
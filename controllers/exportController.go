@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"fmt"
+	"golang/dao"
+	"golang/dto"
+	"golang/export"
+	"golang/filter"
+	"golang/models"
+	"golang/pagination"
+	"golang/services"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ExportController struct {
+	exportService services.IExportService
+}
+
+func NewExportController(exportService services.IExportService) *ExportController {
+	return &ExportController{exportService: exportService}
+}
+
+// ExportUsers streams every user matching filter, sort and search, like
+// GET /users without the page limit.
+func (ec *ExportController) ExportUsers(c *gin.Context) {
+	format, page, ok := exportRequest(c, dto.UserFilterSchema(currentUser(c)))
+	if !ok {
+		return
+	}
+	mode, err := dao.ParseSearchMode(c.Query("search_mode"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	search := dao.Search{Term: c.Query("search"), Mode: mode}
+
+	writer := startExport[dto.UserExport](c, format, "users")
+	err = ec.exportService.StreamUsers(c.Request.Context(), page, search, func(users []models.User) error {
+		return writer.Write(dto.NewUserExports(users))
+	})
+	finishExport(c, writer, err)
+}
+
+// ExportNotes streams the notes of all users matching filter and sort.
+func (ec *ExportController) ExportNotes(c *gin.Context) {
+	format, page, ok := exportRequest(c, dto.NoteExportFilterSchema)
+	if !ok {
+		return
+	}
+
+	writer := startExport[dto.NoteExport](c, format, "notes")
+	err := ec.exportService.StreamNotes(c.Request.Context(), page, func(notes []models.Note) error {
+		return writer.Write(dto.NewNoteExports(notes))
+	})
+	finishExport(c, writer, err)
+}
+
+func exportRequest(c *gin.Context, schema filter.Schema) (export.Format, pagination.Request, bool) {
+	format, err := export.ParseFormat(c.Query("format"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", pagination.Request{}, false
+	}
+	query, err := schema.Parse(c.Query("filter"), c.Query("sort"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", pagination.Request{}, false
+	}
+	return format, pagination.Request{Where: query.Where, Order: query.Order}, true
+}
+
+func startExport[T export.Record](c *gin.Context, format export.Format, name string) export.Writer[T] {
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, name, format))
+	return export.NewWriter[T](format, c.Writer)
+}
+
+// finishExport completes the file. Once part of it has been sent a failure
+// can no longer change the status, so it is logged and the file is left
+// incomplete rather than closed.
+func finishExport[T export.Record](c *gin.Context, writer export.Writer[T], err error) {
+	if err == nil {
+		err = writer.Close()
+		if err == nil {
+			return
+		}
+	}
+	if !c.Writer.Written() {
+		c.Header("Content-Type", "")
+		c.Header("Content-Disposition", "")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("Export of %s failed: %v", c.Request.URL.Path, err)
+	c.Abort()
+}
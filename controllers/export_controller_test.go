@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"golang/dao"
+	"golang/models"
+	"golang/pagination"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockExportService struct {
+	mock.Mock
+}
+
+func (m *MockExportService) StreamUsers(ctx context.Context, page pagination.Request, search dao.Search, fn func([]models.User) error) error {
+	args := m.Called(page, search)
+	for _, batch := range args.Get(0).([][]models.User) {
+		if err := fn(batch); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockExportService) StreamNotes(ctx context.Context, page pagination.Request, fn func([]models.Note) error) error {
+	args := m.Called(page)
+	for _, batch := range args.Get(0).([][]models.Note) {
+		if err := fn(batch); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+func TestExportController(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+
+	mockService := new(MockExportService)
+	controller := NewExportController(mockService)
+	admin := &models.User{ID: 1, Role: models.RoleAdmin}
+	r.Use(func(c *gin.Context) { c.Set("currentUser", *admin) })
+	r.GET("/admin/export/users", controller.ExportUsers)
+	r.GET("/admin/export/notes", controller.ExportNotes)
+
+	get := func(url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("Users As CSV Without Secrets", func(t *testing.T) {
+		users := [][]models.User{
+			{{ID: 1, Username: "alice", Password: "secret", Role: models.RoleAdmin, Version: 2,
+				CreditCards: []models.CreditCard{{Number: "4242424242424242"}}}},
+			{{ID: 2, Username: "bob", Password: "hunter2", Role: models.RoleUser, Version: 1}},
+		}
+		users[0][0].CreatedAt, users[0][0].UpdatedAt = created, created
+		users[1][0].CreatedAt, users[1][0].UpdatedAt = created, created
+		mockService.On("StreamUsers", mock.MatchedBy(func(page pagination.Request) bool {
+			return page.Where != nil && len(page.Order) == 1
+		}), dao.Search{Term: "a", Mode: dao.SearchContains}).Return(users, nil).Once()
+
+		w := get("/admin/export/users?filter=role+eq+RoleAdmin&sort=-username&search=a")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="users.csv"`, w.Header().Get("Content-Disposition"))
+		assert.Equal(t, "id,username,role,version,created_at,updated_at\n"+
+			"1,alice,RoleAdmin,2,2024-05-01T12:00:00Z,2024-05-01T12:00:00Z\n"+
+			"2,bob,RoleUser,1,2024-05-01T12:00:00Z,2024-05-01T12:00:00Z\n", w.Body.String())
+		assert.NotContains(t, w.Body.String(), "secret")
+		assert.NotContains(t, w.Body.String(), "4242")
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Notes As JSON Lines", func(t *testing.T) {
+		notes := [][]models.Note{{{ID: 3, UserID: 2, Name: "todo", Content: "milk", Version: 1}}}
+		notes[0][0].CreatedAt, notes[0][0].UpdatedAt = created, created
+		mockService.On("StreamNotes", mock.MatchedBy(func(page pagination.Request) bool {
+			return page.Where != nil
+		})).Return(notes, nil).Once()
+
+		w := get("/admin/export/notes?format=jsonl&filter=user_id+eq+2")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+		assert.JSONEq(t, `{"id":3,"user_id":2,"name":"todo","content":"milk","version":1,`+
+			`"created_at":"2024-05-01T12:00:00Z","updated_at":"2024-05-01T12:00:00Z"}`, w.Body.String())
+	})
+
+	t.Run("Bad Request", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, get("/admin/export/users?format=xlsx").Code)
+		assert.Equal(t, http.StatusBadRequest, get("/admin/export/users?filter=password+eq+x").Code)
+		assert.Equal(t, http.StatusBadRequest, get("/admin/export/users?search_mode=regex").Code)
+	})
+
+	t.Run("Failure Before Any Row", func(t *testing.T) {
+		mockService.On("StreamNotes", mock.Anything).Return([][]models.Note{}, errors.New("database is down")).Once()
+
+		w := get("/admin/export/notes?format=parquet")
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Empty(t, w.Header().Get("Content-Disposition"))
+		assert.Contains(t, w.Body.String(), "database is down")
+	})
+}
@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"golang/health"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthController struct {
+	checker *health.Checker
+}
+
+func NewHealthController(checker *health.Checker) *HealthController {
+	return &HealthController{checker: checker}
+}
+
+// Live reports that the process serves requests. It checks no dependency,
+// so an unreachable database does not get the process restarted.
+func (hc *HealthController) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": health.StatusOK})
+}
+
+// Ready reports each dependency with its latency, and 503 Service
+// Unavailable when the server should not take traffic.
+func (hc *HealthController) Ready(c *gin.Context) {
+	report := hc.checker.Ready(c.Request.Context())
+	status := http.StatusOK
+	if !report.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"errors"
+	"golang/services"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxImportBytes = 32 << 20
+
+type ImportController struct {
+	importService services.IUserImportService
+	jobs          *services.ImportJobs
+	// AsyncThreshold is the number of rows above which an import runs in the
+	// background instead of within the request.
+	AsyncThreshold int
+}
+
+func NewImportController(importService services.IUserImportService, jobs *services.ImportJobs) *ImportController {
+	return &ImportController{importService: importService, jobs: jobs, AsyncThreshold: 1000}
+}
+
+// ImportUsers creates or updates users from a CSV or JSON Lines body.
+// dry_run=true only validates and reports what would change. Large imports,
+// or any import with async=true, answer 202 with a job to poll.
+func (ic *ImportController) ImportUsers(c *gin.Context) {
+	format, ok := importFormat(c)
+	if !ok {
+		c.Header("Accept", "text/csv, application/x-ndjson")
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Body must be text/csv or application/x-ndjson"})
+		return
+	}
+	dryRun := c.Query("dry_run") == "true"
+	async := c.Query("async") == "true"
+
+	rows, err := services.ParseImport(format, http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Import file is too large"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !dryRun && (async || len(rows) > ic.AsyncThreshold) {
+		if rowErrors := ic.importService.Validate(rows); len(rowErrors) > 0 {
+			c.JSON(http.StatusUnprocessableEntity, services.ImportReport{Rows: len(rows), Errors: rowErrors})
+			return
+		}
+		job, err := ic.jobs.Start(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Header("Location", "/admin/users/import/"+job.ID)
+		c.JSON(http.StatusAccepted, job)
+		return
+	}
+
+	report, err := ic.importService.Run(c.Request.Context(), rows, dryRun, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "report": report})
+		return
+	}
+	if !dryRun && len(report.Errors) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, report)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+func (ic *ImportController) GetImportJob(c *gin.Context) {
+	job, ok := ic.jobs.Get(c.Param("jobId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import job not found"})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// importFormat picks the format from ?format=, falling back to the
+// Content-Type.
+func importFormat(c *gin.Context) (services.ImportFormat, bool) {
+	switch c.Query("format") {
+	case "csv":
+		return services.ImportCSV, true
+	case "jsonl":
+		return services.ImportJSONLines, true
+	case "":
+	default:
+		return "", false
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return services.ImportCSV, true
+	case "application/x-ndjson", "application/jsonl", "application/x-jsonlines":
+		return services.ImportJSONLines, true
+	}
+	return "", false
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"golang/services"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockUserImportService struct {
+	mock.Mock
+}
+
+func (m *MockUserImportService) Validate(rows []services.ImportRow) []services.RowError {
+	args := m.Called(rows)
+	errs, _ := args.Get(0).([]services.RowError)
+	return errs
+}
+
+func (m *MockUserImportService) Run(ctx context.Context, rows []services.ImportRow, dryRun bool, progress func(int)) (*services.ImportReport, error) {
+	args := m.Called(rows, dryRun)
+	if progress != nil {
+		progress(len(rows))
+	}
+	report, _ := args.Get(0).(*services.ImportReport)
+	return report, args.Error(1)
+}
+
+func TestImportController_ImportUsers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+
+	mockService := new(MockUserImportService)
+	jobs := services.NewImportJobs(mockService)
+	controller := NewImportController(mockService, jobs)
+	controller.AsyncThreshold = 2
+
+	r.POST("/admin/users/import", controller.ImportUsers)
+	r.GET("/admin/users/import/:jobId", controller.GetImportJob)
+
+	post := func(query string, contentType string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/admin/users/import"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	csvRows := []services.ImportRow{{Line: 2, Username: "alice"}}
+
+	t.Run("Dry Run", func(t *testing.T) {
+		mockService.On("Run", csvRows, true).Return(&services.ImportReport{DryRun: true, Rows: 1, Created: 1, Invited: 1}, nil).Once()
+
+		w := post("?dry_run=true", "text/csv; charset=utf-8", "username\nalice\n")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"dry_run":true,"rows":1,"created":1,"updated":0,"invited":1}`, w.Body.String())
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Invalid Rows", func(t *testing.T) {
+		report := &services.ImportReport{Rows: 1, Errors: []services.RowError{{Line: 1, Error: "username is required"}}}
+		mockService.On("Run", []services.ImportRow{{Line: 1}}, false).Return(report, nil).Once()
+
+		w := post("", "application/x-ndjson", `{"username":""}`)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Contains(t, w.Body.String(), "username is required")
+	})
+
+	t.Run("Large Import Runs As Job", func(t *testing.T) {
+		rows := []services.ImportRow{{Line: 2, Username: "a"}, {Line: 3, Username: "b"}, {Line: 4, Username: "c"}}
+		mockService.On("Validate", rows).Return(nil).Once()
+		mockService.On("Run", rows, false).Return(&services.ImportReport{Rows: 3, Created: 3}, nil).Once()
+
+		w := post("", "text/csv", "username\na\nb\nc\n")
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		var job services.ImportJob
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+		assert.Equal(t, "/admin/users/import/"+job.ID, w.Header().Get("Location"))
+
+		jobs.Wait()
+		req, _ := http.NewRequest("GET", w.Header().Get("Location"), nil)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+		assert.Equal(t, services.JobSucceeded, job.Status)
+		assert.Equal(t, 3, job.Processed)
+		assert.Equal(t, 3, job.Report.Created)
+	})
+
+	t.Run("Unsupported Media Type", func(t *testing.T) {
+		w := post("", "application/json", `[]`)
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	})
+
+	t.Run("Malformed File", func(t *testing.T) {
+		w := post("?format=csv", "application/octet-stream", "name\nalice\n")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Unknown Job", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/admin/users/import/nope", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
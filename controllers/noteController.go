@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"golang/dto"
+	"golang/pagination"
+	"golang/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NoteController struct {
+	noteService services.INoteService
+	// RequireIfMatch rejects updates and deletes that do not name the version
+	// they were based on with 428 Precondition Required.
+	RequireIfMatch bool
+}
+
+func NewNoteController(noteService services.INoteService) *NoteController {
+	return &NoteController{noteService: noteService}
+}
+
+func (nc *NoteController) ListNotes(c *gin.Context) {
+	userID, ok := ownerParam(c)
+	if !ok {
+		return
+	}
+
+	page, ok := pageRequest(c, dto.NoteFilterSchema)
+	if !ok {
+		return
+	}
+
+	notes, err := nc.noteService.List(c.Request.Context(), userID, page)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setPageLinks(c, page, notes.NextCursor)
+	c.JSON(http.StatusOK, pagination.Map(notes, dto.NewNoteResponses))
+}
+
+func (nc *NoteController) CreateNote(c *gin.Context) {
+	userID, ok := ownerParam(c)
+	if !ok {
+		return
+	}
+
+	var request dto.NoteRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	note := request.ToModel()
+	if err := nc.noteService.Create(c.Request.Context(), userID, &note); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("ETag", entityTag(note.Version))
+	c.JSON(http.StatusCreated, dto.NewNoteResponse(&note))
+}
+
+func (nc *NoteController) GetNote(c *gin.Context) {
+	userID, noteID, ok := noteParams(c)
+	if !ok {
+		return
+	}
+
+	note, err := nc.noteService.Get(c.Request.Context(), userID, noteID)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": "Note not found"})
+		return
+	}
+	if notModified(c, note.Version) {
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewNoteResponse(note))
+}
+
+func (nc *NoteController) UpdateNote(c *gin.Context) {
+	userID, noteID, ok := noteParams(c)
+	if !ok {
+		return
+	}
+	version, ok := ifMatch(c, nc.RequireIfMatch)
+	if !ok {
+		return
+	}
+
+	var request dto.NoteRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	note := request.ToModel()
+	note.ID = noteID
+	note.Version = version
+	if err := nc.noteService.Update(c.Request.Context(), userID, &note); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("ETag", entityTag(note.Version))
+	c.JSON(http.StatusOK, dto.NewNoteResponse(&note))
+}
+
+func (nc *NoteController) DeleteNote(c *gin.Context) {
+	userID, noteID, ok := noteParams(c)
+	if !ok {
+		return
+	}
+	version, ok := ifMatch(c, nc.RequireIfMatch)
+	if !ok {
+		return
+	}
+
+	if err := nc.noteService.Delete(c.Request.Context(), userID, noteID, version); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func noteParams(c *gin.Context) (uint64, uint64, bool) {
+	userID, ok := ownerParam(c)
+	if !ok {
+		return 0, 0, false
+	}
+	noteID, err := strconv.ParseUint(c.Param("noteId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid note ID"})
+		return 0, 0, false
+	}
+	return userID, noteID, true
+}
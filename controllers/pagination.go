@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"fmt"
+	"golang/filter"
+	"golang/pagination"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pageRequest reads the listing position, filter and sort from the query.
+// Clients either follow cursor from a previous page or use the older
+// page/offset style; limit (or pageSize) is bounded to pagination.MaxLimit.
+// filter and sort are validated against schema. It writes the 400 response
+// itself when it returns false.
+func pageRequest(c *gin.Context, schema filter.Schema) (pagination.Request, bool) {
+	var request pagination.Request
+
+	query, err := schema.Parse(c.Query("filter"), c.Query("sort"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return request, false
+	}
+	request.Where = query.Where
+	request.Order = query.Order
+
+	limit := c.Query("limit")
+	if limit == "" {
+		limit = c.Query("pageSize")
+	}
+	if limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return request, false
+		}
+		request.Limit = value
+	}
+	request = request.Normalize()
+
+	if value := c.Query("cursor"); value != "" {
+		cursor, err := pagination.DecodeCursor(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return request, false
+		}
+		request.After = cursor
+		if _, err := request.AfterKeys(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return request, false
+		}
+	} else if value := c.Query("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset"})
+			return request, false
+		}
+		request.Offset = offset
+	} else if value := c.Query("page"); value != "" {
+		page, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page"})
+			return request, false
+		}
+		if page < 1 {
+			page = 1
+		}
+		request.Offset = (page - 1) * request.Limit
+	}
+
+	request.WithTotal = c.Query("include_total") == "true"
+	return request, true
+}
+
+// setPageLinks advertises the first, previous and next pages in an RFC 8288
+// Link header. Cursor pages only link forward; clients paging by page or
+// offset get links in the style they used.
+func setPageLinks(c *gin.Context, request pagination.Request, nextCursor string) {
+	base := c.Request.URL.Query()
+	base.Del("cursor")
+	base.Del("page")
+	base.Del("offset")
+	link := func(rel string, key string, value string) string {
+		values := url.Values{}
+		for k, v := range base {
+			values[k] = v
+		}
+		if key != "" {
+			values.Set(key, value)
+		}
+		target := c.Request.URL.Path
+		if encoded := values.Encode(); encoded != "" {
+			target += "?" + encoded
+		}
+		return fmt.Sprintf(`<%s>; rel="%s"`, target, rel)
+	}
+
+	positionKey := ""
+	position := strconv.Itoa
+	switch {
+	case request.After != nil:
+	case c.Query("offset") != "":
+		positionKey = "offset"
+	case c.Query("page") != "":
+		positionKey = "page"
+		position = func(offset int) string { return strconv.Itoa(offset/request.Limit + 1) }
+	}
+
+	links := []string{link("first", "", "")}
+	if positionKey != "" && request.Offset > 0 {
+		previous := request.Offset - request.Limit
+		if previous < 0 {
+			previous = 0
+		}
+		links = append(links, link("prev", positionKey, position(previous)))
+	}
+	if nextCursor != "" {
+		if positionKey != "" {
+			links = append(links, link("next", positionKey, position(request.Offset+request.Limit)))
+		} else {
+			links = append(links, link("next", "cursor", nextCursor))
+		}
+	}
+	c.Header("Link", strings.Join(links, ", "))
+}
@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"golang/dao"
+	"golang/models"
+	"golang/payments"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PaymentController struct {
+	chargeService payments.IChargeService
+}
+
+func NewPaymentController(chargeService payments.IChargeService) *PaymentController {
+	return &PaymentController{chargeService: chargeService}
+}
+
+type amountRequest struct {
+	Amount int64 `json:"amount"`
+}
+
+func (pc *PaymentController) CreateCharge(c *gin.Context) {
+	var request payments.ChargeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := currentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	charge, err := pc.chargeService.Authorize(c.Request.Context(), user.ID, request, c.GetHeader("Idempotency-Key"))
+	if err != nil {
+		respondPaymentError(c, charge, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, charge)
+}
+
+func (pc *PaymentController) GetCharge(c *gin.Context) {
+	chargeID, ok := chargeIDParam(c)
+	if !ok {
+		return
+	}
+
+	charge, transactions, err := pc.chargeService.Get(c.Request.Context(), chargeID)
+	if err != nil {
+		respondPaymentError(c, nil, err)
+		return
+	}
+
+	user := currentUser(c)
+	if user == nil || (charge.UserID != user.ID && user.Role != models.RoleAdmin) {
+		c.JSON(http.StatusNotFound, gin.H{"error": payments.ErrChargeNotFound.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"charge": charge, "transactions": transactions})
+}
+
+func (pc *PaymentController) CaptureCharge(c *gin.Context) {
+	pc.amountOperation(c, pc.chargeService.Capture)
+}
+
+func (pc *PaymentController) RefundCharge(c *gin.Context) {
+	pc.amountOperation(c, pc.chargeService.Refund)
+}
+
+func (pc *PaymentController) VoidCharge(c *gin.Context) {
+	chargeID, ok := chargeIDParam(c)
+	if !ok {
+		return
+	}
+
+	charge, err := pc.chargeService.Void(c.Request.Context(), chargeID, c.GetHeader("Idempotency-Key"))
+	if err != nil {
+		respondPaymentError(c, charge, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, charge)
+}
+
+// Webhook ingests asynchronous status updates from a gateway. It is not behind
+// RequireAuth; the gateway verifies the payload signature instead.
+func (pc *PaymentController) Webhook(c *gin.Context) {
+	payload, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read body"})
+		return
+	}
+
+	err = pc.chargeService.HandleWebhook(c.Request.Context(), c.Param("gateway"), c.Request.Header, payload)
+	if errors.Is(err, payments.ErrInvalidSignature) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		respondPaymentError(c, nil, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (pc *PaymentController) amountOperation(c *gin.Context, operation func(context.Context, uint64, int64, string) (*models.Charge, error)) {
+	chargeID, ok := chargeIDParam(c)
+	if !ok {
+		return
+	}
+
+	var request amountRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	charge, err := operation(c.Request.Context(), chargeID, request.Amount, c.GetHeader("Idempotency-Key"))
+	if err != nil {
+		respondPaymentError(c, charge, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, charge)
+}
+
+func chargeIDParam(c *gin.Context) (uint64, bool) {
+	chargeID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return 0, false
+	}
+	return chargeID, true
+}
+
+func respondPaymentError(c *gin.Context, charge *models.Charge, err error) {
+	var declined *payments.DeclinedError
+	var invalidTransition *payments.InvalidTransitionError
+
+	switch {
+	case errors.As(err, &declined):
+		c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error(), "charge": charge})
+	case errors.As(err, &invalidTransition), errors.Is(err, dao.ErrStaleCharge):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, payments.ErrIdempotencyConflict):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, payments.ErrCardNotFound), errors.Is(err, payments.ErrChargeNotFound),
+		errors.Is(err, payments.ErrUnknownGateway):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, payments.ErrIdempotencyKeyRequired), errors.Is(err, payments.ErrInvalidAmount),
+		errors.Is(err, payments.ErrInvalidCurrency):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
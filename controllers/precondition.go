@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// entityTag formats a record version as a strong entity tag.
+func entityTag(version uint64) string {
+	return `"` + strconv.FormatUint(version, 10) + `"`
+}
+
+// notModified sets the ETag of the current version and answers 304 when the
+// client's If-None-Match already names it.
+func notModified(c *gin.Context, version uint64) bool {
+	tag := entityTag(version)
+	c.Header("ETag", tag)
+
+	for _, candidate := range strings.Split(c.GetHeader("If-None-Match"), ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == tag {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatch returns the version the client's If-Match header expects, or 0 when
+// the header is absent or "*". A missing header is answered with 428 when
+// required is set, and a tag that cannot name a single version with 412.
+func ifMatch(c *gin.Context, required bool) (uint64, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		if required {
+			c.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header is required"})
+			return 0, false
+		}
+		return 0, true
+	}
+	if header == "*" {
+		return 0, true
+	}
+
+	version, err := strconv.ParseUint(strings.Trim(header, `"`), 10, 64)
+	if err != nil || version == 0 || header != entityTag(version) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "If-Match does not match the current version"})
+		return 0, false
+	}
+	return version, true
+}
@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"golang/dao"
+	"golang/dto"
+	"golang/models"
+	"golang/services"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PrivacyController struct {
+	privacyService services.IPrivacyService
+}
+
+func NewPrivacyController(privacyService services.IPrivacyService) *PrivacyController {
+	return &PrivacyController{privacyService: privacyService}
+}
+
+// DataExport answers a data access request with a zip of one JSON file per
+// kind of data held about the user. Card numbers only appear masked.
+func (pc *PrivacyController) DataExport(c *gin.Context) {
+	userID, ok := ownerParam(c)
+	if !ok {
+		return
+	}
+
+	data, err := pc.privacyService.Export(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{"profile.json", dto.NewUserResponse(&data.User, dto.ViewSelf)},
+		{"notes.json", dto.NewNoteResponses(data.Notes)},
+		{"cards.json", dto.NewCardResponses(data.Cards, dto.ViewSelf)},
+		{"charges.json", data.Charges},
+		{"vault_access.json", dto.NewVaultAccessEntries(data.VaultAccess, userID)},
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="user-%d-data.zip"`, userID))
+	c.Status(http.StatusOK)
+
+	archive := zip.NewWriter(c.Writer)
+	for _, file := range files {
+		writer, err := archive.Create(file.name)
+		if err == nil {
+			encoder := json.NewEncoder(writer)
+			encoder.SetIndent("", "  ")
+			err = encoder.Encode(file.content)
+		}
+		if err != nil {
+			log.Printf("Data export of user %d failed: %v", userID, err)
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		log.Printf("Data export of user %d failed: %v", userID, err)
+	}
+}
+
+// Erase answers a deletion request. Users may erase themselves; only
+// administrators may choose mode delete, which also removes payment records.
+func (pc *PrivacyController) Erase(c *gin.Context) {
+	userID, ok := ownerParam(c)
+	if !ok {
+		return
+	}
+
+	var request dto.EraseRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	mode := dao.EraseMode(request.Mode)
+	if mode == dao.EraseDelete && currentUser(c).Role != models.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only administrators may delete payment records"})
+		return
+	}
+
+	if err := pc.privacyService.Erase(c.Request.Context(), userID, mode); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"golang/dao"
+	"golang/models"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+type MockPrivacyService struct {
+	mock.Mock
+}
+
+func (m *MockPrivacyService) Export(ctx context.Context, userID uint64) (*dao.SubjectData, error) {
+	args := m.Called(userID)
+	data, _ := args.Get(0).(*dao.SubjectData)
+	return data, args.Error(1)
+}
+
+func (m *MockPrivacyService) Erase(ctx context.Context, userID uint64, mode dao.EraseMode) error {
+	args := m.Called(userID, mode)
+	return args.Error(0)
+}
+
+func TestPrivacyController(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockPrivacyService)
+	controller := NewPrivacyController(mockService)
+	serve := func(current models.User, method string, url string, body string) *httptest.ResponseRecorder {
+		r := gin.New()
+		r.Use(func(c *gin.Context) { c.Set("currentUser", current) })
+		r.GET("/users/:id/data-export", controller.DataExport)
+		r.POST("/users/:id/erase", controller.Erase)
+
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	self := models.User{ID: 7, Role: models.RoleUser}
+	admin := models.User{ID: 1, Role: models.RoleAdmin}
+
+	t.Run("Data Export", func(t *testing.T) {
+		data := &dao.SubjectData{
+			User:  models.User{ID: 7, Username: "alice", Password: "secret"},
+			Notes: []models.Note{{ID: 1, Name: "todo"}},
+			Cards: []models.CreditCard{{Token: "tok_123", Brand: "visa", Last4: "4242"}},
+			VaultAccess: []models.VaultAuditEntry{
+				{Token: "tok_123", ActorID: 1, ClientIP: "10.0.0.1"},
+				{Token: "tok_x", ActorID: 7, ClientIP: "10.0.0.7"},
+			},
+		}
+		mockService.On("Export", uint64(7)).Return(data, nil).Once()
+
+		w := serve(self, "GET", "/users/7/data-export", "")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+		archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+		if !assert.NoError(t, err) {
+			return
+		}
+		contents := map[string]string{}
+		for _, file := range archive.File {
+			reader, err := file.Open()
+			assert.NoError(t, err)
+			content, _ := io.ReadAll(reader)
+			contents[file.Name] = string(content)
+		}
+		assert.Len(t, contents, 5)
+		assert.Contains(t, contents["profile.json"], `"username": "alice"`)
+		assert.NotContains(t, contents["profile.json"], "secret")
+		assert.Contains(t, contents["cards.json"], `"last4": "4242"`)
+		assert.NotContains(t, contents["cards.json"], "tok_123")
+		assert.NotContains(t, contents["vault_access.json"], "10.0.0.1")
+		assert.Contains(t, contents["vault_access.json"], "10.0.0.7")
+	})
+
+	t.Run("Data Export Of Someone Else", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, serve(self, "GET", "/users/8/data-export", "").Code)
+	})
+
+	t.Run("Data Export Not Found", func(t *testing.T) {
+		mockService.On("Export", uint64(8)).Return(nil, gorm.ErrRecordNotFound).Once()
+		assert.Equal(t, http.StatusNotFound, serve(admin, "GET", "/users/8/data-export", "").Code)
+	})
+
+	t.Run("Erase Self", func(t *testing.T) {
+		mockService.On("Erase", uint64(7), dao.EraseMode("")).Return(nil).Once()
+		assert.Equal(t, http.StatusNoContent, serve(self, "POST", "/users/7/erase", "").Code)
+	})
+
+	t.Run("Only Admins Delete", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, serve(self, "POST", "/users/7/erase", `{"mode":"delete"}`).Code)
+
+		mockService.On("Erase", uint64(7), dao.EraseDelete).Return(nil).Once()
+		assert.Equal(t, http.StatusNoContent, serve(admin, "POST", "/users/7/erase", `{"mode":"delete"}`).Code)
+	})
+
+	t.Run("Invalid Mode", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, serve(admin, "POST", "/users/7/erase", `{"mode":"shred"}`).Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
@@ -1,35 +1,75 @@
 package controllers
 
 import (
+	"encoding/json"
+	"errors"
+	"golang/dao"
+	"golang/dto"
 	"golang/models"
+	"golang/pagination"
+	"golang/patch"
 	"golang/services"
+	"golang/vault"
+	"io"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type UserController struct {
 	userService services.IUserService
+	// RequireIfMatch rejects updates and deletes that do not name the version
+	// they were based on with 428 Precondition Required.
+	RequireIfMatch bool
 }
 
 func NewUserController(userService services.IUserService) *UserController {
 	return &UserController{userService: userService}
 }
 
+// CreateUser creates a user. Only administrators may choose its role.
 func (uc *UserController) CreateUser(c *gin.Context) {
-	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
+	var request dto.CreateUserRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := uc.userService.Create(&user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	current := currentUser(c)
+	if request.Role != nil && (current == nil || current.Role != models.RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only administrators may set roles"})
+		return
+	}
+
+	user := request.ToModel()
+	if err := uc.userService.Create(c.Request.Context(), &user); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("ETag", entityTag(user.Version))
+	c.JSON(http.StatusCreated, dto.NewUserResponse(&user, dto.ViewFor(current, user.ID)))
+}
+
+// Signup creates an account for an anonymous caller. New accounts always get
+// RoleUser.
+func (uc *UserController) Signup(c *gin.Context) {
+	var request dto.SignupRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := request.ToModel()
+	if err := uc.userService.Create(c.Request.Context(), &user); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, user)
+	c.Header("ETag", entityTag(user.Version))
+	c.JSON(http.StatusCreated, dto.NewUserResponse(&user, dto.ViewSelf))
 }
 
 func (uc *UserController) GetUserById(c *gin.Context) {
@@ -45,96 +85,245 @@ func (uc *UserController) GetUserById(c *gin.Context) {
 		return
 	}
 
-	user, err := uc.userService.GetByID(userId)
+	user, err := uc.userService.GetByID(c.Request.Context(), userId)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
+	if notModified(c, user.Version) {
+		return
+	}
 
-	c.JSON(http.StatusOK, user)
+	c.JSON(http.StatusOK, dto.NewUserResponse(user, dto.ViewFor(currentUser(c), user.ID)))
 }
 
-// func (uc *UserController) GetAllUsers(c *gin.Context) {
-// 	users, err := uc.userService.GetAll()
-// 	if err != nil {
-// 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-// 		return
-// 	}
-
-// 	c.JSON(http.StatusOK, users)
-// }
-
-// func (uc *UserController) GetAllUsers(c *gin.Context) {
-// 	page, _ := strconv.Atoi(c.Query("page"))
-// 	pageSize, _ := strconv.Atoi(c.Query("pageSize"))
-
-// 	users, err := uc.userService.GetAll(page, pageSize)
-// 	if err != nil {
-// 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-// 		return
-// 	}
-
-// 	c.JSON(http.StatusOK, users)
-// }
-
+// GetAllUsers lists users in the pagination envelope. See pageRequest for
+// the supported query parameters and dto.UserFilterSchema for the fields.
 func (uc *UserController) GetAllUsers(c *gin.Context) {
-	page, _ := strconv.Atoi(c.Query("page"))
-	pageSize, _ := strconv.Atoi(c.Query("pageSize"))
-	searchQuery := c.Query("search")
+	current := currentUser(c)
+	page, ok := pageRequest(c, dto.UserFilterSchema(current))
+	if !ok {
+		return
+	}
+	mode, err := dao.ParseSearchMode(c.Query("search_mode"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	search := dao.Search{Term: c.Query("search"), Mode: mode}
+	if c.Query("include_deleted") == "true" {
+		if current == nil || current.Role != models.RoleAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only administrators may list deleted users"})
+			return
+		}
+		page.IncludeDeleted = true
+	}
 
-	users, err := uc.userService.GetAll(page, pageSize, searchQuery)
+	users, err := uc.userService.GetAll(c.Request.Context(), page, search)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, users)
+	setPageLinks(c, page, users.NextCursor)
+	c.JSON(http.StatusOK, pagination.Map(users, func(users []models.User) []dto.UserResponse {
+		return dto.NewUserResponses(users, current)
+	}))
 }
 
+// UpdateUser replaces the caller's own user, or any user for an
+// administrator.
 func (uc *UserController) UpdateUser(c *gin.Context) {
-	id, err := c.Params.Get("id")
-	if err != true {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID" + id})
+	userId, ok := ownerParam(c)
+	if !ok {
 		return
 	}
+	current := currentUser(c)
 
-	// userId, er := strconv.ParseUint(id, 10, 64)
-	// if er != nil {
-	// 	c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID "})
-	// 	return
-	// }
+	version, ok := ifMatch(c, uc.RequireIfMatch)
+	if !ok {
+		return
+	}
 
-	var updatedUser models.User
-	if err := c.ShouldBindJSON(&updatedUser); err != nil {
+	var request dto.UpdateUserRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := uc.userService.Update(&updatedUser); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	role := current.Role
+	switch {
+	case request.Role != nil && current.Role != models.RoleAdmin:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only administrators may set roles"})
 		return
+	case request.Role == nil && userId != current.ID:
+		user, err := uc.userService.GetByID(c.Request.Context(), userId)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		role = user.Role
 	}
 
-	c.JSON(http.StatusOK, updatedUser)
+	updatedUser := request.ToModel(userId, role)
+	updatedUser.Version = version
+	if err := uc.userService.Update(c.Request.Context(), &updatedUser); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("ETag", entityTag(updatedUser.Version))
+	c.JSON(http.StatusOK, dto.NewUserResponse(&updatedUser, dto.ViewFor(current, updatedUser.ID)))
 }
 
-func (uc *UserController) DeleteUser(c *gin.Context) {
-	id, err := c.Params.Get("id")
-	if err != true {
+// PatchUser applies a JSON Merge Patch or JSON Patch to the stored user. Only
+// the fields the caller's view allows may change. The write is always guarded
+// by the version the patch was applied to, so a concurrent update makes it
+// fail instead of being silently merged.
+func (uc *UserController) PatchUser(c *gin.Context) {
+	userId, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
 		return
 	}
 
-	userId, errorr := strconv.ParseUint(id, 10, 64)
-	if errorr != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+	view := dto.ViewFor(currentUser(c), userId)
+	if view == dto.ViewPublic {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this resource"})
 		return
 	}
 
-	if err := uc.userService.Delete(userId); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	expected, ok := ifMatch(c, uc.RequireIfMatch)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read body"})
+		return
+	}
+
+	user, err := uc.userService.GetByID(c.Request.Context(), userId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if expected != 0 && expected != user.Version {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": dao.ErrVersionConflict.Error()})
+		return
+	}
+
+	original := dto.NewUserPatchDocument(user)
+	document, _ := json.Marshal(original)
+
+	var patched []byte
+	switch c.ContentType() {
+	case patch.MergePatchContentType:
+		patched, err = patch.MergePatch(document, body)
+	case patch.JSONPatchContentType:
+		var operations []patch.Operation
+		operations, err = patch.DecodeJSONPatch(body)
+		if err == nil {
+			patched, err = patch.ApplyJSONPatch(document, operations)
+		}
+	default:
+		c.Header("Accept-Patch", patch.MergePatchContentType+", "+patch.JSONPatchContentType)
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Unsupported patch format"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	fields, err := dto.ApplyUserPatch(original, patched, view)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err = uc.userService.Patch(c.Request.Context(), userId, user.Version, fields)
+	if errors.Is(err, dao.ErrVersionConflict) && expected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("ETag", entityTag(user.Version))
+	c.JSON(http.StatusOK, dto.NewUserResponse(user, view))
+}
+
+func (uc *UserController) DeleteUser(c *gin.Context) {
+	userId, ok := ownerParam(c)
+	if !ok {
+		return
+	}
+
+	version, ok := ifMatch(c, uc.RequireIfMatch)
+	if !ok {
+		return
+	}
+
+	if err := uc.userService.Delete(c.Request.Context(), userId, version); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusNoContent, nil)
 }
+
+// RestoreUser undeletes a user along with the notes and cards deleted with
+// it. Deleted users cannot log in, so only administrators get here.
+func (uc *UserController) RestoreUser(c *gin.Context) {
+	userId, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	user, err := uc.userService.Restore(c.Request.Context(), userId)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("ETag", entityTag(user.Version))
+	c.JSON(http.StatusOK, dto.NewUserResponse(user, dto.ViewFor(currentUser(c), user.ID)))
+}
+
+// errorStatus maps service errors caused by bad input to 400, missing
+// records to 404, failed version checks to 412, taken usernames to 409,
+// erased users to 410 and everything else to 500.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, vault.ErrInvalidCard), errors.Is(err, dao.ErrInvalidEraseMode), errors.Is(err, services.ErrPasswordTooLong):
+		return http.StatusBadRequest
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, dao.ErrVersionConflict):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, dao.ErrUsernameTaken):
+		return http.StatusConflict
+	case errors.Is(err, dao.ErrUserErased):
+		return http.StatusGone
+	}
+	return http.StatusInternalServerError
+}
+
+// currentUser returns the user RequireAuth stored on the context, or nil for
+// anonymous requests.
+func currentUser(c *gin.Context) *models.User {
+	value, ok := c.Get("currentUser")
+	if !ok {
+		return nil
+	}
+	user, ok := value.(models.User)
+	if !ok {
+		return nil
+	}
+	return &user
+}
@@ -2,16 +2,22 @@
 package controllers
 
 import (
+	"context"
 	"errors"
+	"golang/dao"
 	"golang/models"
+	"golang/pagination"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
 )
 
 // Mocking the IUserService interface
@@ -19,31 +25,45 @@ type MockUserService struct {
 	mock.Mock
 }
 
-func (m *MockUserService) Create(user *models.User) error {
+func (m *MockUserService) Create(ctx context.Context, user *models.User) error {
 	args := m.Called(user)
 	return args.Error(0)
 }
 
-func (m *MockUserService) GetByID(id uint64) (*models.User, error) {
+func (m *MockUserService) GetByID(ctx context.Context, id uint64) (*models.User, error) {
 	args := m.Called(id)
-	return args.Get(0).(*models.User), args.Error(1)
+	user, _ := args.Get(0).(*models.User)
+	return user, args.Error(1)
 }
 
-func (m *MockUserService) GetAll(page int, pageSize int, searchQuery string) ([]models.User, error) {
-	args := m.Called(page, pageSize, searchQuery)
-	return args.Get(0).([]models.User), args.Error(1)
+func (m *MockUserService) GetAll(ctx context.Context, page pagination.Request, search dao.Search) (*pagination.Page[models.User], error) {
+	args := m.Called(page, search)
+	users, _ := args.Get(0).(*pagination.Page[models.User])
+	return users, args.Error(1)
 }
 
-func (m *MockUserService) Update(user *models.User) error {
+func (m *MockUserService) Update(ctx context.Context, user *models.User) error {
 	args := m.Called(user)
 	return args.Error(0)
 }
 
-func (m *MockUserService) Delete(id uint64) error {
-	args := m.Called(id)
+func (m *MockUserService) Patch(ctx context.Context, id uint64, version uint64, fields map[string]interface{}) (*models.User, error) {
+	args := m.Called(id, version, fields)
+	user, _ := args.Get(0).(*models.User)
+	return user, args.Error(1)
+}
+
+func (m *MockUserService) Delete(ctx context.Context, id uint64, version uint64) error {
+	args := m.Called(id, version)
 	return args.Error(0)
 }
 
+func (m *MockUserService) Restore(ctx context.Context, id uint64) (*models.User, error) {
+	args := m.Called(id)
+	user, _ := args.Get(0).(*models.User)
+	return user, args.Error(1)
+}
+
 func TestUserController_CreateUser(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	r := gin.Default()
@@ -54,8 +74,8 @@ func TestUserController_CreateUser(t *testing.T) {
 	r.POST("/users", controller.CreateUser)
 
 	t.Run("Success", func(t *testing.T) {
-		user := &models.User{Username: "john", Password: "password"}
-		mockService.On("Create", user).Return(nil)
+		user := &models.User{Username: "john", Password: "password", Role: models.RoleUser}
+		mockService.On("Create", user).Return(nil).Once()
 
 		jsonStr := `{"Username":"john","Password":"password"}`
 		req, _ := http.NewRequest("POST", "/users", strings.NewReader(jsonStr))
@@ -64,6 +84,7 @@ func TestUserController_CreateUser(t *testing.T) {
 		r.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.NotContains(t, w.Body.String(), "password")
 		mockService.AssertExpectations(t)
 	})
 
@@ -77,8 +98,35 @@ func TestUserController_CreateUser(t *testing.T) {
 		assert.Equal(t, http.StatusBadRequest, w.Code)
 	})
 
+	t.Run("Role Requires Admin", func(t *testing.T) {
+		jsonStr := `{"username":"john","password":"password","role":0}`
+		req, _ := http.NewRequest("POST", "/users", strings.NewReader(jsonStr))
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Admin Sets Role", func(t *testing.T) {
+		r := gin.New()
+		r.Use(func(c *gin.Context) { c.Set("currentUser", models.User{ID: 9, Role: models.RoleAdmin}) })
+		r.POST("/users", controller.CreateUser)
+		user := &models.User{Username: "jane", Password: "password", Role: models.RoleAdmin}
+		mockService.On("Create", user).Return(nil).Once()
+
+		jsonStr := `{"username":"jane","password":"password","role":0}`
+		req, _ := http.NewRequest("POST", "/users", strings.NewReader(jsonStr))
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
 	t.Run("Internal Server Error", func(t *testing.T) {
-		user := &models.User{Username: "john", Password: "password"}
+		user := &models.User{Username: "john", Password: "password", Role: models.RoleUser}
 		mockService.On("Create", user).Return(errors.New("error creating user"))
 
 		jsonStr := `{"Username":"john","Password":"password"}`
@@ -111,6 +159,7 @@ func TestUserController_GetUserById(t *testing.T) {
 		r.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"id":1,"username":"john"}`, w.Body.String())
 		mockService.AssertExpectations(t)
 	})
 
@@ -143,25 +192,144 @@ func TestUserController_GetAllUsers(t *testing.T) {
 	controller := NewUserController(mockService)
 
 	r.GET("/users", controller.GetAllUsers)
+	noSearch := dao.Search{Mode: dao.SearchContains}
 
 	t.Run("Success", func(t *testing.T) {
-		users := []models.User{
+		users := &pagination.Page[models.User]{Items: []models.User{
 			{ID: 1, Username: "john", Password: "password"},
 			{ID: 2, Username: "jane", Password: "password"},
-		}
-		mockService.On("GetAll", 0, 10, "").Return(users, nil)
+		}}
+		mockService.On("GetAll", pagination.Request{Limit: 10}, noSearch).Return(users, nil).Once()
 
 		req, _ := http.NewRequest("GET", "/users?page=1&pageSize=10", nil)
 		w := httptest.NewRecorder()
 
 		r.ServeHTTP(w, req)
 
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"items":[{"id":1,"username":"john"},{"id":2,"username":"jane"}]}`, w.Body.String())
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Page Zero Is The First Page", func(t *testing.T) {
+		users := &pagination.Page[models.User]{Items: []models.User{}}
+		mockService.On("GetAll", pagination.Request{Limit: 10}, noSearch).Return(users, nil).Once()
+
+		req, _ := http.NewRequest("GET", "/users?page=0&pageSize=10", nil)
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Page Size Is Bounded", func(t *testing.T) {
+		users := &pagination.Page[models.User]{Items: []models.User{}}
+		mockService.On("GetAll", pagination.Request{Limit: pagination.MaxLimit, Offset: pagination.MaxLimit}, noSearch).Return(users, nil).Once()
+
+		req, _ := http.NewRequest("GET", "/users?page=2&pageSize=100000", nil)
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Cursor With Links", func(t *testing.T) {
+		after := pagination.Cursor{ID: 2}
+		next := pagination.Cursor{ID: 4}.Encode()
+		total := int64(6)
+		users := &pagination.Page[models.User]{
+			Items:      []models.User{{ID: 3, Username: "jim"}, {ID: 4, Username: "jill"}},
+			NextCursor: next,
+			Total:      &total,
+		}
+		mockService.On("GetAll", pagination.Request{Limit: 2, After: &after, WithTotal: true}, dao.Search{Term: "j", Mode: dao.SearchContains}).Return(users, nil).Once()
+
+		req, _ := http.NewRequest("GET", "/users?limit=2&search=j&include_total=true&cursor="+after.Encode(), nil)
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"items":[{"id":3,"username":"jim"},{"id":4,"username":"jill"}],"next_cursor":"`+next+`","total":6}`, w.Body.String())
+		assert.Equal(t, `</users?include_total=true&limit=2&search=j>; rel="first", `+
+			`</users?cursor=`+next+`&include_total=true&limit=2&search=j>; rel="next"`, w.Header().Get("Link"))
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Offset Links", func(t *testing.T) {
+		users := &pagination.Page[models.User]{Items: []models.User{{ID: 5}}, NextCursor: pagination.Cursor{ID: 5}.Encode()}
+		mockService.On("GetAll", pagination.Request{Limit: 1, Offset: 4}, noSearch).Return(users, nil).Once()
+
+		req, _ := http.NewRequest("GET", "/users?offset=4&limit=1", nil)
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, `</users?limit=1>; rel="first", </users?limit=1&offset=3>; rel="prev", </users?limit=1&offset=5>; rel="next"`,
+			w.Header().Get("Link"))
+	})
+
+	t.Run("Filter And Sort", func(t *testing.T) {
+		users := &pagination.Page[models.User]{Items: []models.User{}}
+		mockService.On("GetAll", mock.MatchedBy(func(page pagination.Request) bool {
+			return page.Where != nil && len(page.Order) == 1 && page.Order[0].Column == "username" && page.Order[0].Desc
+		}), noSearch).Return(users, nil).Once()
+
+		req, _ := http.NewRequest("GET", "/users?"+url.Values{"filter": {"username startswith jo"}, "sort": {"-username"}}.Encode(), nil)
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
 		assert.Equal(t, http.StatusOK, w.Code)
 		mockService.AssertExpectations(t)
 	})
 
+	t.Run("Filter On Field Hidden From Caller", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/users?"+url.Values{"filter": {"role eq RoleAdmin"}}.Encode(), nil)
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Fuzzy Search", func(t *testing.T) {
+		users := &pagination.Page[models.User]{Items: []models.User{}}
+		mockService.On("GetAll", pagination.Request{Limit: pagination.DefaultLimit}, dao.Search{Term: "jhon", Mode: dao.SearchFuzzy}).Return(users, nil).Once()
+
+		req, _ := http.NewRequest("GET", "/users?search=jhon&search_mode=fuzzy", nil)
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Invalid Search Mode", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/users?search=jhon&search_mode=regex", nil)
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Invalid Cursor", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/users?cursor=not-a-cursor", nil)
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
 	t.Run("Internal Server Error", func(t *testing.T) {
-		mockService.On("GetAll", 0, 10, "").Return(nil, errors.New("error fetching users"))
+		mockService.On("GetAll", pagination.Request{Limit: 10}, noSearch).Return(nil, errors.New("error fetching users"))
 
 		req, _ := http.NewRequest("GET", "/users?page=1&pageSize=10", nil)
 		w := httptest.NewRecorder()
@@ -179,11 +347,12 @@ func TestUserController_UpdateUser(t *testing.T) {
 	mockService := new(MockUserService)
 	controller := NewUserController(mockService)
 
+	r.Use(func(c *gin.Context) { c.Set("currentUser", models.User{ID: 1, Role: models.RoleUser}) })
 	r.PUT("/users/:id", controller.UpdateUser)
 
 	t.Run("Success", func(t *testing.T) {
-		user := &models.User{ID: 1, Username: "john", Password: "newpassword"}
-		mockService.On("Update", user).Return(nil)
+		user := &models.User{ID: 1, Username: "john", Password: "newpassword", Role: models.RoleUser}
+		mockService.On("Update", user).Return(nil).Once()
 
 		jsonStr := `{"ID":1,"Username":"john","Password":"newpassword"}`
 		req, _ := http.NewRequest("PUT", "/users/1", strings.NewReader(jsonStr))
@@ -205,8 +374,46 @@ func TestUserController_UpdateUser(t *testing.T) {
 		assert.Equal(t, http.StatusBadRequest, w.Code)
 	})
 
+	t.Run("Other User", func(t *testing.T) {
+		jsonStr := `{"username":"jane","password":"taken-over"}`
+		req, _ := http.NewRequest("PUT", "/users/2", strings.NewReader(jsonStr))
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Role Requires Admin", func(t *testing.T) {
+		jsonStr := `{"username":"john","password":"newpassword","role":0}`
+		req, _ := http.NewRequest("PUT", "/users/1", strings.NewReader(jsonStr))
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Admin Keeps The Role", func(t *testing.T) {
+		r := gin.New()
+		r.Use(func(c *gin.Context) { c.Set("currentUser", models.User{ID: 9, Role: models.RoleAdmin}) })
+		r.PUT("/users/:id", controller.UpdateUser)
+		mockService.On("GetByID", uint64(2)).Return(&models.User{ID: 2, Username: "jane", Role: models.RoleUser}, nil).Once()
+		user := &models.User{ID: 2, Username: "jane", Password: "reset", Role: models.RoleUser}
+		mockService.On("Update", user).Return(nil).Once()
+
+		jsonStr := `{"username":"jane","password":"reset"}`
+		req, _ := http.NewRequest("PUT", "/users/2", strings.NewReader(jsonStr))
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
 	t.Run("Internal Server Error", func(t *testing.T) {
-		user := &models.User{ID: 1, Username: "john", Password: "newpassword"}
+		user := &models.User{ID: 1, Username: "john", Password: "newpassword", Role: models.RoleUser}
 		mockService.On("Update", user).Return(errors.New("error updating user"))
 
 		jsonStr := `{"ID":1,"Username":"john","Password":"newpassword"}`
@@ -227,10 +434,11 @@ func TestUserController_DeleteUser(t *testing.T) {
 	mockService := new(MockUserService)
 	controller := NewUserController(mockService)
 
+	r.Use(func(c *gin.Context) { c.Set("currentUser", models.User{ID: 1, Role: models.RoleUser}) })
 	r.DELETE("/users/:id", controller.DeleteUser)
 
 	t.Run("Success", func(t *testing.T) {
-		mockService.On("Delete", uint64(1)).Return(nil)
+		mockService.On("Delete", uint64(1), uint64(0)).Return(nil).Once()
 
 		req, _ := http.NewRequest("DELETE", "/users/1", nil)
 		w := httptest.NewRecorder()
@@ -242,9 +450,9 @@ func TestUserController_DeleteUser(t *testing.T) {
 	})
 
 	t.Run("Internal Server Error", func(t *testing.T) {
-		mockService.On("Delete", uint64(999)).Return(errors.New("error deleting user"))
+		mockService.On("Delete", uint64(1), uint64(0)).Return(errors.New("error deleting user")).Once()
 
-		req, _ := http.NewRequest("DELETE", "/users/999", nil)
+		req, _ := http.NewRequest("DELETE", "/users/1", nil)
 		w := httptest.NewRecorder()
 
 		r.ServeHTTP(w, req)
@@ -252,6 +460,16 @@ func TestUserController_DeleteUser(t *testing.T) {
 		assert.Equal(t, http.StatusInternalServerError, w.Code)
 	})
 
+	t.Run("Other User", func(t *testing.T) {
+		req, _ := http.NewRequest("DELETE", "/users/2", nil)
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		mockService.AssertNotCalled(t, "Delete", uint64(2), mock.Anything)
+	})
+
 	t.Run("Invalid ID", func(t *testing.T) {
 		req, _ := http.NewRequest("DELETE", "/users/abc", nil)
 		w := httptest.NewRecorder()
@@ -261,3 +479,273 @@ func TestUserController_DeleteUser(t *testing.T) {
 		assert.Equal(t, http.StatusBadRequest, w.Code)
 	})
 }
+
+func TestUserController_Signup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+
+	mockService := new(MockUserService)
+	controller := NewUserController(mockService)
+
+	r.POST("/signup", controller.Signup)
+
+	t.Run("Role Is Always User", func(t *testing.T) {
+		user := &models.User{Username: "john", Password: "password", Role: models.RoleUser}
+		mockService.On("Create", user).Return(nil).Once()
+
+		jsonStr := `{"username":"john","password":"password","role":0}`
+		req, _ := http.NewRequest("POST", "/signup", strings.NewReader(jsonStr))
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.JSONEq(t, `{"id":0,"username":"john","role":"RoleUser"}`, w.Body.String())
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestUserController_PatchUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+
+	mockService := new(MockUserService)
+	controller := NewUserController(mockService)
+
+	current := models.User{ID: 1, Username: "john", Role: models.RoleUser}
+	r.PATCH("/users/:id", func(c *gin.Context) {
+		c.Set("currentUser", current)
+	}, controller.PatchUser)
+
+	stored := &models.User{ID: 1, Username: "john", Password: "password", Role: models.RoleUser, Version: 3}
+	mockService.On("GetByID", uint64(1)).Return(stored, nil)
+
+	patchRequest := func(contentType string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("PATCH", "/users/1", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Merge Patch", func(t *testing.T) {
+		patched := &models.User{ID: 1, Username: "johnny", Role: models.RoleUser}
+		mockService.On("Patch", uint64(1), uint64(3), map[string]interface{}{"username": "johnny"}).Return(patched, nil).Once()
+
+		w := patchRequest("application/merge-patch+json", `{"username":"johnny"}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"username":"johnny"`)
+	})
+
+	t.Run("JSON Patch", func(t *testing.T) {
+		mockService.On("Patch", uint64(1), uint64(3), map[string]interface{}{"password": "newpassword"}).Return(stored, nil).Once()
+
+		w := patchRequest("application/json-patch+json", `[{"op":"test","path":"/username","value":"john"},{"op":"add","path":"/password","value":"newpassword"}]`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "password")
+	})
+
+	t.Run("Field Not Allowed", func(t *testing.T) {
+		w := patchRequest("application/merge-patch+json", `{"role":0}`)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("Invalid Result", func(t *testing.T) {
+		w := patchRequest("application/json-patch+json", `[{"op":"remove","path":"/username"}]`)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("Failed Test Operation", func(t *testing.T) {
+		w := patchRequest("application/json-patch+json", `[{"op":"test","path":"/username","value":"jane"}]`)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("Stale If-Match", func(t *testing.T) {
+		req, _ := http.NewRequest("PATCH", "/users/1", strings.NewReader(`{"username":"johnny"}`))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		req.Header.Set("If-Match", `"2"`)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
+
+	t.Run("Unsupported Media Type", func(t *testing.T) {
+		w := patchRequest("application/json", `{"username":"johnny"}`)
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	})
+
+	t.Run("Other User", func(t *testing.T) {
+		req, _ := http.NewRequest("PATCH", "/users/2", strings.NewReader(`{"username":"x"}`))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
+
+func TestUserController_Preconditions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+
+	mockService := new(MockUserService)
+	controller := NewUserController(mockService)
+	controller.RequireIfMatch = true
+
+	r.Use(func(c *gin.Context) { c.Set("currentUser", models.User{ID: 1, Role: models.RoleUser}) })
+	r.GET("/users/:id", controller.GetUserById)
+	r.PUT("/users/:id", controller.UpdateUser)
+	r.DELETE("/users/:id", controller.DeleteUser)
+
+	mockService.On("GetByID", uint64(1)).Return(&models.User{ID: 1, Username: "john", Version: 2}, nil)
+
+	send := func(method string, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, "/users/1", strings.NewReader(body))
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("ETag On Read", func(t *testing.T) {
+		w := send("GET", "", nil)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+	})
+
+	t.Run("Not Modified", func(t *testing.T) {
+		w := send("GET", "", map[string]string{"If-None-Match": `"1", W/"2"`})
+
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.String())
+	})
+
+	t.Run("Modified Since", func(t *testing.T) {
+		w := send("GET", "", map[string]string{"If-None-Match": `"1"`})
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Update Without If-Match", func(t *testing.T) {
+		w := send("PUT", `{"username":"john","password":"secret"}`, nil)
+
+		assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+	})
+
+	t.Run("Update With Current Version", func(t *testing.T) {
+		user := &models.User{ID: 1, Username: "john", Password: "secret", Role: models.RoleUser, Version: 2}
+		mockService.On("Update", user).Run(func(args mock.Arguments) {
+			args.Get(0).(*models.User).Version = 3
+		}).Return(nil).Once()
+
+		w := send("PUT", `{"username":"john","password":"secret"}`, map[string]string{"If-Match": `"2"`})
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+	})
+
+	t.Run("Update With Stale Version", func(t *testing.T) {
+		user := &models.User{ID: 1, Username: "john", Password: "secret", Role: models.RoleUser, Version: 1}
+		mockService.On("Update", user).Return(dao.ErrVersionConflict).Once()
+
+		w := send("PUT", `{"username":"john","password":"secret"}`, map[string]string{"If-Match": `"1"`})
+
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
+
+	t.Run("Weak Tag Never Matches", func(t *testing.T) {
+		w := send("PUT", `{"username":"john","password":"secret"}`, map[string]string{"If-Match": `W/"2"`})
+
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
+
+	t.Run("Delete With Version", func(t *testing.T) {
+		mockService.On("Delete", uint64(1), uint64(2)).Return(nil).Once()
+
+		w := send("DELETE", "", map[string]string{"If-Match": `"2"`})
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	})
+
+	t.Run("Delete Any Version", func(t *testing.T) {
+		mockService.On("Delete", uint64(1), uint64(0)).Return(nil).Once()
+
+		w := send("DELETE", "", map[string]string{"If-Match": "*"})
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestUserController_DeletedUsers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockUserService)
+	controller := NewUserController(mockService)
+	serve := func(current models.User, method string, url string) *httptest.ResponseRecorder {
+		r := gin.New()
+		r.Use(func(c *gin.Context) { c.Set("currentUser", current) })
+		r.GET("/users", controller.GetAllUsers)
+		r.POST("/users/:id/restore", controller.RestoreUser)
+
+		req, _ := http.NewRequest(method, url, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	admin := models.User{ID: 1, Role: models.RoleAdmin}
+
+	t.Run("Admins List Deleted Users", func(t *testing.T) {
+		users := &pagination.Page[models.User]{Items: []models.User{{ID: 2, Username: "gone"}}}
+		users.Items[0].DeletedAt = gorm.DeletedAt{Time: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Valid: true}
+		mockService.On("GetAll", pagination.Request{Limit: pagination.DefaultLimit, IncludeDeleted: true},
+			dao.Search{Mode: dao.SearchContains}).Return(users, nil).Once()
+
+		w := serve(admin, "GET", "/users?include_deleted=true")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"deleted_at":"2024-01-02T00:00:00Z"`)
+	})
+
+	t.Run("Others May Not", func(t *testing.T) {
+		w := serve(models.User{ID: 2, Role: models.RoleUser}, "GET", "/users?include_deleted=true")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		mockService.On("Restore", uint64(2)).Return(&models.User{ID: 2, Username: "back", Version: 3}, nil).Once()
+
+		w := serve(admin, "POST", "/users/2/restore")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+		assert.Contains(t, w.Body.String(), `"username":"back"`)
+	})
+
+	t.Run("Restore Conflicts With A Live Username", func(t *testing.T) {
+		mockService.On("Restore", uint64(3)).Return(nil, dao.ErrUsernameTaken).Once()
+		assert.Equal(t, http.StatusConflict, serve(admin, "POST", "/users/3/restore").Code)
+	})
+
+	t.Run("Restore Unknown User", func(t *testing.T) {
+		mockService.On("Restore", uint64(4)).Return(nil, gorm.ErrRecordNotFound).Once()
+		assert.Equal(t, http.StatusNotFound, serve(admin, "POST", "/users/4/restore").Code)
+	})
+
+	t.Run("Erased User Stays Erased", func(t *testing.T) {
+		mockService.On("Restore", uint64(5)).Return(nil, dao.ErrUserErased).Once()
+		assert.Equal(t, http.StatusGone, serve(admin, "POST", "/users/5/restore").Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
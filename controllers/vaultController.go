@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"errors"
+	"golang/vault"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type VaultController struct {
+	cardVault vault.IVault
+}
+
+func NewVaultController(cardVault vault.IVault) *VaultController {
+	return &VaultController{cardVault: cardVault}
+}
+
+type detokenizeRequest struct {
+	Token  string `json:"token" binding:"required"`
+	Reason string `json:"reason" binding:"required"`
+}
+
+// Detokenize returns the full card number for a token. It must only be
+// mounted behind RequireAuth("RoleAdmin"); the vault audits every call.
+func (vc *VaultController) Detokenize(c *gin.Context) {
+	var request detokenizeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := currentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	number, err := vc.cardVault.Detokenize(c.Request.Context(), request.Token, vault.Access{
+		ActorID:  user.ID,
+		Reason:   request.Reason,
+		ClientIP: c.ClientIP(),
+	})
+	if errors.Is(err, vault.ErrTokenNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, vault.ErrReasonRequired) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"token": request.Token, "number": number})
+}
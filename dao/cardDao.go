@@ -0,0 +1,134 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"golang/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ICardDao interface {
+	ListByUser(ctx context.Context, userID uint64) ([]models.CreditCard, error)
+	Get(ctx context.Context, userID uint64, cardID uint64) (*models.CreditCard, error)
+	Create(ctx context.Context, card *models.CreditCard) error
+	SetDefault(ctx context.Context, userID uint64, cardID uint64) error
+	Delete(ctx context.Context, userID uint64, cardID uint64) error
+	FindExpiring(ctx context.Context, fromYear int, fromMonth int, toYear int, toMonth int) ([]models.CreditCard, error)
+	MarkReminderSent(ctx context.Context, cardID uint64, sentAt time.Time) error
+}
+
+type CardDao struct {
+	db *gorm.DB
+}
+
+func NewCardDao(db *gorm.DB) *CardDao {
+	return &CardDao{db: db}
+}
+
+func (c *CardDao) ListByUser(ctx context.Context, userID uint64) ([]models.CreditCard, error) {
+	var cards []models.CreditCard
+	err := c.db.WithContext(ctx).Where("user_id = ?", userID).Order("is_default DESC, id").Find(&cards).Error
+	return cards, err
+}
+
+func (c *CardDao) Get(ctx context.Context, userID uint64, cardID uint64) (*models.CreditCard, error) {
+	var card models.CreditCard
+	err := c.db.WithContext(ctx).Where("user_id = ?", userID).First(&card, cardID).Error
+	return &card, err
+}
+
+// Create adds a card to its user. The user's first card becomes the default,
+// and a new card flagged as default takes the flag over from the old one.
+func (c *CardDao) Create(ctx context.Context, card *models.CreditCard) error {
+	return c.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		err := tx.Model(&models.CreditCard{}).Where("user_id = ?", card.UserID).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			card.IsDefault = true
+		} else if card.IsDefault {
+			if err := clearDefault(tx, card.UserID); err != nil {
+				return err
+			}
+		}
+		return tx.Create(card).Error
+	})
+}
+
+// SetDefault makes cardID the user's default card. It returns
+// gorm.ErrRecordNotFound when the card does not belong to the user.
+func (c *CardDao) SetDefault(ctx context.Context, userID uint64, cardID uint64) error {
+	return c.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var card models.CreditCard
+		if err := tx.Where("user_id = ?", userID).First(&card, cardID).Error; err != nil {
+			return err
+		}
+		if err := clearDefault(tx, userID); err != nil {
+			return err
+		}
+		return tx.Model(&card).Update("is_default", true).Error
+	})
+}
+
+// Delete removes one of the user's cards. When the default card is removed
+// the most recently added remaining card becomes the default.
+func (c *CardDao) Delete(ctx context.Context, userID uint64, cardID uint64) error {
+	return c.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var card models.CreditCard
+		if err := tx.Where("user_id = ?", userID).First(&card, cardID).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&card).Error; err != nil {
+			return err
+		}
+		if !card.IsDefault {
+			return nil
+		}
+
+		var next models.CreditCard
+		err := tx.Where("user_id = ?", userID).Order("id DESC").First(&next).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return tx.Model(&next).Update("is_default", true).Error
+	})
+}
+
+// FindExpiring returns cards expiring between the two months (inclusive)
+// that have not had an expiry reminder yet.
+func (c *CardDao) FindExpiring(ctx context.Context, fromYear int, fromMonth int, toYear int, toMonth int) ([]models.CreditCard, error) {
+	var cards []models.CreditCard
+	err := c.db.WithContext(ctx).
+		Where("exp_year * 12 + exp_month BETWEEN ? AND ?", fromYear*12+fromMonth, toYear*12+toMonth).
+		Where("expiry_reminder_sent_at IS NULL").
+		Find(&cards).Error
+	return cards, err
+}
+
+func (c *CardDao) MarkReminderSent(ctx context.Context, cardID uint64, sentAt time.Time) error {
+	return c.db.WithContext(ctx).Model(&models.CreditCard{}).Where("id = ?", cardID).Update("expiry_reminder_sent_at", sentAt).Error
+}
+
+// EnsureDefaultCards flags the oldest card of every user that has cards but
+// no default one. Users from before multiple cards were supported had a
+// single card without the flag.
+func EnsureDefaultCards(db *gorm.DB) error {
+	withoutDefault := db.Model(&models.CreditCard{}).
+		Select("MIN(id)").
+		Group("user_id").
+		Having("SUM(CASE WHEN is_default THEN 1 ELSE 0 END) = 0")
+	return db.Model(&models.CreditCard{}).Where("id IN (?)", withoutDefault).Update("is_default", true).Error
+}
+
+func clearDefault(tx *gorm.DB, userID uint64) error {
+	return tx.Model(&models.CreditCard{}).
+		Where("user_id = ? AND is_default = ?", userID, true).
+		Update("is_default", false).Error
+}
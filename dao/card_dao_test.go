@@ -0,0 +1,97 @@
+package dao
+
+import (
+	"context"
+	"golang/models"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestCardDao(t *testing.T) {
+	db := SetupTestDB(t)
+	defer func() {
+		sqlDB, err := db.DB()
+		if err != nil {
+			log.Fatalf("Failed to get DB from GORM: %v", err)
+		}
+		sqlDB.Close()
+	}()
+
+	userDao := NewUserDao(db)
+	cardDao := NewCardDao(db)
+
+	user := &models.User{Username: "erin", Password: "password123"}
+	assert.NoError(t, userDao.Create(context.Background(), user))
+	other := &models.User{Username: "frank", Password: "password123"}
+	assert.NoError(t, userDao.Create(context.Background(), other))
+
+	first := &models.CreditCard{UserID: user.ID, Token: "tok_1", Last4: "1111", ExpMonth: 1, ExpYear: 2030}
+	second := &models.CreditCard{UserID: user.ID, Token: "tok_2", Last4: "0004", ExpMonth: 2, ExpYear: 2030}
+
+	defaultToken := func() string {
+		cards, err := cardDao.ListByUser(context.Background(), user.ID)
+		assert.NoError(t, err)
+		for _, card := range cards {
+			if card.IsDefault {
+				return card.Token
+			}
+		}
+		return ""
+	}
+
+	t.Run("First Card Becomes Default", func(t *testing.T) {
+		assert.NoError(t, cardDao.Create(context.Background(), first))
+		assert.NoError(t, cardDao.Create(context.Background(), second))
+
+		cards, err := cardDao.ListByUser(context.Background(), user.ID)
+		assert.NoError(t, err)
+		assert.Len(t, cards, 2)
+		assert.Equal(t, "tok_1", defaultToken())
+	})
+
+	t.Run("Set Default", func(t *testing.T) {
+		assert.NoError(t, cardDao.SetDefault(context.Background(), user.ID, uint64(second.ID)))
+		assert.Equal(t, "tok_2", defaultToken())
+	})
+
+	t.Run("Set Default On Another User's Card", func(t *testing.T) {
+		err := cardDao.SetDefault(context.Background(), other.ID, uint64(first.ID))
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	})
+
+	t.Run("Removing Default Promotes Remaining Card", func(t *testing.T) {
+		assert.NoError(t, cardDao.Delete(context.Background(), user.ID, uint64(second.ID)))
+		assert.Equal(t, "tok_1", defaultToken())
+
+		err := cardDao.Delete(context.Background(), other.ID, uint64(first.ID))
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	})
+
+	t.Run("Find Expiring", func(t *testing.T) {
+		cards, err := cardDao.FindExpiring(context.Background(), 2029, 12, 2030, 1)
+		assert.NoError(t, err)
+		assert.Len(t, cards, 1)
+
+		assert.NoError(t, cardDao.MarkReminderSent(context.Background(), uint64(first.ID), time.Now()))
+
+		cards, err = cardDao.FindExpiring(context.Background(), 2029, 12, 2030, 1)
+		assert.NoError(t, err)
+		assert.Len(t, cards, 0)
+	})
+
+	t.Run("Ensure Default Cards", func(t *testing.T) {
+		legacy := &models.CreditCard{UserID: other.ID, Token: "tok_legacy"}
+		assert.NoError(t, db.Create(legacy).Error)
+
+		assert.NoError(t, EnsureDefaultCards(db))
+
+		var card models.CreditCard
+		assert.NoError(t, db.First(&card, legacy.ID).Error)
+		assert.True(t, card.IsDefault)
+		assert.Equal(t, "tok_1", defaultToken())
+	})
+}
@@ -0,0 +1,123 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"golang/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrStaleCharge           = errors.New("charge was modified concurrently")
+	ErrDuplicateWebhookEvent = errors.New("webhook event was already recorded")
+)
+
+type IChargeDao interface {
+	Create(ctx context.Context, charge *models.Charge, entry *models.ChargeTransaction) error
+	GetByID(ctx context.Context, id uint64) (*models.Charge, error)
+	FindByIdempotencyKey(ctx context.Context, userID uint64, key string) (*models.Charge, error)
+	FindByReference(ctx context.Context, gateway string, reference string) (*models.Charge, error)
+	FindTransaction(ctx context.Context, chargeID uint64, idempotencyKey string) (*models.ChargeTransaction, error)
+	ListTransactions(ctx context.Context, chargeID uint64) ([]models.ChargeTransaction, error)
+	Apply(ctx context.Context, before models.Charge, charge *models.Charge, entry *models.ChargeTransaction, event *models.WebhookEvent) error
+	RecordWebhookEvent(ctx context.Context, event *models.WebhookEvent) (bool, error)
+}
+
+type ChargeDao struct {
+	db *gorm.DB
+}
+
+func NewChargeDao(db *gorm.DB) *ChargeDao {
+	return &ChargeDao{db: db}
+}
+
+// Create stores a new charge together with its first ledger entry.
+func (c *ChargeDao) Create(ctx context.Context, charge *models.Charge, entry *models.ChargeTransaction) error {
+	return c.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(charge).Error; err != nil {
+			return err
+		}
+		entry.ChargeID = uint64(charge.ID)
+		return tx.Create(entry).Error
+	})
+}
+
+func (c *ChargeDao) GetByID(ctx context.Context, id uint64) (*models.Charge, error) {
+	var charge models.Charge
+	err := c.db.WithContext(ctx).First(&charge, id).Error
+	return &charge, err
+}
+
+func (c *ChargeDao) FindByIdempotencyKey(ctx context.Context, userID uint64, key string) (*models.Charge, error) {
+	var charge models.Charge
+	err := c.db.WithContext(ctx).Where("user_id = ? AND idempotency_key = ?", userID, key).First(&charge).Error
+	return &charge, err
+}
+
+func (c *ChargeDao) FindByReference(ctx context.Context, gateway string, reference string) (*models.Charge, error) {
+	var charge models.Charge
+	err := c.db.WithContext(ctx).Where("gateway = ? AND gateway_reference = ?", gateway, reference).First(&charge).Error
+	return &charge, err
+}
+
+func (c *ChargeDao) FindTransaction(ctx context.Context, chargeID uint64, idempotencyKey string) (*models.ChargeTransaction, error) {
+	var entry models.ChargeTransaction
+	err := c.db.WithContext(ctx).Where("charge_id = ? AND idempotency_key = ?", chargeID, idempotencyKey).First(&entry).Error
+	return &entry, err
+}
+
+func (c *ChargeDao) ListTransactions(ctx context.Context, chargeID uint64) ([]models.ChargeTransaction, error) {
+	var entries []models.ChargeTransaction
+	err := c.db.WithContext(ctx).Where("charge_id = ?", chargeID).Order("id").Find(&entries).Error
+	return entries, err
+}
+
+// Apply saves a charge's new state and appends the ledger entry describing
+// the change. The update only succeeds if the stored charge still has the
+// status and amounts of before, so concurrent operations cannot both win,
+// including two partial refunds that keep the status.
+//
+// A non-nil event is the webhook that caused the change. It is recorded in
+// the same transaction, so a failed update leaves it unrecorded for the
+// gateway's redelivery; an event recorded before returns
+// ErrDuplicateWebhookEvent and changes nothing.
+func (c *ChargeDao) Apply(ctx context.Context, before models.Charge, charge *models.Charge, entry *models.ChargeTransaction, event *models.WebhookEvent) error {
+	return c.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if event != nil {
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(event)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrDuplicateWebhookEvent
+			}
+		}
+		result := tx.Model(&models.Charge{}).
+			Where("id = ? AND status = ? AND captured_amount = ? AND refunded_amount = ?",
+				charge.ID, before.Status, before.CapturedAmount, before.RefundedAmount).
+			Updates(map[string]interface{}{
+				"status":            charge.Status,
+				"gateway_reference": charge.GatewayReference,
+				"captured_amount":   charge.CapturedAmount,
+				"refunded_amount":   charge.RefundedAmount,
+				"failure_code":      charge.FailureCode,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrStaleCharge
+		}
+		entry.ChargeID = uint64(charge.ID)
+		return tx.Create(entry).Error
+	})
+}
+
+// RecordWebhookEvent stores a gateway event and reports whether it is new.
+// Redelivered events return false.
+func (c *ChargeDao) RecordWebhookEvent(ctx context.Context, event *models.WebhookEvent) (bool, error) {
+	result := c.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(event)
+	return result.RowsAffected == 1, result.Error
+}
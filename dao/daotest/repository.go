@@ -0,0 +1,87 @@
+// Package daotest holds test doubles for the dao package.
+package daotest
+
+import (
+	"context"
+	"golang/dao"
+	"golang/pagination"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockRepository is a testify mock of dao.IRepository. Expectations are set
+// without the context, and scopes are not passed on since functions cannot
+// be compared. Embed it to mock a DAO built on dao.Repository and add the
+// DAO's own methods.
+type MockRepository[T any] struct {
+	mock.Mock
+}
+
+var _ dao.IRepository[struct{}] = (*MockRepository[struct{}])(nil)
+
+func (m *MockRepository[T]) Create(ctx context.Context, entity *T) error {
+	args := m.Called(entity)
+	return args.Error(0)
+}
+
+func (m *MockRepository[T]) Get(ctx context.Context, id uint64, scopes ...dao.Scope[T]) (*T, error) {
+	args := m.Called(id)
+	entity, _ := args.Get(0).(*T)
+	return entity, args.Error(1)
+}
+
+func (m *MockRepository[T]) First(ctx context.Context, scopes ...dao.Scope[T]) (*T, error) {
+	args := m.Called()
+	entity, _ := args.Get(0).(*T)
+	return entity, args.Error(1)
+}
+
+func (m *MockRepository[T]) Find(ctx context.Context, scopes ...dao.Scope[T]) ([]T, error) {
+	args := m.Called()
+	entities, _ := args.Get(0).([]T)
+	return entities, args.Error(1)
+}
+
+func (m *MockRepository[T]) Count(ctx context.Context, scopes ...dao.Scope[T]) (int64, error) {
+	args := m.Called()
+	count, _ := args.Get(0).(int64)
+	return count, args.Error(1)
+}
+
+func (m *MockRepository[T]) Page(ctx context.Context, page pagination.Request, scopes ...dao.Scope[T]) (*pagination.Page[T], error) {
+	args := m.Called(page)
+	result, _ := args.Get(0).(*pagination.Page[T])
+	return result, args.Error(1)
+}
+
+// Stream hands the batches given as the first return value to fn.
+func (m *MockRepository[T]) Stream(ctx context.Context, page pagination.Request, fn func([]T) error, scopes ...dao.Scope[T]) error {
+	args := m.Called(page)
+	batches, _ := args.Get(0).([][]T)
+	for _, batch := range batches {
+		if err := fn(batch); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockRepository[T]) Update(ctx context.Context, entity *T) error {
+	args := m.Called(entity)
+	return args.Error(0)
+}
+
+func (m *MockRepository[T]) UpdateFields(ctx context.Context, id uint64, version uint64, fields map[string]interface{}) error {
+	args := m.Called(id, version, fields)
+	return args.Error(0)
+}
+
+func (m *MockRepository[T]) Delete(ctx context.Context, id uint64, version uint64) error {
+	args := m.Called(id, version)
+	return args.Error(0)
+}
+
+func (m *MockRepository[T]) Restore(ctx context.Context, id uint64) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
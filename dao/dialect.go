@@ -0,0 +1,164 @@
+package dao
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"unicode"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// similarityThreshold is the share of matching trigrams a fuzzy match needs.
+// It equals the pg_trgm default so both dialects agree on what matches.
+const similarityThreshold = 0.3
+
+type SearchMode string
+
+const (
+	SearchContains SearchMode = "contains"
+	SearchPrefix   SearchMode = "prefix"
+	SearchFuzzy    SearchMode = "fuzzy"
+)
+
+var ErrInvalidSearchMode = errors.New("search mode must be contains, prefix or fuzzy")
+
+func ParseSearchMode(value string) (SearchMode, error) {
+	switch mode := SearchMode(value); mode {
+	case "":
+		return SearchContains, nil
+	case SearchContains, SearchPrefix, SearchFuzzy:
+		return mode, nil
+	}
+	return "", ErrInvalidSearchMode
+}
+
+// Search is a case-insensitive text search. The term is matched literally;
+// % and _ in it are not wildcards.
+type Search struct {
+	Term string
+	Mode SearchMode
+}
+
+// Dialect builds the parts of a query that differ between databases.
+type Dialect interface {
+	// ILike matches column case-insensitively against a LIKE pattern whose
+	// literal %, _ and ! are escaped with !.
+	ILike(column clause.Column, pattern string) clause.Expression
+	// Similar matches column against term by trigram similarity.
+	Similar(column clause.Column, term string) clause.Expression
+}
+
+// DialectOf returns the dialect of the database db is connected to.
+func DialectOf(db *gorm.DB) Dialect {
+	if db.Dialector.Name() == "postgres" {
+		return postgresDialect{db: db}
+	}
+	return portableDialect{}
+}
+
+// Condition returns the WHERE condition for the search on column, or nil
+// when there is nothing to search for.
+func (s Search) Condition(dialect Dialect, column clause.Column) clause.Expression {
+	if s.Term == "" {
+		return nil
+	}
+	switch s.Mode {
+	case SearchPrefix:
+		return dialect.ILike(column, escapeLike(s.Term)+"%")
+	case SearchFuzzy:
+		return dialect.Similar(column, s.Term)
+	}
+	return dialect.ILike(column, "%"+escapeLike(s.Term)+"%")
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(value)
+}
+
+// postgresDialect uses ILIKE and the pg_trgm similarity operator, both of
+// which can use the trigram index of the username_trigram_index migration.
+// The migration skips the extension when the database user may not create
+// it; fuzzy matching then falls back to the portable query.
+type postgresDialect struct {
+	db *gorm.DB
+}
+
+func (postgresDialect) ILike(column clause.Column, pattern string) clause.Expression {
+	return clause.Expr{SQL: "? ILIKE ? ESCAPE '!'", Vars: []interface{}{column, pattern}}
+}
+
+func (d postgresDialect) Similar(column clause.Column, term string) clause.Expression {
+	if !hasTrigrams(d.db) {
+		return portableDialect{}.Similar(column, term)
+	}
+	return clause.Expr{SQL: "? % ?", Vars: []interface{}{column, term}}
+}
+
+// trigramSupport remembers per database whether pg_trgm is installed; the
+// key is the *gorm.Config every session of a database shares.
+var trigramSupport sync.Map
+
+func hasTrigrams(db *gorm.DB) bool {
+	if installed, ok := trigramSupport.Load(db.Config); ok {
+		return installed.(bool)
+	}
+	var installed bool
+	err := db.Session(&gorm.Session{NewDB: true}).
+		Raw("SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_trgm')").
+		Scan(&installed).Error
+	if err != nil {
+		// Ask again next time rather than remember a guess.
+		return false
+	}
+	trigramSupport.Store(db.Config, installed)
+	return installed
+}
+
+// portableDialect sticks to SQL every database understands. Fuzzy matching
+// counts how many of the term's trigrams occur in the column.
+type portableDialect struct{}
+
+func (portableDialect) ILike(column clause.Column, pattern string) clause.Expression {
+	return clause.Expr{SQL: "LOWER(?) LIKE ? ESCAPE '!'", Vars: []interface{}{column, strings.ToLower(pattern)}}
+}
+
+func (d portableDialect) Similar(column clause.Column, term string) clause.Expression {
+	grams := trigrams(term)
+	if len(grams) == 0 {
+		return d.ILike(column, "%"+escapeLike(term)+"%")
+	}
+
+	cases := make([]string, len(grams))
+	vars := make([]interface{}, 0, 2*len(grams)+1)
+	for i, gram := range grams {
+		cases[i] = "CASE WHEN LOWER(?) LIKE ? ESCAPE '!' THEN 1 ELSE 0 END"
+		vars = append(vars, column, "%"+escapeLike(gram)+"%")
+	}
+	required := int(similarityThreshold*float64(len(grams)) + 0.999)
+	vars = append(vars, required)
+	return clause.Expr{SQL: "(" + strings.Join(cases, " + ") + ") >= ?", Vars: vars}
+}
+
+// trigrams returns the distinct three letter sequences of every word in term,
+// lowercased. Like pg_trgm, anything that is not a letter or digit separates
+// words.
+func trigrams(term string) []string {
+	seen := map[string]bool{}
+	var grams []string
+	words := strings.FieldsFunc(strings.ToLower(term), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		runes := []rune(word)
+		for i := 0; i+3 <= len(runes); i++ {
+			gram := string(runes[i : i+3])
+			if !seen[gram] {
+				seen[gram] = true
+				grams = append(grams, gram)
+			}
+		}
+	}
+	return grams
+}
@@ -0,0 +1,78 @@
+package dao
+
+import (
+	"context"
+	"encoding/json"
+	"golang/idempotency"
+	"golang/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IdempotencyDao is the idempotency.Store shared by every instance of the
+// service. The primary key on the scoped key is the lock.
+type IdempotencyDao struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+func NewIdempotencyDao(db *gorm.DB) *IdempotencyDao {
+	return &IdempotencyDao{db: db, now: time.Now}
+}
+
+// Claim also purges every expired record, which keeps the table bounded by
+// the traffic of one TTL.
+func (d *IdempotencyDao) Claim(ctx context.Context, key string, fingerprint string, owner string, lockUntil time.Time) (*idempotency.Record, error) {
+	var existing *idempotency.Record
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at <= ?", d.now()).Delete(&models.IdempotencyRecord{}).Error; err != nil {
+			return err
+		}
+
+		claim := &models.IdempotencyRecord{Key: key, Fingerprint: fingerprint, Owner: owner, ExpiresAt: lockUntil}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(claim)
+		if result.Error != nil || result.RowsAffected == 1 {
+			return result.Error
+		}
+
+		var record models.IdempotencyRecord
+		if err := tx.First(&record, "idempotency_key = ?", key).Error; err != nil {
+			return err
+		}
+		existing = &idempotency.Record{Key: record.Key, Fingerprint: record.Fingerprint, Owner: record.Owner, ExpiresAt: record.ExpiresAt}
+		if record.Status != 0 {
+			response := &idempotency.Response{Status: record.Status, Body: record.Body}
+			if err := json.Unmarshal([]byte(record.Header), &response.Header); err != nil {
+				return err
+			}
+			existing.Response = response
+		}
+		return nil
+	})
+	return existing, err
+}
+
+func (d *IdempotencyDao) Complete(ctx context.Context, key string, owner string, response idempotency.Response, expiresAt time.Time) error {
+	header, err := json.Marshal(response.Header)
+	if err != nil {
+		return err
+	}
+
+	result := d.db.WithContext(ctx).Model(&models.IdempotencyRecord{}).
+		Where("idempotency_key = ? AND owner = ? AND status = 0", key, owner).
+		Updates(map[string]interface{}{"status": response.Status, "header": string(header), "body": response.Body, "expires_at": expiresAt})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return idempotency.ErrNotOwner
+	}
+	return nil
+}
+
+func (d *IdempotencyDao) Release(ctx context.Context, key string, owner string) error {
+	return d.db.WithContext(ctx).Where("idempotency_key = ? AND owner = ? AND status = 0", key, owner).
+		Delete(&models.IdempotencyRecord{}).Error
+}
@@ -0,0 +1,79 @@
+package dao
+
+import (
+	"context"
+	"golang/models"
+	"golang/pagination"
+
+	"gorm.io/gorm"
+)
+
+type INoteDao interface {
+	ListByUser(ctx context.Context, userID uint64, page pagination.Request) (*pagination.Page[models.Note], error)
+	Get(ctx context.Context, userID uint64, noteID uint64) (*models.Note, error)
+	Stream(ctx context.Context, page pagination.Request, fn func([]models.Note) error) error
+	Create(ctx context.Context, note *models.Note) error
+	Update(ctx context.Context, note *models.Note) error
+	Delete(ctx context.Context, userID uint64, noteID uint64, version uint64) error
+}
+
+type NoteDao struct {
+	db *gorm.DB
+}
+
+func NewNoteDao(db *gorm.DB) *NoteDao {
+	return &NoteDao{db: db}
+}
+
+func (n *NoteDao) ListByUser(ctx context.Context, userID uint64, page pagination.Request) (*pagination.Page[models.Note], error) {
+	query := n.db.WithContext(ctx).Where("user_id = ?", userID)
+	return paginate(query, page, func(note *models.Note) uint64 { return note.ID })
+}
+
+// Stream hands the notes of every user matching page's filter to fn in
+// batches, in page's order.
+func (n *NoteDao) Stream(ctx context.Context, page pagination.Request, fn func([]models.Note) error) error {
+	return stream(n.db.WithContext(ctx).Model(&models.Note{}), page, StreamBatchSize, func(note *models.Note) uint64 { return note.ID }, fn)
+}
+
+func (n *NoteDao) Get(ctx context.Context, userID uint64, noteID uint64) (*models.Note, error) {
+	var note models.Note
+	err := n.db.WithContext(ctx).Where("user_id = ?", userID).First(&note, noteID).Error
+	return &note, err
+}
+
+func (n *NoteDao) Create(ctx context.Context, note *models.Note) error {
+	return n.db.WithContext(ctx).Create(note).Error
+}
+
+// Update writes the note's name and content and reloads it. A non-zero
+// note.Version must still be the stored version, otherwise nothing is written
+// and ErrVersionConflict is returned.
+func (n *NoteDao) Update(ctx context.Context, note *models.Note) error {
+	db := n.db.WithContext(ctx)
+	query := db.Model(&models.Note{}).Where("id = ? AND user_id = ?", note.ID, note.UserID)
+	if note.Version != 0 {
+		query = query.Where("version = ?", note.Version)
+	}
+	result := query.Updates(map[string]interface{}{
+		"name":    note.Name,
+		"content": note.Content,
+		"version": gorm.Expr("version + 1"),
+	})
+	if err := versionedResult(db, &models.Note{}, result, "id = ? AND user_id = ?", note.ID, note.UserID); err != nil {
+		return err
+	}
+	return db.First(note, note.ID).Error
+}
+
+// Delete removes one of the user's notes. A non-zero version must match the
+// stored one.
+func (n *NoteDao) Delete(ctx context.Context, userID uint64, noteID uint64, version uint64) error {
+	db := n.db.WithContext(ctx)
+	query := db.Where("user_id = ?", userID)
+	if version != 0 {
+		query = query.Where("version = ?", version)
+	}
+	result := query.Delete(&models.Note{}, noteID)
+	return versionedResult(db, &models.Note{}, result, "id = ? AND user_id = ?", noteID, userID)
+}
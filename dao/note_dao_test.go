@@ -0,0 +1,262 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+	"golang/filter"
+	"golang/models"
+	"golang/pagination"
+	"log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestNoteDao(t *testing.T) {
+	db := SetupTestDB(t)
+	defer func() {
+		sqlDB, err := db.DB()
+		if err != nil {
+			log.Fatalf("Failed to get DB from GORM: %v", err)
+		}
+		sqlDB.Close()
+	}()
+
+	userDao := NewUserDao(db)
+	noteDao := NewNoteDao(db)
+
+	user := &models.User{Username: "gina", Password: "password123"}
+	assert.NoError(t, userDao.Create(context.Background(), user))
+	other := &models.User{Username: "hank", Password: "password123"}
+	assert.NoError(t, userDao.Create(context.Background(), other))
+
+	note := &models.Note{UserID: user.ID, Name: "todo", Content: "milk"}
+	assert.NoError(t, noteDao.Create(context.Background(), note))
+	assert.Equal(t, uint64(1), note.Version)
+
+	t.Run("List", func(t *testing.T) {
+		notes, err := noteDao.ListByUser(context.Background(), user.ID, pagination.Request{Limit: 10})
+		assert.NoError(t, err)
+		assert.Len(t, notes.Items, 1)
+
+		notes, err = noteDao.ListByUser(context.Background(), other.ID, pagination.Request{Limit: 10})
+		assert.NoError(t, err)
+		assert.Empty(t, notes.Items)
+	})
+
+	t.Run("Get Another User's Note", func(t *testing.T) {
+		_, err := noteDao.Get(context.Background(), other.ID, note.ID)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		update := &models.Note{ID: note.ID, UserID: user.ID, Name: "todo", Content: "milk, eggs", Version: 1}
+		assert.NoError(t, noteDao.Update(context.Background(), update))
+		assert.Equal(t, uint64(2), update.Version)
+		assert.Equal(t, "milk, eggs", update.Content)
+	})
+
+	t.Run("Concurrent Updates", func(t *testing.T) {
+		first := &models.Note{ID: note.ID, UserID: user.ID, Name: "first", Version: 2}
+		second := &models.Note{ID: note.ID, UserID: user.ID, Name: "second", Version: 2}
+		assert.NoError(t, noteDao.Update(context.Background(), first))
+		assert.ErrorIs(t, noteDao.Update(context.Background(), second), ErrVersionConflict)
+
+		stored, err := noteDao.Get(context.Background(), user.ID, note.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "first", stored.Name)
+	})
+
+	t.Run("Update Another User's Note", func(t *testing.T) {
+		update := &models.Note{ID: note.ID, UserID: other.ID, Name: "stolen"}
+		assert.ErrorIs(t, noteDao.Update(context.Background(), update), gorm.ErrRecordNotFound)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		assert.ErrorIs(t, noteDao.Delete(context.Background(), user.ID, note.ID, 1), ErrVersionConflict)
+		assert.NoError(t, noteDao.Delete(context.Background(), user.ID, note.ID, 3))
+
+		_, err := noteDao.Get(context.Background(), user.ID, note.ID)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	})
+}
+
+func TestNoteDao_ListByUserPages(t *testing.T) {
+	db := SetupTestDB(t)
+	defer func() {
+		sqlDB, err := db.DB()
+		if err != nil {
+			log.Fatalf("Failed to get DB from GORM: %v", err)
+		}
+		sqlDB.Close()
+	}()
+
+	noteDao := NewNoteDao(db)
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		assert.NoError(t, noteDao.Create(context.Background(), &models.Note{UserID: 1, Name: name}))
+	}
+	assert.NoError(t, noteDao.Create(context.Background(), &models.Note{UserID: 2, Name: "other"}))
+
+	t.Run("Cursor", func(t *testing.T) {
+		names := []string{}
+		request := pagination.Request{Limit: 2, WithTotal: true}
+		for pages := 0; ; pages++ {
+			page, err := noteDao.ListByUser(context.Background(), 1, request)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(5), *page.Total)
+			for _, note := range page.Items {
+				names = append(names, note.Name)
+			}
+			if page.NextCursor == "" {
+				assert.Equal(t, 2, pages)
+				break
+			}
+			request.After, err = pagination.DecodeCursor(page.NextCursor)
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, []string{"a", "b", "c", "d", "e"}, names)
+	})
+
+	t.Run("Offset", func(t *testing.T) {
+		page, err := noteDao.ListByUser(context.Background(), 1, pagination.Request{Limit: 2, Offset: 3})
+		assert.NoError(t, err)
+		assert.Len(t, page.Items, 2)
+		assert.Equal(t, "d", page.Items[0].Name)
+		assert.Empty(t, page.NextCursor)
+		assert.Nil(t, page.Total)
+	})
+}
+
+func TestNoteDao_ListByUserFilteredAndSorted(t *testing.T) {
+	db := SetupTestDB(t)
+	defer func() {
+		sqlDB, err := db.DB()
+		if err != nil {
+			log.Fatalf("Failed to get DB from GORM: %v", err)
+		}
+		sqlDB.Close()
+	}()
+
+	noteDao := NewNoteDao(db)
+	for _, name := range []string{"b", "a", "b", "c", "a", "d"} {
+		assert.NoError(t, noteDao.Create(context.Background(), &models.Note{UserID: 1, Name: name, Content: "note " + name}))
+	}
+
+	schema := filter.Schema{
+		"name":       {Column: "name", Type: filter.String, Operators: filter.Text, Sortable: true},
+		"created_at": {Column: "created_at", Type: filter.Time, Operators: filter.Ordered, Sortable: true},
+	}
+	query, err := schema.Parse("name ne d and created_at gt 2000-01-01", "-name")
+	assert.NoError(t, err)
+
+	// Pages of two walk the ties in id order and never repeat or skip a note.
+	var seen []string
+	request := pagination.Request{Limit: 2, Where: query.Where, Order: query.Order, WithTotal: true}
+	for {
+		page, err := noteDao.ListByUser(context.Background(), 1, request)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, int64(5), *page.Total)
+		for _, note := range page.Items {
+			seen = append(seen, fmt.Sprintf("%s%d", note.Name, note.ID))
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		request.After, err = pagination.DecodeCursor(page.NextCursor)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []string{"c4", "b1", "b3", "a2", "a5"}, seen)
+
+	t.Run("Sorted By Time", func(t *testing.T) {
+		query, err := schema.Parse("", "-created_at")
+		assert.NoError(t, err)
+
+		var ids []uint64
+		request := pagination.Request{Limit: 4, Order: query.Order}
+		for {
+			page, err := noteDao.ListByUser(context.Background(), 1, request)
+			if !assert.NoError(t, err) {
+				return
+			}
+			for _, note := range page.Items {
+				ids = append(ids, note.ID)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			request.After, err = pagination.DecodeCursor(page.NextCursor)
+			assert.NoError(t, err)
+		}
+		assert.ElementsMatch(t, []uint64{1, 2, 3, 4, 5, 6}, ids)
+	})
+
+	t.Run("Cursor From Another Sort", func(t *testing.T) {
+		first, err := noteDao.ListByUser(context.Background(), 1, pagination.Request{Limit: 1, Order: query.Order})
+		assert.NoError(t, err)
+		cursor, _ := pagination.DecodeCursor(first.NextCursor)
+
+		_, err = noteDao.ListByUser(context.Background(), 1, pagination.Request{Limit: 1, After: cursor})
+		assert.ErrorIs(t, err, pagination.ErrInvalidCursor)
+	})
+}
+
+func TestNoteDao_Stream(t *testing.T) {
+	db := SetupTestDB(t)
+	noteDao := NewNoteDao(db)
+	for i, name := range []string{"b", "a", "b", "c", "a", "d"} {
+		assert.NoError(t, noteDao.Create(context.Background(), &models.Note{UserID: uint64(i%2 + 1), Name: name}))
+	}
+
+	schema := filter.Schema{
+		"name":    {Column: "name", Type: filter.String, Operators: filter.Text, Sortable: true},
+		"user_id": {Column: "user_id", Type: filter.Int, Operators: filter.Ordered},
+	}
+	query, err := schema.Parse("name ne d", "-name")
+	assert.NoError(t, err)
+
+	// Offset and cursor of the request are ignored; every matching row is
+	// handed over exactly once, in batches of two.
+	var batches [][]string
+	err = stream(db.Model(&models.Note{}), pagination.Request{Limit: 1, Offset: 3, Where: query.Where, Order: query.Order}, 2,
+		func(note *models.Note) uint64 { return note.ID },
+		func(notes []models.Note) error {
+			var batch []string
+			for _, note := range notes {
+				batch = append(batch, fmt.Sprintf("%s%d", note.Name, note.ID))
+			}
+			batches = append(batches, batch)
+			return nil
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"c4", "b1"}, {"b3", "a2"}, {"a5"}}, batches)
+
+	t.Run("Filtered By Owner", func(t *testing.T) {
+		query, err := schema.Parse("user_id eq 2", "")
+		assert.NoError(t, err)
+
+		var ids []uint64
+		err = noteDao.Stream(context.Background(), pagination.Request{Where: query.Where}, func(notes []models.Note) error {
+			for _, note := range notes {
+				ids = append(ids, note.ID)
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []uint64{2, 4, 6}, ids)
+	})
+
+	t.Run("Stops On Error", func(t *testing.T) {
+		calls := 0
+		err := stream(db.Model(&models.Note{}), pagination.Request{}, 2,
+			func(note *models.Note) uint64 { return note.ID },
+			func(notes []models.Note) error {
+				calls++
+				return fmt.Errorf("client went away")
+			})
+		assert.EqualError(t, err, "client went away")
+		assert.Equal(t, 1, calls)
+	})
+}
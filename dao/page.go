@@ -0,0 +1,124 @@
+package dao
+
+import (
+	"golang/pagination"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// paginate runs query for one page of the listing page describes. Rows are
+// ordered by page.Order and then by primary key, so every row has a unique
+// position a cursor can point at. One row more than the limit is fetched to
+// learn whether another page follows.
+func paginate[T any](query *gorm.DB, page pagination.Request, idOf func(*T) uint64) (*pagination.Page[T], error) {
+	if page.IncludeDeleted {
+		query = query.Unscoped()
+	}
+	if page.Where != nil {
+		query = query.Where(page.Where)
+	}
+
+	result := &pagination.Page[T]{}
+	if page.WithTotal {
+		var total int64
+		if err := query.Session(&gorm.Session{}).Model(new(T)).Count(&total).Error; err != nil {
+			return nil, err
+		}
+		result.Total = &total
+	}
+
+	columns := make([]clause.OrderByColumn, 0, len(page.Order)+1)
+	for _, order := range page.Order {
+		columns = append(columns, clause.OrderByColumn{
+			Column: clause.Column{Table: clause.CurrentTable, Name: order.Column},
+			Desc:   order.Desc,
+		})
+	}
+	columns = append(columns, clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: clause.PrimaryKey}})
+	query = query.Order(clause.OrderBy{Columns: columns})
+
+	if page.After != nil {
+		keys, err := page.AfterKeys()
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(keysetAfter(columns, append(keys, page.After.ID)))
+	} else {
+		query = query.Offset(page.Offset)
+	}
+
+	items := []T{}
+	found := query.Limit(page.Limit + 1).Find(&items)
+	if found.Error != nil {
+		return nil, found.Error
+	}
+	if len(items) > page.Limit {
+		items = items[:page.Limit]
+		last := &items[len(items)-1]
+
+		keys := make([]interface{}, len(page.Order))
+		for i, order := range page.Order {
+			field := found.Statement.Schema.LookUpField(order.Column)
+			keys[i], _ = field.ValueOf(found.Statement.Context, reflect.ValueOf(last).Elem())
+		}
+		cursor, err := pagination.NewCursor(idOf(last), page.Order, keys)
+		if err != nil {
+			return nil, err
+		}
+		result.NextCursor = cursor.Encode()
+	}
+	result.Items = items
+	return result, nil
+}
+
+// StreamBatchSize is how many rows Stream methods load at a time.
+const StreamBatchSize = 1000
+
+// stream walks every row of the listing page describes, ignoring its offset
+// and cursor, and hands them to fn one batch of batchSize rows at a time. Each
+// batch is a keyset query following the previous one, so memory use does not
+// grow with the number of rows.
+func stream[T any](query *gorm.DB, page pagination.Request, batchSize int, idOf func(*T) uint64, fn func([]T) error) error {
+	page.Limit = batchSize
+	page.Offset = 0
+	page.After = nil
+	page.WithTotal = false
+	for {
+		batch, err := paginate(query.Session(&gorm.Session{}), page, idOf)
+		if err != nil {
+			return err
+		}
+		if len(batch.Items) > 0 {
+			if err := fn(batch.Items); err != nil {
+				return err
+			}
+		}
+		if batch.NextCursor == "" {
+			return nil
+		}
+		if page.After, err = pagination.DecodeCursor(batch.NextCursor); err != nil {
+			return err
+		}
+	}
+}
+
+// keysetAfter matches the rows that sort after the given values:
+// (a > x) OR (a = x AND b > y) OR ... with < for descending columns.
+func keysetAfter(columns []clause.OrderByColumn, values []interface{}) clause.Expression {
+	alternatives := make([]clause.Expression, 0, len(columns))
+	for i, column := range columns {
+		conditions := make([]clause.Expression, 0, i+1)
+		for j := 0; j < i; j++ {
+			conditions = append(conditions, clause.Eq{Column: columns[j].Column, Value: values[j]})
+		}
+		if column.Desc {
+			conditions = append(conditions, clause.Lt{Column: column.Column, Value: values[i]})
+		} else {
+			conditions = append(conditions, clause.Gt{Column: column.Column, Value: values[i]})
+		}
+		alternatives = append(alternatives, clause.And(conditions...))
+	}
+	return clause.Or(alternatives...)
+}
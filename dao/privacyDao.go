@@ -0,0 +1,149 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang/models"
+
+	"gorm.io/gorm"
+)
+
+type EraseMode string
+
+const (
+	// EraseAnonymize keeps the user row and the payment ledger, with every
+	// personal field of the user overwritten.
+	EraseAnonymize EraseMode = "anonymize"
+	// EraseDelete removes every row tied to the user, ledger included.
+	EraseDelete EraseMode = "delete"
+)
+
+var ErrInvalidEraseMode = errors.New("erase mode must be anonymize or delete")
+
+// ChargeRecord is a charge together with its ledger entries.
+type ChargeRecord struct {
+	Charge       models.Charge
+	Transactions []models.ChargeTransaction
+}
+
+// SubjectData is everything stored about one user. Soft-deleted rows are
+// included.
+type SubjectData struct {
+	User    models.User
+	Notes   []models.Note
+	Cards   []models.CreditCard
+	Charges []ChargeRecord
+	// VaultAccess lists detokenize attempts on the user's cards and the ones
+	// the user made.
+	VaultAccess []models.VaultAuditEntry
+}
+
+type IPrivacyDao interface {
+	Collect(ctx context.Context, userID uint64) (*SubjectData, error)
+	Erase(ctx context.Context, userID uint64, mode EraseMode) error
+}
+
+type PrivacyDao struct {
+	db *gorm.DB
+}
+
+func NewPrivacyDao(db *gorm.DB) *PrivacyDao {
+	return &PrivacyDao{db: db}
+}
+
+func (p *PrivacyDao) Collect(ctx context.Context, userID uint64) (*SubjectData, error) {
+	var data SubjectData
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		tx = tx.Unscoped().Session(&gorm.Session{})
+		if err := tx.First(&data.User, userID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Order("id").Find(&data.Notes).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Order("id").Find(&data.Cards).Error; err != nil {
+			return err
+		}
+
+		var charges []models.Charge
+		if err := tx.Where("user_id = ?", userID).Order("id").Find(&charges).Error; err != nil {
+			return err
+		}
+		for _, charge := range charges {
+			record := ChargeRecord{Charge: charge}
+			if err := tx.Where("charge_id = ?", charge.ID).Order("id").Find(&record.Transactions).Error; err != nil {
+				return err
+			}
+			data.Charges = append(data.Charges, record)
+		}
+
+		return tx.Where("actor_id = ? OR token IN (?)", userID, cardTokens(tx, userID)).
+			Order("id").Find(&data.VaultAccess).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// Erase removes the user's personal data from every table in one
+// transaction. Notes, cards and the vaulted card numbers are always deleted
+// for good. With EraseAnonymize the user row stays, soft-deleted, marked as
+// erased and without username or password, so charges keep a valid owner
+// but the user cannot be restored; with EraseDelete the
+// user and the charges go as well. Vault audit entries are kept as the
+// security record they are, without the client IP of the user's own
+// attempts.
+func (p *PrivacyDao) Erase(ctx context.Context, userID uint64, mode EraseMode) error {
+	if mode != EraseAnonymize && mode != EraseDelete {
+		return ErrInvalidEraseMode
+	}
+
+	return p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		tx = tx.Unscoped().Session(&gorm.Session{})
+		if err := tx.Select("id").First(&models.User{}, userID).Error; err != nil {
+			return err
+		}
+
+		tokens := cardTokens(tx, userID)
+		if err := tx.Where("token IN (?)", tokens).Delete(&models.VaultEntry{}).Error; err != nil {
+			return err
+		}
+		err := tx.Model(&models.VaultAuditEntry{}).Where("actor_id = ?", userID).Update("client_ip", "").Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.CreditCard{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.Note{}).Error; err != nil {
+			return err
+		}
+
+		if mode == EraseDelete {
+			charges := tx.Model(&models.Charge{}).Select("id").Where("user_id = ?", userID)
+			if err := tx.Where("charge_id IN (?)", charges).Delete(&models.ChargeTransaction{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("user_id = ?", userID).Delete(&models.Charge{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&models.User{}, userID).Error
+		}
+
+		return tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"username":   fmt.Sprintf("erased-%d", userID),
+			"password":   "",
+			"role":       models.RoleUser,
+			"deleted_at": gorm.Expr("COALESCE(deleted_at, CURRENT_TIMESTAMP)"),
+			"erased_at":  gorm.Expr("CURRENT_TIMESTAMP"),
+			"version":    gorm.Expr("version + 1"),
+		}).Error
+	})
+}
+
+// cardTokens selects the vault tokens of every card the user ever had.
+func cardTokens(tx *gorm.DB, userID uint64) *gorm.DB {
+	return tx.Model(&models.CreditCard{}).Select("token").Where("user_id = ? AND token <> ''", userID)
+}
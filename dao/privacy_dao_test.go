@@ -0,0 +1,124 @@
+package dao
+
+import (
+	"context"
+	"golang/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// seedSubject stores a user with one row in every table that refers to
+// users, plus an unrelated user with the same kinds of rows.
+func seedSubject(t *testing.T, db *gorm.DB, username string) *models.User {
+	user := &models.User{Username: username, Password: "secret"}
+	assert.NoError(t, db.Create(user).Error)
+	assert.NoError(t, db.Create(&models.Note{UserID: user.ID, Name: username + " note"}).Error)
+	token := "tok_" + username
+	card := &models.CreditCard{UserID: user.ID, Token: token, Brand: "visa", Last4: "4242"}
+	assert.NoError(t, db.Create(card).Error)
+	assert.NoError(t, db.Create(&models.VaultEntry{Token: token, Number: "4242424242424242"}).Error)
+	assert.NoError(t, db.Create(&models.VaultAuditEntry{Token: token, ActorID: 999, ClientIP: "10.0.0.1", Success: true}).Error)
+	assert.NoError(t, db.Create(&models.VaultAuditEntry{Token: "tok_other", ActorID: user.ID, ClientIP: "10.0.0.2"}).Error)
+	charge := &models.Charge{UserID: user.ID, CardID: uint64(card.ID), Amount: 500, Currency: "EUR",
+		Gateway: "fake", GatewayReference: "ref_" + username, IdempotencyKey: "key_" + username}
+	assert.NoError(t, db.Create(charge).Error)
+	assert.NoError(t, db.Create(&models.ChargeTransaction{ChargeID: uint64(charge.ID), Type: models.TransactionAuthorize, Amount: 500}).Error)
+	return user
+}
+
+func countRows(t *testing.T, db *gorm.DB, model interface{}, query string, args ...interface{}) int64 {
+	var total int64
+	assert.NoError(t, db.Unscoped().Model(model).Where(query, args...).Count(&total).Error)
+	return total
+}
+
+func TestPrivacyDao_Collect(t *testing.T) {
+	db := SetupTestDB(t)
+	privacyDao := NewPrivacyDao(db)
+	user := seedSubject(t, db, "alice")
+	seedSubject(t, db, "bob")
+	assert.NoError(t, db.Delete(&models.Note{}, "user_id = ?", user.ID).Error)
+
+	data, err := privacyDao.Collect(context.Background(), user.ID)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "alice", data.User.Username)
+	assert.Len(t, data.Notes, 1, "soft-deleted notes are still held")
+	assert.Len(t, data.Cards, 1)
+	assert.Len(t, data.Charges, 1)
+	assert.Len(t, data.Charges[0].Transactions, 1)
+	assert.Len(t, data.VaultAccess, 2)
+
+	_, err = privacyDao.Collect(context.Background(), 999)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestPrivacyDao_Erase(t *testing.T) {
+	t.Run("Anonymize", func(t *testing.T) {
+		db := SetupTestDB(t)
+		privacyDao := NewPrivacyDao(db)
+		user := seedSubject(t, db, "alice")
+		other := seedSubject(t, db, "bob")
+
+		assert.NoError(t, privacyDao.Erase(context.Background(), user.ID, EraseAnonymize))
+
+		var erased models.User
+		assert.NoError(t, db.Unscoped().First(&erased, user.ID).Error)
+		assert.Equal(t, "erased-1", erased.Username)
+		assert.Empty(t, erased.Password)
+		assert.True(t, erased.DeletedAt.Valid)
+		assert.Zero(t, countRows(t, db, &models.Note{}, "user_id = ?", user.ID))
+		assert.Zero(t, countRows(t, db, &models.CreditCard{}, "user_id = ?", user.ID))
+		assert.Zero(t, countRows(t, db, &models.VaultEntry{}, "token = ?", "tok_alice"))
+		assert.Zero(t, countRows(t, db, &models.VaultAuditEntry{}, "actor_id = ? AND client_ip <> ''", user.ID))
+		assert.Equal(t, int64(1), countRows(t, db, &models.Charge{}, "user_id = ?", user.ID))
+
+		assert.Equal(t, int64(1), countRows(t, db, &models.Note{}, "user_id = ?", other.ID))
+		assert.Equal(t, int64(1), countRows(t, db, &models.VaultEntry{}, "token = ?", "tok_bob"))
+
+		// Erasure cannot be undone.
+		assert.NotNil(t, erased.ErasedAt)
+		assert.ErrorIs(t, NewUserDao(db).Restore(context.Background(), user.ID), ErrUserErased)
+		assert.NoError(t, db.Unscoped().First(&erased, user.ID).Error)
+		assert.True(t, erased.DeletedAt.Valid)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		db := SetupTestDB(t)
+		privacyDao := NewPrivacyDao(db)
+		user := seedSubject(t, db, "alice")
+		other := seedSubject(t, db, "bob")
+
+		assert.NoError(t, privacyDao.Erase(context.Background(), user.ID, EraseDelete))
+
+		assert.Zero(t, countRows(t, db, &models.User{}, "id = ?", user.ID))
+		assert.Zero(t, countRows(t, db, &models.Charge{}, "user_id = ?", user.ID))
+		assert.Equal(t, int64(1), countRows(t, db, &models.ChargeTransaction{}, "1 = 1"))
+		assert.Equal(t, int64(1), countRows(t, db, &models.Charge{}, "user_id = ?", other.ID))
+	})
+
+	t.Run("All Or Nothing", func(t *testing.T) {
+		db := SetupTestDB(t)
+		privacyDao := NewPrivacyDao(db)
+		user := seedSubject(t, db, "alice")
+		assert.NoError(t, db.Callback().Update().Before("gorm:update").Register("fail_erase", func(tx *gorm.DB) {
+			if tx.Statement.Table == "users" {
+				tx.AddError(assert.AnError)
+			}
+		}))
+
+		assert.ErrorIs(t, privacyDao.Erase(context.Background(), user.ID, EraseAnonymize), assert.AnError)
+		assert.Equal(t, int64(1), countRows(t, db, &models.Note{}, "user_id = ?", user.ID))
+		assert.Equal(t, int64(1), countRows(t, db, &models.VaultEntry{}, "token = ?", "tok_alice"))
+	})
+
+	t.Run("Unknown User Or Mode", func(t *testing.T) {
+		db := SetupTestDB(t)
+		privacyDao := NewPrivacyDao(db)
+		assert.ErrorIs(t, privacyDao.Erase(context.Background(), 42, EraseAnonymize), gorm.ErrRecordNotFound)
+		assert.ErrorIs(t, privacyDao.Erase(context.Background(), 42, "shred"), ErrInvalidEraseMode)
+	})
+}
@@ -0,0 +1,346 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang/pagination"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+var ErrNotSoftDeletable = errors.New("model has no deleted_at column")
+
+// IRepository is the data access every model gets from Repository.
+type IRepository[T any] interface {
+	Create(ctx context.Context, entity *T) error
+	Get(ctx context.Context, id uint64, scopes ...Scope[T]) (*T, error)
+	First(ctx context.Context, scopes ...Scope[T]) (*T, error)
+	Find(ctx context.Context, scopes ...Scope[T]) ([]T, error)
+	Count(ctx context.Context, scopes ...Scope[T]) (int64, error)
+	Page(ctx context.Context, page pagination.Request, scopes ...Scope[T]) (*pagination.Page[T], error)
+	Stream(ctx context.Context, page pagination.Request, fn func([]T) error, scopes ...Scope[T]) error
+	Update(ctx context.Context, entity *T) error
+	UpdateFields(ctx context.Context, id uint64, version uint64, fields map[string]interface{}) error
+	Delete(ctx context.Context, id uint64, version uint64) error
+	Restore(ctx context.Context, id uint64) error
+}
+
+// Scope narrows or extends a query on T. Scopes of one model cannot be
+// passed to the repository of another.
+type Scope[T any] func(db *gorm.DB) *gorm.DB
+
+// Field is a column of T holding values of type V, so that a filter on it
+// is checked against both at compile time.
+type Field[T any, V any] string
+
+func (f Field[T, V]) column() clause.Column {
+	return clause.Column{Table: clause.CurrentTable, Name: string(f)}
+}
+
+func (f Field[T, V]) Eq(value V) Scope[T] {
+	return Where[T](clause.Eq{Column: f.column(), Value: value})
+}
+
+func (f Field[T, V]) In(values ...V) Scope[T] {
+	list := make([]interface{}, len(values))
+	for i, value := range values {
+		list[i] = value
+	}
+	return Where[T](clause.IN{Column: f.column(), Values: list})
+}
+
+func (f Field[T, V]) Gt(value V) Scope[T] {
+	return Where[T](clause.Gt{Column: f.column(), Value: value})
+}
+
+func (f Field[T, V]) Lt(value V) Scope[T] {
+	return Where[T](clause.Lt{Column: f.column(), Value: value})
+}
+
+// Where adds a raw condition.
+func Where[T any](conditions ...clause.Expression) Scope[T] {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Clauses(clause.Where{Exprs: conditions})
+	}
+}
+
+// Preload loads an association of the rows found.
+func Preload[T any](association string, conditions ...interface{}) Scope[T] {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Preload(association, conditions...)
+	}
+}
+
+// WithDeleted includes soft-deleted rows.
+func WithDeleted[T any]() Scope[T] {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
+	}
+}
+
+// OnlyDeleted matches soft-deleted rows only.
+func OnlyDeleted[T any]() Scope[T] {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped().Where(clause.Neq{Column: clause.Column{Table: clause.CurrentTable, Name: "deleted_at"}, Value: nil})
+	}
+}
+
+// Matching adds the condition of a text search on column.
+func Matching[T any](search Search, column string) Scope[T] {
+	return func(db *gorm.DB) *gorm.DB {
+		condition := search.Condition(DialectOf(db), clause.Column{Table: clause.CurrentTable, Name: column})
+		if condition == nil {
+			return db
+		}
+		return db.Where(condition)
+	}
+}
+
+// Hooks run inside the transaction of the write they belong to; an error
+// rolls the write back. tx carries the caller's context.
+type Hooks[T any] struct {
+	BeforeCreate func(tx *gorm.DB, entity *T) error
+	AfterCreate  func(tx *gorm.DB, entity *T) error
+	BeforeUpdate func(tx *gorm.DB, entity *T) error
+	AfterUpdate  func(tx *gorm.DB, entity *T) error
+	// TranslateError maps the error of every write, e.g. a unique index
+	// violation to an error of the domain.
+	TranslateError func(err error) error
+}
+
+// Repository implements the queries every GORM model needs. A model with a
+// version column gets optimistic locking: a non-zero version passed to a
+// write must still be the stored one, otherwise ErrVersionConflict is
+// returned. Models with a deleted_at column are soft-deleted, and deleted
+// rows are left out unless a scope asks for them.
+type Repository[T any] struct {
+	db      *gorm.DB
+	schema  *schema.Schema
+	version *schema.Field
+	Hooks   Hooks[T]
+}
+
+func NewRepository[T any](db *gorm.DB) *Repository[T] {
+	statement := &gorm.Statement{DB: db}
+	if err := statement.Parse(new(T)); err != nil {
+		panic(fmt.Sprintf("dao: %T is not a model: %v", *new(T), err))
+	}
+	return &Repository[T]{
+		db:      db,
+		schema:  statement.Schema,
+		version: statement.Schema.LookUpField("version"),
+	}
+}
+
+func (r *Repository[T]) Create(ctx context.Context, entity *T) error {
+	return r.write(ctx, entity, r.Hooks.BeforeCreate, r.Hooks.AfterCreate, func(tx *gorm.DB) error {
+		return tx.Create(entity).Error
+	})
+}
+
+// Get returns the row with primary key id, or nil and gorm.ErrRecordNotFound.
+func (r *Repository[T]) Get(ctx context.Context, id uint64, scopes ...Scope[T]) (*T, error) {
+	return r.First(ctx, append(scopes, Where[T](r.primaryKeyIs(id)))...)
+}
+
+func (r *Repository[T]) First(ctx context.Context, scopes ...Scope[T]) (*T, error) {
+	var entity T
+	if err := r.query(ctx, scopes).First(&entity).Error; err != nil {
+		return nil, err
+	}
+	return &entity, nil
+}
+
+func (r *Repository[T]) Find(ctx context.Context, scopes ...Scope[T]) ([]T, error) {
+	entities := []T{}
+	err := r.query(ctx, scopes).Order(clause.OrderByColumn{Column: r.primaryKey()}).Find(&entities).Error
+	return entities, err
+}
+
+func (r *Repository[T]) Count(ctx context.Context, scopes ...Scope[T]) (int64, error) {
+	var count int64
+	err := r.query(ctx, scopes).Model(new(T)).Count(&count).Error
+	return count, err
+}
+
+// Page returns one page of the listing page describes.
+func (r *Repository[T]) Page(ctx context.Context, page pagination.Request, scopes ...Scope[T]) (*pagination.Page[T], error) {
+	return paginate(r.query(ctx, scopes), page, r.idOf)
+}
+
+// Stream hands every row of the listing page describes to fn in batches.
+func (r *Repository[T]) Stream(ctx context.Context, page pagination.Request, fn func([]T) error, scopes ...Scope[T]) error {
+	return stream(r.query(ctx, scopes).Model(new(T)), page, StreamBatchSize, r.idOf, fn)
+}
+
+// Update writes every column of entity except its creation and deletion
+// time. Without a version column, or with a zero version, the write always
+// wins. A row that is missing or soft-deleted is not found.
+func (r *Repository[T]) Update(ctx context.Context, entity *T) error {
+	return r.write(ctx, entity, r.Hooks.BeforeUpdate, r.Hooks.AfterUpdate, func(tx *gorm.DB) error {
+		id := r.idOf(entity)
+		if r.version == nil {
+			result := tx.Model(entity).Select("*").Omit("created_at", "deleted_at").Updates(entity)
+			return r.checked(tx, result, id)
+		}
+
+		value := reflect.ValueOf(entity).Elem()
+		expected := r.versionOf(tx, value)
+		if expected == 0 {
+			stored := new(T)
+			err := tx.Select(r.version.DBName).Where(r.primaryKeyIs(id)).First(stored).Error
+			if err != nil {
+				return err
+			}
+			expected = r.versionOf(tx, reflect.ValueOf(stored).Elem())
+		}
+
+		r.setVersion(tx, value, expected+1)
+		result := tx.Model(entity).Where(clause.Eq{Column: r.versionColumn(), Value: expected}).
+			Select("*").Omit("created_at", "deleted_at").
+			Updates(entity)
+		if err := versionedResult(tx, new(T), result, r.primaryKeyIs(id)); err != nil {
+			r.setVersion(tx, value, expected)
+			return err
+		}
+		return nil
+	})
+}
+
+// UpdateFields updates only the given columns of a row. A non-zero version
+// guards the write like in Update.
+func (r *Repository[T]) UpdateFields(ctx context.Context, id uint64, version uint64, fields map[string]interface{}) error {
+	db := r.db.WithContext(ctx)
+	values := make(map[string]interface{}, len(fields)+1)
+	for column, value := range fields {
+		values[column] = value
+	}
+	query := db.Model(new(T)).Where(r.primaryKeyIs(id))
+	if r.version != nil {
+		values[r.version.DBName] = gorm.Expr("? + 1", clause.Column{Name: r.version.DBName})
+		if version != 0 {
+			query = query.Where(clause.Eq{Column: r.versionColumn(), Value: version})
+		}
+	}
+	return r.translate(r.checked(db, query.Updates(values), id))
+}
+
+// Delete removes the row with primary key id, softly when the model has a
+// deleted_at column. A non-zero version must match the stored one; without
+// a version deleting a missing row is not an error.
+func (r *Repository[T]) Delete(ctx context.Context, id uint64, version uint64) error {
+	db := r.db.WithContext(ctx)
+	query := db.Where(r.primaryKeyIs(id))
+	if version == 0 {
+		return r.translate(query.Delete(new(T)).Error)
+	}
+	if r.version != nil {
+		query = query.Where(clause.Eq{Column: r.versionColumn(), Value: version})
+	}
+	return r.translate(r.checked(db, query.Delete(new(T)), id))
+}
+
+// Restore undeletes a soft-deleted row. Restoring a row that is not deleted
+// does nothing.
+func (r *Repository[T]) Restore(ctx context.Context, id uint64) error {
+	if r.schema.LookUpField("deleted_at") == nil {
+		return ErrNotSoftDeletable
+	}
+	db := r.db.WithContext(ctx).Unscoped().Session(&gorm.Session{})
+	if err := db.Select(r.schema.PrioritizedPrimaryField.DBName).Where(r.primaryKeyIs(id)).First(new(T)).Error; err != nil {
+		return err
+	}
+	values := map[string]interface{}{"deleted_at": nil}
+	if r.version != nil {
+		values[r.version.DBName] = gorm.Expr("? + 1", clause.Column{Name: r.version.DBName})
+	}
+	err := db.Model(new(T)).Where(r.primaryKeyIs(id)).Where("deleted_at IS NOT NULL").UpdateColumns(values).Error
+	return r.translate(err)
+}
+
+func (r *Repository[T]) query(ctx context.Context, scopes []Scope[T]) *gorm.DB {
+	query := r.db.WithContext(ctx)
+	for _, scope := range scopes {
+		query = scope(query)
+	}
+	return query
+}
+
+// write runs a single write with its hooks, in a transaction when there
+// are any.
+func (r *Repository[T]) write(ctx context.Context, entity *T, before, after func(*gorm.DB, *T) error, fn func(tx *gorm.DB) error) error {
+	db := r.db.WithContext(ctx)
+	if before == nil && after == nil {
+		return r.translate(fn(db))
+	}
+	return r.translate(db.Transaction(func(tx *gorm.DB) error {
+		if before != nil {
+			if err := before(tx, entity); err != nil {
+				return err
+			}
+		}
+		if err := fn(tx); err != nil {
+			return err
+		}
+		if after != nil {
+			return after(tx, entity)
+		}
+		return nil
+	}))
+}
+
+// checked reports a write to one row that touched nothing as
+// gorm.ErrRecordNotFound or, for a versioned model, ErrVersionConflict.
+func (r *Repository[T]) checked(db *gorm.DB, result *gorm.DB, id uint64) error {
+	if r.version != nil {
+		return versionedResult(db, new(T), result, r.primaryKeyIs(id))
+	}
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+	var count int64
+	if err := db.Model(new(T)).Where(r.primaryKeyIs(id)).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *Repository[T]) translate(err error) error {
+	if err != nil && r.Hooks.TranslateError != nil {
+		return r.Hooks.TranslateError(err)
+	}
+	return err
+}
+
+func (r *Repository[T]) primaryKey() clause.Column {
+	return clause.Column{Table: clause.CurrentTable, Name: r.schema.PrioritizedPrimaryField.DBName}
+}
+
+func (r *Repository[T]) primaryKeyIs(id uint64) clause.Expression {
+	return clause.Eq{Column: r.primaryKey(), Value: id}
+}
+
+func (r *Repository[T]) versionColumn() clause.Column {
+	return clause.Column{Table: clause.CurrentTable, Name: r.version.DBName}
+}
+
+func (r *Repository[T]) idOf(entity *T) uint64 {
+	value, _ := r.schema.PrioritizedPrimaryField.ValueOf(context.Background(), reflect.ValueOf(entity).Elem())
+	return reflect.ValueOf(value).Uint()
+}
+
+func (r *Repository[T]) versionOf(db *gorm.DB, value reflect.Value) uint64 {
+	version, _ := r.version.ValueOf(db.Statement.Context, value)
+	return reflect.ValueOf(version).Uint()
+}
+
+func (r *Repository[T]) setVersion(db *gorm.DB, value reflect.Value, version uint64) {
+	r.version.Set(db.Statement.Context, value, version)
+}
@@ -0,0 +1,145 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"golang/models"
+	"golang/pagination"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestRepository(t *testing.T) {
+	db := SetupTestDB(t)
+	ctx := context.Background()
+	users := NewUserDao(db)
+	notes := NewRepository[models.Note](db)
+	cards := NewRepository[models.CreditCard](db)
+
+	owner := &models.User{Username: "owner", Role: models.RoleUser}
+	assert.NoError(t, users.Create(ctx, owner))
+	for _, name := range []string{"alpha", "beta", "gamma"} {
+		assert.NoError(t, notes.Create(ctx, &models.Note{UserID: owner.ID, Name: name}))
+	}
+	noteName := Field[models.Note, string]("name")
+	noteOwner := Field[models.Note, uint64]("user_id")
+
+	t.Run("Typed Filters", func(t *testing.T) {
+		found, err := notes.Find(ctx, noteOwner.Eq(owner.ID), noteName.In("alpha", "gamma"))
+		assert.NoError(t, err)
+		if assert.Len(t, found, 2) {
+			assert.Equal(t, "alpha", found[0].Name)
+			assert.Equal(t, "gamma", found[1].Name)
+		}
+
+		count, err := notes.Count(ctx, noteName.Gt("alpha"))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		user, err := users.Get(ctx, owner.ID, Preload[models.User]("Notes"))
+		assert.NoError(t, err)
+		assert.Len(t, user.Notes, 3)
+
+		user, err = users.Get(ctx, 999)
+		assert.Nil(t, user)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	})
+
+	t.Run("Page", func(t *testing.T) {
+		page, err := notes.Page(ctx, pagination.Request{Limit: 2, WithTotal: true}, noteOwner.Eq(owner.ID))
+		assert.NoError(t, err)
+		assert.Len(t, page.Items, 2)
+		assert.Equal(t, int64(3), *page.Total)
+		assert.NotEmpty(t, page.NextCursor)
+	})
+
+	t.Run("Soft Delete And Restore", func(t *testing.T) {
+		note, err := notes.First(ctx, noteName.Eq("beta"))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.ErrorIs(t, notes.Delete(ctx, note.ID, note.Version+1), ErrVersionConflict)
+		assert.NoError(t, notes.Delete(ctx, note.ID, note.Version))
+
+		_, err = notes.Get(ctx, note.ID)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		deleted, err := notes.Find(ctx, OnlyDeleted[models.Note]())
+		assert.NoError(t, err)
+		assert.Len(t, deleted, 1)
+		all, err := notes.Count(ctx, WithDeleted[models.Note]())
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), all)
+
+		assert.NoError(t, notes.Restore(ctx, note.ID))
+		restored, err := notes.Get(ctx, note.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, note.Version+1, restored.Version)
+		assert.ErrorIs(t, notes.Restore(ctx, 999), gorm.ErrRecordNotFound)
+	})
+
+	t.Run("Versioned Writes", func(t *testing.T) {
+		note, _ := notes.First(ctx, noteName.Eq("alpha"))
+		stale := *note
+
+		note.Content = "updated"
+		assert.NoError(t, notes.Update(ctx, note))
+		assert.Equal(t, stale.Version+1, note.Version)
+
+		stale.Content = "lost"
+		assert.ErrorIs(t, notes.Update(ctx, &stale), ErrVersionConflict)
+		assert.ErrorIs(t, notes.UpdateFields(ctx, note.ID, stale.Version, map[string]interface{}{"content": "lost"}), ErrVersionConflict)
+		assert.NoError(t, notes.UpdateFields(ctx, note.ID, note.Version, map[string]interface{}{"content": "patched"}))
+
+		stored, _ := notes.Get(ctx, note.ID)
+		assert.Equal(t, "patched", stored.Content)
+		assert.Equal(t, note.Version+1, stored.Version)
+	})
+
+	t.Run("Unversioned Writes", func(t *testing.T) {
+		card := &models.CreditCard{UserID: owner.ID, Token: "tok_1", Last4: "1111"}
+		assert.NoError(t, cards.Create(ctx, card))
+
+		card.Last4 = "2222"
+		assert.NoError(t, cards.Update(ctx, card))
+		assert.NoError(t, cards.UpdateFields(ctx, uint64(card.ID), 0, map[string]interface{}{"brand": "visa"}))
+		assert.ErrorIs(t, cards.UpdateFields(ctx, 999, 0, map[string]interface{}{"brand": "visa"}), gorm.ErrRecordNotFound)
+
+		stored, err := cards.Get(ctx, uint64(card.ID))
+		assert.NoError(t, err)
+		assert.Equal(t, "2222", stored.Last4)
+		assert.Equal(t, "visa", stored.Brand)
+	})
+
+	t.Run("Hooks", func(t *testing.T) {
+		hooked := NewRepository[models.Note](db)
+		var seen []string
+		hooked.Hooks.BeforeCreate = func(tx *gorm.DB, note *models.Note) error {
+			seen = append(seen, "before "+note.Name)
+			return nil
+		}
+		hooked.Hooks.AfterCreate = func(tx *gorm.DB, note *models.Note) error {
+			seen = append(seen, "after "+note.Name)
+			if note.Name == "rejected" {
+				return errors.New("rejected")
+			}
+			return nil
+		}
+		hooked.Hooks.TranslateError = func(err error) error {
+			return errors.New("translated: " + err.Error())
+		}
+
+		assert.NoError(t, hooked.Create(ctx, &models.Note{UserID: owner.ID, Name: "accepted"}))
+		assert.EqualError(t, hooked.Create(ctx, &models.Note{UserID: owner.ID, Name: "rejected"}), "translated: rejected")
+		assert.Equal(t, []string{"before accepted", "after accepted", "before rejected", "after rejected"}, seen)
+
+		// The failing after hook rolled its insert back.
+		count, err := notes.Count(ctx, noteName.Eq("rejected"))
+		assert.NoError(t, err)
+		assert.Zero(t, count)
+	})
+}
@@ -0,0 +1,115 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+	"golang/models"
+	"golang/pagination"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// searchTestDBs returns every database the search cases run against: SQLite
+// always, and Postgres when TEST_POSTGRES_DSN points at a server. Each
+// Postgres run gets its own schema, dropped afterwards.
+func searchTestDBs(t *testing.T) map[string]*gorm.DB {
+	dbs := map[string]*gorm.DB{"sqlite": SetupTestDB(t)}
+	t.Cleanup(func() {
+		sqlDB, _ := dbs["sqlite"].DB()
+		sqlDB.Close()
+	})
+
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Log("TEST_POSTGRES_DSN is not set, skipping Postgres")
+		return dbs
+	}
+
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to Postgres: %v", err)
+	}
+	schema := fmt.Sprintf("search_test_%d", time.Now().UnixNano())
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		sqlDB, _ := admin.DB()
+		sqlDB.Close()
+	})
+
+	db, err := gorm.Open(postgres.Open(dsn+" search_path="+schema+",public"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to Postgres: %v", err)
+	}
+	t.Cleanup(func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	})
+	migrateTestDB(t, db)
+	dbs["postgres"] = db
+	return dbs
+}
+
+func TestUserDao_Search(t *testing.T) {
+	tests := []struct {
+		name     string
+		search   Search
+		expected []string
+	}{
+		{"Contains", Search{Term: "jane"}, []string{"jane_doe", "jane_smith"}},
+		{"Contains Ignores Case", Search{Term: "JANe", Mode: SearchContains}, []string{"jane_doe", "jane_smith"}},
+		{"Underscore Is Literal", Search{Term: "_s"}, []string{"john_smith", "jane_smith", "100%_sure"}},
+		{"Percent Is Literal", Search{Term: "%"}, []string{"100%_sure"}},
+		{"Escape Character Is Literal", Search{Term: "!"}, []string{"wow!"}},
+		{"Prefix", Search{Term: "J", Mode: SearchPrefix}, []string{"jane_doe", "john_smith", "jane_smith"}},
+		{"Prefix Does Not Match Inside", Search{Term: "smith", Mode: SearchPrefix}, []string{}},
+		{"Fuzzy Typo", Search{Term: "jane smiht", Mode: SearchFuzzy}, []string{"jane_doe", "jane_smith"}},
+		{"Fuzzy Missing Letter", Search{Term: "jhn smith", Mode: SearchFuzzy}, []string{"john_smith", "jane_smith"}},
+		{"Fuzzy Unrelated", Search{Term: "zebra", Mode: SearchFuzzy}, []string{}},
+	}
+
+	for driver, db := range searchTestDBs(t) {
+		userDao := NewUserDao(db)
+		for _, username := range []string{"user1", "jane_doe", "john_smith", "jane_smith", "100%_sure", "wow!"} {
+			assert.NoError(t, userDao.Create(context.Background(), &models.User{Username: username, Password: "password"}))
+		}
+
+		for _, tt := range tests {
+			t.Run(driver+"/"+tt.name, func(t *testing.T) {
+				page, err := userDao.GetAll(context.Background(), pagination.Request{Limit: 10}, tt.search)
+				if !assert.NoError(t, err) {
+					return
+				}
+				usernames := []string{}
+				for _, user := range page.Items {
+					usernames = append(usernames, user.Username)
+				}
+				assert.ElementsMatch(t, tt.expected, usernames)
+			})
+		}
+	}
+}
+
+func TestTrigrams(t *testing.T) {
+	assert.Equal(t, []string{"jan", "ane", "smi", "mit", "ith"}, trigrams("Jane_Smith"))
+	assert.Empty(t, trigrams("a b-cd"))
+}
+
+func TestPostgresDialect_WithoutTrigrams(t *testing.T) {
+	// A dry run finds no pg_trgm, as on a server where the migration could
+	// not create it.
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	assert.NoError(t, err)
+
+	var users []models.User
+	statement := db.Scopes(Matching[models.User](Search{Term: "jane", Mode: SearchFuzzy}, "username")).Find(&users).Statement
+	assert.NotContains(t, statement.SQL.String(), " % ")
+	assert.Contains(t, statement.SQL.String(), "LIKE")
+}
@@ -1,73 +1,243 @@
 package dao
 
 import (
+	"context"
+	"errors"
 	"golang/models"
+	"golang/pagination"
+	"time"
 
 	"gorm.io/gorm"
 )
 
 type IUserDao interface {
-	Create(user *models.User) error
-	GetByID(id uint64) (*models.User, error)
-	GetAll(page int, pageSize int, searchQuery string) ([]models.User, error)
-	Update(user *models.User) error
-	Delete(id uint64) error
-	FindByEmail(email string) (*models.User, error)
+	Create(ctx context.Context, user *models.User) error
+	GetByID(ctx context.Context, id uint64) (*models.User, error)
+	// FindByID returns the user without notes and cards.
+	FindByID(ctx context.Context, id uint64) (*models.User, error)
+	GetAll(ctx context.Context, page pagination.Request, search Search) (*pagination.Page[models.User], error)
+	Stream(ctx context.Context, page pagination.Request, search Search, fn func([]models.User) error) error
+	Update(ctx context.Context, user *models.User) error
+	UpdateFields(ctx context.Context, id uint64, version uint64, fields map[string]interface{}) error
+	Delete(ctx context.Context, id uint64, version uint64) error
+	Restore(ctx context.Context, id uint64) error
+	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	UsernamesTaken(ctx context.Context, usernames []string) (map[string]bool, error)
+	ImportBatch(ctx context.Context, users []*models.User, setRole []bool) ([]bool, error)
 }
 
+var ErrUsernameTaken = errors.New("username is already taken")
+
+// ErrUserErased is returned when restoring a user whose personal data was
+// erased.
+var ErrUserErased = errors.New("user was erased and cannot be restored")
+
+var userUsername = Field[models.User, string]("username")
+
+// UserDao is the user repository plus the queries and cascades only users
+// have.
 type UserDao struct {
+	*Repository[models.User]
 	db *gorm.DB
 }
 
 func NewUserDao(db *gorm.DB) *UserDao {
-	return &UserDao{db: db}
+	u := &UserDao{Repository: NewRepository[models.User](db), db: db}
+	u.Hooks.TranslateError = u.usernameError
+	u.Hooks.AfterUpdate = keepDefaultCard
+	return u
+}
+
+// keepDefaultCard makes the default card of an update the user's only
+// default, so a stored card that was the default before is not one any
+// more.
+func keepDefaultCard(tx *gorm.DB, user *models.User) error {
+	for _, card := range user.CreditCards {
+		if !card.IsDefault {
+			continue
+		}
+		err := tx.Model(&models.CreditCard{}).
+			Where("user_id = ? AND id <> ? AND is_default = ?", user.ID, card.ID, true).
+			Update("is_default", false).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.CreditCard{}).Where("id = ?", card.ID).Update("is_default", true).Error
+	}
+	return nil
 }
 
-func (u *UserDao) Create(user *models.User) error {
-	return u.db.Create(user).Error
+func (u *UserDao) GetByID(ctx context.Context, id uint64) (*models.User, error) {
+	return u.Get(ctx, id, Preload[models.User]("Notes"), Preload[models.User]("CreditCards"))
 }
 
-func (u *UserDao) GetByID(id uint64) (*models.User, error) {
-	var user models.User
-	err := u.db.Preload("Notes").Preload("CreditCard").First(&user, id).Error
-	return &user, err
+// FindByID returns the user without its notes and cards.
+func (u *UserDao) FindByID(ctx context.Context, id uint64) (*models.User, error) {
+	return u.Get(ctx, id)
 }
 
-func (u *UserDao) FindByEmail(email string) (*models.User, error) {
-	var user models.User
-	err := u.db.First(&user, "userName = ?", email).Error
-	return &user, err
+func (u *UserDao) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	return u.First(ctx, userUsername.Eq(email))
 }
 
 // func (u *UserDao) GetAll() ([]models.User, error) {
 // 	var users []models.User
-// 	err := u.db.Preload("Notes").Preload("CreditCard").Find(&users).Error
+// 	err := u.db.Preload("Notes").Preload("CreditCards").Find(&users).Error
 // 	return users, err
 // }
 
 // func (u *UserDao) GetAll(offset int, pageSize int) ([]models.User, error) {
 // 	var users []models.User
-// 	err := u.db.Preload("Notes").Preload("CreditCard").Offset(offset).Limit(pageSize).Find(&users).Error
+// 	err := u.db.Preload("Notes").Preload("CreditCards").Offset(offset).Limit(pageSize).Find(&users).Error
 // 	return users, err
 // }
 
-func (u *UserDao) GetAll(offset int, pageSize int, searchQuery string) ([]models.User, error) {
-	var users []models.User
-	query := u.db.Preload("Notes").Preload("CreditCard")
+func (u *UserDao) GetAll(ctx context.Context, page pagination.Request, search Search) (*pagination.Page[models.User], error) {
+	return u.Page(ctx, page, Preload[models.User]("Notes"), Preload[models.User]("CreditCards"), Matching[models.User](search, "username"))
+}
 
-	if searchQuery != "" {
-		query = query.Where("Username ILIKE ?", "%"+searchQuery+"%")
-	}
+// Stream hands every user matching page's filter and search to fn in batches,
+// in page's order. Associations are not loaded.
+func (u *UserDao) Stream(ctx context.Context, page pagination.Request, search Search, fn func([]models.User) error) error {
+	return u.Repository.Stream(ctx, page, fn, Matching[models.User](search, "username"))
+}
+
+// Delete soft-deletes a user together with its notes and cards. Everything
+// gets the same deletion time, which is how Restore later tells the rows
+// deleted with the user from ones deleted on their own before. A non-zero
+// version must match the stored one; without a version deleting a missing
+// user is not an error.
+func (u *UserDao) Delete(ctx context.Context, id uint64, version uint64) error {
+	return u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		deletedAt := tx.NowFunc().UTC().Truncate(time.Microsecond)
+
+		query := tx.Model(&models.User{}).Where("id = ?", id)
+		if version != 0 {
+			query = query.Where("version = ?", version)
+		}
+		result := query.UpdateColumns(map[string]interface{}{"deleted_at": deletedAt, "version": gorm.Expr("version + 1")})
+		if version != 0 {
+			if err := versionedResult(tx, &models.User{}, result, "id = ?", id); err != nil {
+				return err
+			}
+		}
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		for _, child := range []interface{}{&models.Note{}, &models.CreditCard{}} {
+			if err := tx.Model(child).Where("user_id = ?", id).UpdateColumn("deleted_at", deletedAt).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
 
-	err := query.Offset(offset).Limit(pageSize).Find(&users).Error
+// Restore undeletes a user and the notes and cards that were deleted with
+// it. Restoring a user that is not deleted does nothing. It fails with
+// ErrUsernameTaken when another user has taken the name in the meantime and
+// with ErrUserErased for an erased user.
+func (u *UserDao) Restore(ctx context.Context, id uint64) error {
+	return u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		tx = tx.Unscoped().Session(&gorm.Session{})
+
+		var user models.User
+		if err := tx.Select("id", "deleted_at", "erased_at").First(&user, id).Error; err != nil {
+			return err
+		}
+		if user.ErasedAt != nil {
+			return ErrUserErased
+		}
+		if !user.DeletedAt.Valid {
+			return nil
+		}
+
+		for _, child := range []interface{}{&models.Note{}, &models.CreditCard{}} {
+			err := tx.Model(child).Where("user_id = ? AND deleted_at >= ?", id, user.DeletedAt.Time).
+				UpdateColumn("deleted_at", nil).Error
+			if err != nil {
+				return err
+			}
+		}
+		err := tx.Model(&models.User{}).Where("id = ?", id).
+			UpdateColumns(map[string]interface{}{"deleted_at": nil, "version": gorm.Expr("version + 1")}).Error
+		return u.usernameError(err)
+	})
+}
 
-	return users, err
+// usernameError turns the unique index violation on users.username into
+// ErrUsernameTaken.
+func (u *UserDao) usernameError(err error) error {
+	if translator, ok := u.db.Dialector.(gorm.ErrorTranslator); ok && err != nil {
+		if errors.Is(translator.Translate(err), gorm.ErrDuplicatedKey) {
+			return ErrUsernameTaken
+		}
+	}
+	return err
 }
 
-func (u *UserDao) Update(user *models.User) error {
-	return u.db.Save(user).Error
+// UsernamesTaken reports which of the given usernames already belong to a
+// user.
+func (u *UserDao) UsernamesTaken(ctx context.Context, usernames []string) (map[string]bool, error) {
+	var taken []string
+	if err := u.db.WithContext(ctx).Model(&models.User{}).Where("username IN ?", usernames).Pluck("username", &taken).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[string]bool, len(taken))
+	for _, username := range taken {
+		result[username] = true
+	}
+	return result, nil
 }
 
-func (u *UserDao) Delete(id uint64) error {
-	return u.db.Delete(&models.User{}, id).Error
+// ImportBatch upserts users by username in one transaction. Unknown usernames
+// are inserted; known ones get their role replaced where setRole is true and
+// their password when it is set. The result tells for every user whether it
+// was inserted. Either the whole batch is written or none of it.
+func (u *UserDao) ImportBatch(ctx context.Context, users []*models.User, setRole []bool) ([]bool, error) {
+	created := make([]bool, len(users))
+	err := u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		usernames := make([]string, len(users))
+		for i, user := range users {
+			usernames[i] = user.Username
+		}
+		var existing []models.User
+		if err := tx.Select("id", "username").Where("username IN ?", usernames).Find(&existing).Error; err != nil {
+			return err
+		}
+		ids := make(map[string]uint64, len(existing))
+		for _, user := range existing {
+			ids[user.Username] = user.ID
+		}
+
+		var inserts []*models.User
+		for i, user := range users {
+			id, ok := ids[user.Username]
+			if !ok {
+				created[i] = true
+				inserts = append(inserts, user)
+				continue
+			}
+			fields := map[string]interface{}{"version": gorm.Expr("version + 1")}
+			if setRole[i] {
+				fields["role"] = user.Role
+			}
+			if user.Password != "" {
+				fields["password"] = user.Password
+			}
+			if err := tx.Model(&models.User{}).Where("id = ?", id).Updates(fields).Error; err != nil {
+				return err
+			}
+			user.ID = id
+		}
+		if len(inserts) == 0 {
+			return nil
+		}
+		return tx.Create(inserts).Error
+	})
+	if err != nil {
+		return nil, u.usernameError(err)
+	}
+	return created, nil
 }
@@ -1,7 +1,13 @@
 package dao
 
 import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"golang/encryption"
+	"golang/migrations"
 	"golang/models"
+	"golang/pagination"
 	"log"
 	"testing"
 
@@ -13,18 +19,39 @@ import (
 
 // SetupTestDB initializes an in-memory SQLite database and migrates the schema.
 func SetupTestDB(t *testing.T) *gorm.DB {
+	encryption.SetKeyProvider(NewTestKeyProvider(t, "test"))
+
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	if err != nil {
 		t.Fatalf("Failed to connect to in-memory database: %v", err)
 	}
 
-	// Migrate the schema
-	err = db.AutoMigrate(&models.User{}, &models.Note{}, &models.CreditCard{})
+	migrateTestDB(t, db)
+	return db
+}
+
+// migrateTestDB creates the schema with the migrations the server runs.
+func migrateTestDB(t *testing.T, db *gorm.DB) {
+	migrator, err := migrations.New(db)
 	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	if _, err := migrator.Up(); err != nil {
 		t.Fatalf("Failed to migrate database schema: %v", err)
 	}
+}
 
-	return db
+// NewTestKeyProvider returns an in-memory key provider with a single random key.
+func NewTestKeyProvider(t *testing.T, keyID string) encryption.KeyProvider {
+	key := make([]byte, encryption.MasterKeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("Failed to generate master key: %v", err)
+	}
+	provider, err := encryption.NewLocalKeyProvider(keyID, map[string][]byte{keyID: key})
+	if err != nil {
+		t.Fatalf("Failed to create key provider: %v", err)
+	}
+	return provider
 }
 
 func TestUserDao_Create(t *testing.T) {
@@ -45,7 +72,7 @@ func TestUserDao_Create(t *testing.T) {
 			Password: "password123",
 		}
 
-		err := userDao.Create(user)
+		err := userDao.Create(context.Background(), user)
 		assert.NoError(t, err)
 		assert.NotZero(t, user.ID)
 
@@ -62,14 +89,14 @@ func TestUserDao_Create(t *testing.T) {
 			Username: "janedoe",
 			Password: "password1",
 		}
-		err := userDao.Create(user1)
+		err := userDao.Create(context.Background(), user1)
 		assert.NoError(t, err)
 
 		user2 := &models.User{
 			Username: "janedoe", // Duplicate username
 			Password: "password2",
 		}
-		err = userDao.Create(user2)
+		err = userDao.Create(context.Background(), user2)
 		assert.Error(t, err)
 	})
 }
@@ -94,26 +121,27 @@ func TestUserDao_GetByID(t *testing.T) {
 			{Name: "Note1", Content: "Alice's first note"},
 			{Name: "Note2", Content: "Alice's second note"},
 		},
-		CreditCard: models.CreditCard{
-			Number: "4111111111111111",
+		CreditCards: []models.CreditCard{
+			{Token: "tok_alice", Brand: "visa", Last4: "1111", IsDefault: true},
 		},
 	}
 
-	err := userDao.Create(user)
+	err := userDao.Create(context.Background(), user)
 	assert.NoError(t, err)
 	assert.NotZero(t, user.ID)
 
 	t.Run("Found", func(t *testing.T) {
-		fetchedUser, err := userDao.GetByID(user.ID)
+		fetchedUser, err := userDao.GetByID(context.Background(), user.ID)
 		assert.NoError(t, err)
 		assert.Equal(t, user.Username, fetchedUser.Username)
 		assert.Equal(t, user.Password, fetchedUser.Password)
 		assert.Len(t, fetchedUser.Notes, 2)
-		assert.Equal(t, user.CreditCard.Number, fetchedUser.CreditCard.Number)
+		assert.Len(t, fetchedUser.CreditCards, 1)
+		assert.Equal(t, "tok_alice", fetchedUser.CreditCards[0].Token)
 	})
 
 	t.Run("Not Found", func(t *testing.T) {
-		fetchedUser, err := userDao.GetByID(999) // Non-existent ID
+		fetchedUser, err := userDao.GetByID(context.Background(), 999) // Non-existent ID
 		assert.Error(t, err)
 		assert.Nil(t, fetchedUser)
 	})
@@ -141,7 +169,7 @@ func TestUserDao_GetAll(t *testing.T) {
 	}
 
 	for i := range users {
-		err := userDao.Create(&users[i])
+		err := userDao.Create(context.Background(), &users[i])
 		assert.NoError(t, err)
 	}
 
@@ -150,8 +178,11 @@ func TestUserDao_GetAll(t *testing.T) {
 		pageSize := 2
 		searchQuery := "jane"
 
-		fetchedUsers, err := userDao.GetAll((page-1)*pageSize, pageSize, searchQuery)
-		assert.NoError(t, err)
+		result, err := userDao.GetAll(context.Background(), pagination.Request{Offset: (page - 1) * pageSize, Limit: pageSize}, Search{Term: searchQuery})
+		if !assert.NoError(t, err) {
+			return
+		}
+		fetchedUsers := result.Items
 		assert.Len(t, fetchedUsers, 2)
 		for _, user := range fetchedUsers {
 			assert.Contains(t, user.Username, "jane")
@@ -163,8 +194,11 @@ func TestUserDao_GetAll(t *testing.T) {
 		pageSize := 2
 		searchQuery := ""
 
-		fetchedUsers, err := userDao.GetAll((page-1)*pageSize, pageSize, searchQuery)
-		assert.NoError(t, err)
+		result, err := userDao.GetAll(context.Background(), pagination.Request{Offset: (page - 1) * pageSize, Limit: pageSize}, Search{Term: searchQuery})
+		if !assert.NoError(t, err) {
+			return
+		}
+		fetchedUsers := result.Items
 		assert.Len(t, fetchedUsers, 2)
 	})
 
@@ -173,8 +207,11 @@ func TestUserDao_GetAll(t *testing.T) {
 		pageSize := 5
 		searchQuery := "nonexistent"
 
-		fetchedUsers, err := userDao.GetAll((page-1)*pageSize, pageSize, searchQuery)
-		assert.NoError(t, err)
+		result, err := userDao.GetAll(context.Background(), pagination.Request{Offset: (page - 1) * pageSize, Limit: pageSize}, Search{Term: searchQuery})
+		if !assert.NoError(t, err) {
+			return
+		}
+		fetchedUsers := result.Items
 		assert.Len(t, fetchedUsers, 0)
 	})
 
@@ -183,8 +220,11 @@ func TestUserDao_GetAll(t *testing.T) {
 		pageSize := 10
 		searchQuery := "JANe"
 
-		fetchedUsers, err := userDao.GetAll((page-1)*pageSize, pageSize, searchQuery)
-		assert.NoError(t, err)
+		result, err := userDao.GetAll(context.Background(), pagination.Request{Offset: (page - 1) * pageSize, Limit: pageSize}, Search{Term: searchQuery})
+		if !assert.NoError(t, err) {
+			return
+		}
+		fetchedUsers := result.Items
 		assert.Len(t, fetchedUsers, 2)
 		for _, user := range fetchedUsers {
 			assert.Contains(t, user.Username, "jane")
@@ -192,6 +232,36 @@ func TestUserDao_GetAll(t *testing.T) {
 	})
 }
 
+func TestUserDao_GetAllWithCursor(t *testing.T) {
+	db := SetupTestDB(t)
+	defer func() {
+		sqlDB, err := db.DB()
+		if err != nil {
+			log.Fatalf("Failed to get DB from GORM: %v", err)
+		}
+		sqlDB.Close()
+	}()
+
+	userDao := NewUserDao(db)
+	for _, username := range []string{"amy", "ben", "cat"} {
+		assert.NoError(t, userDao.Create(context.Background(), &models.User{Username: username, Password: "password"}))
+	}
+
+	first, err := userDao.GetAll(context.Background(), pagination.Request{Limit: 2, WithTotal: true}, Search{})
+	assert.NoError(t, err)
+	assert.Len(t, first.Items, 2)
+	assert.Equal(t, int64(3), *first.Total)
+	assert.NotEmpty(t, first.NextCursor)
+
+	cursor, err := pagination.DecodeCursor(first.NextCursor)
+	assert.NoError(t, err)
+	second, err := userDao.GetAll(context.Background(), pagination.Request{Limit: 2, After: cursor}, Search{})
+	assert.NoError(t, err)
+	assert.Len(t, second.Items, 1)
+	assert.Equal(t, "cat", second.Items[0].Username)
+	assert.Empty(t, second.NextCursor)
+}
+
 func TestUserDao_Update(t *testing.T) {
 	db := SetupTestDB(t)
 	defer func() {
@@ -209,7 +279,7 @@ func TestUserDao_Update(t *testing.T) {
 		Username: "bob",
 		Password: "password123",
 	}
-	err := userDao.Create(user)
+	err := userDao.Create(context.Background(), user)
 	assert.NoError(t, err)
 	assert.NotZero(t, user.ID)
 
@@ -218,7 +288,7 @@ func TestUserDao_Update(t *testing.T) {
 		user.Username = "bobby"
 		user.Password = "newpassword"
 
-		err := userDao.Update(user)
+		err := userDao.Update(context.Background(), user)
 		assert.NoError(t, err)
 
 		// Verify updates
@@ -236,15 +306,144 @@ func TestUserDao_Update(t *testing.T) {
 			Password: "ghostpassword",
 		}
 
-		err := userDao.Update(nonExistentUser)
-		assert.NoError(t, err) // GORM's Save will create or update; depends on implementation
+		err := userDao.Update(context.Background(), nonExistentUser)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
 
-		// Verify that the user was created
-		var fetchedUser models.User
-		err = db.First(&fetchedUser, nonExistentUser.ID).Error
+		// Verify that no user was created
+		var count int64
+		assert.NoError(t, db.Unscoped().Model(&models.User{}).Where("id = ?", nonExistentUser.ID).Count(&count).Error)
+		assert.Zero(t, count)
+	})
+
+	t.Run("New Default Card Replaces The Stored One", func(t *testing.T) {
+		owner := &models.User{Username: "carol", Password: "password123",
+			CreditCards: []models.CreditCard{{Token: "tok_old", Last4: "1111", IsDefault: true}}}
+		assert.NoError(t, userDao.Create(context.Background(), owner))
+
+		update := &models.User{ID: owner.ID, Username: "carol", Password: "password123",
+			CreditCards: []models.CreditCard{{Token: "tok_new", Last4: "2222", IsDefault: true}}}
+		assert.NoError(t, userDao.Update(context.Background(), update))
+
+		var defaults []models.CreditCard
+		assert.NoError(t, db.Where("user_id = ? AND is_default = ?", owner.ID, true).Find(&defaults).Error)
+		if assert.Len(t, defaults, 1) {
+			assert.Equal(t, "tok_new", defaults[0].Token)
+		}
+	})
+
+	t.Run("Update Deleted User", func(t *testing.T) {
+		deleted := &models.User{Username: "gone", Password: "password123"}
+		assert.NoError(t, userDao.Create(context.Background(), deleted))
+		assert.NoError(t, userDao.Delete(context.Background(), deleted.ID, 0))
+
+		err := userDao.Update(context.Background(), &models.User{ID: deleted.ID, Username: "back"})
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+		var stored models.User
+		assert.NoError(t, db.Unscoped().First(&stored, deleted.ID).Error)
+		assert.Equal(t, "gone", stored.Username)
+		assert.True(t, stored.DeletedAt.Valid)
+	})
+}
+
+func TestUserDao_UpdateFields(t *testing.T) {
+	db := SetupTestDB(t)
+	defer func() {
+		sqlDB, err := db.DB()
+		if err != nil {
+			log.Fatalf("Failed to get DB from GORM: %v", err)
+		}
+		sqlDB.Close()
+	}()
+
+	userDao := NewUserDao(db)
+
+	user := &models.User{
+		Username: "carol",
+		Password: "password123",
+		Role:     models.RoleUser,
+	}
+	err := userDao.Create(context.Background(), user)
+	assert.NoError(t, err)
+
+	t.Run("Success", func(t *testing.T) {
+		err := userDao.UpdateFields(context.Background(), user.ID, 0, map[string]interface{}{"username": "caroline"})
 		assert.NoError(t, err)
-		assert.Equal(t, "ghost", fetchedUser.Username)
-		assert.Equal(t, "ghostpassword", fetchedUser.Password)
+
+		var updatedUser models.User
+		err = db.First(&updatedUser, user.ID).Error
+		assert.NoError(t, err)
+		assert.Equal(t, "caroline", updatedUser.Username)
+		assert.Equal(t, "password123", updatedUser.Password)
+		assert.Equal(t, models.RoleUser, updatedUser.Role)
+	})
+
+	t.Run("Non-Existent User", func(t *testing.T) {
+		err := userDao.UpdateFields(context.Background(), 999, 0, map[string]interface{}{"username": "ghost"})
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	})
+}
+
+func TestUserDao_Versioning(t *testing.T) {
+	db := SetupTestDB(t)
+	defer func() {
+		sqlDB, err := db.DB()
+		if err != nil {
+			log.Fatalf("Failed to get DB from GORM: %v", err)
+		}
+		sqlDB.Close()
+	}()
+
+	userDao := NewUserDao(db)
+
+	user := &models.User{Username: "dave", Password: "password123"}
+	assert.NoError(t, userDao.Create(context.Background(), user))
+	assert.Equal(t, uint64(1), user.Version)
+
+	t.Run("Update Bumps Version", func(t *testing.T) {
+		update := &models.User{ID: user.ID, Username: "david", Password: "password123", Version: 1,
+			CreditCards: []models.CreditCard{{Token: "tok_dave", Last4: "4242", IsDefault: true}}}
+		assert.NoError(t, userDao.Update(context.Background(), update))
+		assert.Equal(t, uint64(2), update.Version)
+
+		stored, err := userDao.GetByID(context.Background(), user.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "david", stored.Username)
+		assert.Equal(t, uint64(2), stored.Version)
+		assert.Len(t, stored.CreditCards, 1)
+		assert.False(t, stored.CreatedAt.IsZero())
+	})
+
+	t.Run("Stale Update", func(t *testing.T) {
+		stale := &models.User{ID: user.ID, Username: "stale", Password: "password123", Version: 1}
+		assert.ErrorIs(t, userDao.Update(context.Background(), stale), ErrVersionConflict)
+		assert.Equal(t, uint64(1), stale.Version)
+
+		stored, err := userDao.GetByID(context.Background(), user.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "david", stored.Username)
+	})
+
+	t.Run("Unversioned Update Still Bumps Version", func(t *testing.T) {
+		update := &models.User{ID: user.ID, Username: "dave", Password: "password123"}
+		assert.NoError(t, userDao.Update(context.Background(), update))
+		assert.Equal(t, uint64(3), update.Version)
+	})
+
+	t.Run("Update Fields", func(t *testing.T) {
+		assert.ErrorIs(t, userDao.UpdateFields(context.Background(), user.ID, 2, map[string]interface{}{"username": "x"}), ErrVersionConflict)
+		assert.NoError(t, userDao.UpdateFields(context.Background(), user.ID, 3, map[string]interface{}{"username": "davey"}))
+
+		stored, err := userDao.GetByID(context.Background(), user.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "davey", stored.Username)
+		assert.Equal(t, uint64(4), stored.Version)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		assert.ErrorIs(t, userDao.Delete(context.Background(), user.ID, 3), ErrVersionConflict)
+		assert.ErrorIs(t, userDao.Delete(context.Background(), 999, 1), gorm.ErrRecordNotFound)
+		assert.NoError(t, userDao.Delete(context.Background(), user.ID, 4))
 	})
 }
 
@@ -265,12 +464,12 @@ func TestUserDao_Delete(t *testing.T) {
 		Username: "charlie",
 		Password: "password123",
 	}
-	err := userDao.Create(user)
+	err := userDao.Create(context.Background(), user)
 	assert.NoError(t, err)
 	assert.NotZero(t, user.ID)
 
 	t.Run("Success", func(t *testing.T) {
-		err := userDao.Delete(user.ID)
+		err := userDao.Delete(context.Background(), user.ID, 0)
 		assert.NoError(t, err)
 
 		// Verify deletion
@@ -280,7 +479,133 @@ func TestUserDao_Delete(t *testing.T) {
 	})
 
 	t.Run("Delete Non-Existent User", func(t *testing.T) {
-		err := userDao.Delete(999) // Non-existent ID
-		assert.NoError(t, err)     // No error should be returned for non-existent users
+		err := userDao.Delete(context.Background(), 999, 0) // Non-existent ID
+		assert.NoError(t, err)                              // No error should be returned for non-existent users
+	})
+}
+
+func TestUserDao_ImportBatch(t *testing.T) {
+	db := SetupTestDB(t)
+	userDao := NewUserDao(db)
+
+	existing := &models.User{Username: "alice", Password: "old", Role: models.RoleUser}
+	assert.NoError(t, userDao.Create(context.Background(), existing))
+
+	t.Run("Upserts By Username", func(t *testing.T) {
+		users := []*models.User{
+			{Username: "alice", Role: models.RoleAdmin},
+			{Username: "bob", Password: "hash", Role: models.RoleUser},
+		}
+		created, err := userDao.ImportBatch(context.Background(), users, []bool{true, true})
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []bool{false, true}, created)
+		assert.Equal(t, existing.ID, users[0].ID)
+		assert.NotZero(t, users[1].ID)
+
+		var alice models.User
+		assert.NoError(t, db.First(&alice, existing.ID).Error)
+		assert.Equal(t, models.RoleAdmin, alice.Role)
+		assert.Equal(t, "old", alice.Password)
+		assert.Equal(t, uint64(2), alice.Version)
+	})
+
+	t.Run("Keeps The Role Unless Set", func(t *testing.T) {
+		_, err := userDao.ImportBatch(context.Background(), []*models.User{
+			{Username: "alice", Password: "new", Role: models.RoleUser},
+		}, []bool{false})
+		assert.NoError(t, err)
+
+		var alice models.User
+		assert.NoError(t, db.First(&alice, existing.ID).Error)
+		assert.Equal(t, models.RoleAdmin, alice.Role)
+		assert.Equal(t, "new", alice.Password)
+	})
+
+	t.Run("Rolls Back The Whole Batch", func(t *testing.T) {
+		db.Callback().Create().Before("gorm:create").Register("fail_import", func(tx *gorm.DB) {
+			tx.AddError(errors.New("insert failed"))
+		})
+		defer db.Callback().Create().Remove("fail_import")
+
+		_, err := userDao.ImportBatch(context.Background(), []*models.User{
+			{Username: "alice", Role: models.RoleUser},
+			{Username: "carol", Password: "hash"},
+		}, []bool{true, true})
+		assert.Error(t, err)
+
+		var alice models.User
+		assert.NoError(t, db.First(&alice, existing.ID).Error)
+		assert.Equal(t, models.RoleAdmin, alice.Role)
+
+		taken, err := userDao.UsernamesTaken(context.Background(), []string{"alice", "bob", "carol"})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]bool{"alice": true, "bob": true}, taken)
+	})
+}
+
+func TestUserDao_DeleteAndRestore(t *testing.T) {
+	db := SetupTestDB(t)
+	userDao := NewUserDao(db)
+	noteDao := NewNoteDao(db)
+
+	user := &models.User{
+		Username:    "alice",
+		Notes:       []models.Note{{Name: "kept"}, {Name: "gone before"}},
+		CreditCards: []models.CreditCard{{Token: "tok_alice"}},
+	}
+	assert.NoError(t, userDao.Create(context.Background(), user))
+	assert.NoError(t, noteDao.Delete(context.Background(), user.ID, user.Notes[1].ID, 0))
+
+	t.Run("Cascades To Children", func(t *testing.T) {
+		assert.NoError(t, userDao.Delete(context.Background(), user.ID, 0))
+
+		assert.Zero(t, countRows(t, db, &models.Note{}, "user_id = ? AND deleted_at IS NULL", user.ID))
+		assert.Zero(t, countRows(t, db, &models.CreditCard{}, "user_id = ? AND deleted_at IS NULL", user.ID))
+		_, err := userDao.GetByID(context.Background(), user.ID)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	})
+
+	t.Run("Username Is Free Again", func(t *testing.T) {
+		taken := &models.User{Username: "alice"}
+		assert.NoError(t, userDao.Create(context.Background(), taken))
+
+		assert.ErrorIs(t, userDao.Restore(context.Background(), user.ID), ErrUsernameTaken)
+		assert.NoError(t, userDao.Delete(context.Background(), taken.ID, 0))
+	})
+
+	t.Run("Restore Brings Back What Was Deleted With It", func(t *testing.T) {
+		assert.NoError(t, userDao.Restore(context.Background(), user.ID))
+
+		restored, err := userDao.GetByID(context.Background(), user.ID)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Len(t, restored.Notes, 1)
+		assert.Equal(t, "kept", restored.Notes[0].Name)
+		assert.Len(t, restored.CreditCards, 1)
+
+		assert.NoError(t, userDao.Restore(context.Background(), user.ID), "restoring a live user does nothing")
+	})
+
+	t.Run("Stale Version", func(t *testing.T) {
+		assert.ErrorIs(t, userDao.Delete(context.Background(), user.ID, 1), ErrVersionConflict)
+	})
+
+	t.Run("Unknown User", func(t *testing.T) {
+		assert.ErrorIs(t, userDao.Restore(context.Background(), 999), gorm.ErrRecordNotFound)
+	})
+
+	t.Run("Listing Deleted Users", func(t *testing.T) {
+		assert.NoError(t, userDao.Delete(context.Background(), user.ID, 0))
+
+		page, err := userDao.GetAll(context.Background(), pagination.Request{Limit: 10}, Search{})
+		assert.NoError(t, err)
+		assert.Empty(t, page.Items)
+
+		page, err = userDao.GetAll(context.Background(), pagination.Request{Limit: 10, IncludeDeleted: true}, Search{})
+		assert.NoError(t, err)
+		assert.Len(t, page.Items, 2)
 	})
 }
@@ -0,0 +1,99 @@
+package dao
+
+import (
+	"context"
+	"golang/encryption"
+	"golang/models"
+
+	"gorm.io/gorm"
+)
+
+const vaultBatchSize = 100
+
+type IVaultDao interface {
+	CreateEntry(ctx context.Context, entry *models.VaultEntry) error
+	FindEntry(ctx context.Context, token string) (*models.VaultEntry, error)
+	CreateAuditEntry(ctx context.Context, entry *models.VaultAuditEntry) error
+}
+
+type VaultDao struct {
+	db *gorm.DB
+}
+
+func NewVaultDao(db *gorm.DB) *VaultDao {
+	return &VaultDao{db: db}
+}
+
+func (v *VaultDao) CreateEntry(ctx context.Context, entry *models.VaultEntry) error {
+	return v.db.WithContext(ctx).Create(entry).Error
+}
+
+func (v *VaultDao) FindEntry(ctx context.Context, token string) (*models.VaultEntry, error) {
+	var entry models.VaultEntry
+	err := v.db.WithContext(ctx).First(&entry, "token = ?", token).Error
+	return &entry, err
+}
+
+func (v *VaultDao) CreateAuditEntry(ctx context.Context, entry *models.VaultAuditEntry) error {
+	return v.db.WithContext(ctx).Create(entry).Error
+}
+
+// rawVaultNumber reads vault_entries.number without going through the
+// encrypted serializer.
+type rawVaultNumber struct {
+	ID     uint
+	Number string
+}
+
+// ReencryptVaultNumbers rewraps the data key of every vault number under the
+// active master key of to. Rows already wrapped with that key are skipped.
+func ReencryptVaultNumbers(db *gorm.DB, from, to encryption.KeyProvider) (int, error) {
+	return rewriteVaultNumbers(db, func(number string) (string, bool, error) {
+		if !encryption.IsEncrypted(number) {
+			return "", false, nil
+		}
+		keyID, err := encryption.KeyIDOf(number)
+		if err != nil || keyID == to.KeyID() {
+			return "", false, err
+		}
+		rewrapped, err := encryption.Rewrap(number, from, to)
+		return rewrapped, true, err
+	})
+}
+
+func rewriteVaultNumbers(db *gorm.DB, rewrite func(string) (string, bool, error)) (int, error) {
+	changed := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var lastID uint
+		for {
+			var rows []rawVaultNumber
+			err := tx.Table("vault_entries").Select("id", "number").
+				Where("id > ?", lastID).Order("id").Limit(vaultBatchSize).
+				Scan(&rows).Error
+			if err != nil {
+				return err
+			}
+			if len(rows) == 0 {
+				return nil
+			}
+
+			for _, row := range rows {
+				lastID = row.ID
+				number, ok, err := rewrite(row.Number)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					continue
+				}
+				err = tx.Table("vault_entries").Where("id = ?", row.ID).
+					Update("number", number).Error
+				if err != nil {
+					return err
+				}
+				changed++
+			}
+		}
+	})
+	return changed, err
+}
@@ -0,0 +1,76 @@
+package dao
+
+import (
+	"context"
+	"golang/encryption"
+	"golang/models"
+	"log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func storedVaultNumber(t *testing.T, db *gorm.DB, token string) string {
+	var number string
+	err := db.Table("vault_entries").Select("number").Where("token = ?", token).Row().Scan(&number)
+	assert.NoError(t, err)
+	return number
+}
+
+func TestVaultDao_Entries(t *testing.T) {
+	db := SetupTestDB(t)
+	defer func() {
+		sqlDB, err := db.DB()
+		if err != nil {
+			log.Fatalf("Failed to get DB from GORM: %v", err)
+		}
+		sqlDB.Close()
+	}()
+
+	vaultDao := NewVaultDao(db)
+
+	entry := &models.VaultEntry{Token: "tok_test", Number: "4111111111111111"}
+	err := vaultDao.CreateEntry(context.Background(), entry)
+	assert.NoError(t, err)
+
+	t.Run("Stored Encrypted", func(t *testing.T) {
+		stored := storedVaultNumber(t, db, "tok_test")
+		assert.True(t, encryption.IsEncrypted(stored))
+		assert.NotContains(t, stored, "4111111111111111")
+	})
+
+	t.Run("Find", func(t *testing.T) {
+		found, err := vaultDao.FindEntry(context.Background(), "tok_test")
+		assert.NoError(t, err)
+		assert.Equal(t, "4111111111111111", found.Number)
+	})
+
+	t.Run("Find Unknown Token", func(t *testing.T) {
+		_, err := vaultDao.FindEntry(context.Background(), "tok_missing")
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	})
+
+	t.Run("Reencrypt Under New Master Key", func(t *testing.T) {
+		from, err := encryption.CurrentKeyProvider()
+		assert.NoError(t, err)
+		to := NewTestKeyProvider(t, "rotated")
+
+		count, err := ReencryptVaultNumbers(db, from, to)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		keyID, err := encryption.KeyIDOf(storedVaultNumber(t, db, "tok_test"))
+		assert.NoError(t, err)
+		assert.Equal(t, "rotated", keyID)
+
+		encryption.SetKeyProvider(to)
+		found, err := vaultDao.FindEntry(context.Background(), "tok_test")
+		assert.NoError(t, err)
+		assert.Equal(t, "4111111111111111", found.Number)
+
+		count, err = ReencryptVaultNumbers(db, to, to)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+}
@@ -0,0 +1,29 @@
+package dao
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrVersionConflict is returned when a versioned write expected a version
+// that is no longer the stored one.
+var ErrVersionConflict = errors.New("record was modified concurrently")
+
+// versionedResult turns the outcome of a write guarded by "version = ?" into
+// an error. A write that touched no rows either lost against a concurrent
+// writer or addressed a record that does not exist.
+func versionedResult(db *gorm.DB, model interface{}, result *gorm.DB, conds ...interface{}) error {
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+
+	var count int64
+	if err := db.Model(model).Where(conds[0], conds[1:]...).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return ErrVersionConflict
+}
@@ -0,0 +1,98 @@
+package dto
+
+import (
+	"golang/models"
+)
+
+type Address struct {
+	Line1      string `json:"line1"`
+	Line2      string `json:"line2"`
+	City       string `json:"city"`
+	State      string `json:"state"`
+	PostalCode string `json:"postal_code"`
+	Country    string `json:"country" binding:"omitempty,len=2"`
+}
+
+type CardRequest struct {
+	Number         string  `json:"number" binding:"required"`
+	ExpMonth       int     `json:"exp_month" binding:"required"`
+	ExpYear        int     `json:"exp_year" binding:"required"`
+	HolderName     string  `json:"holder_name"`
+	BillingAddress Address `json:"billing_address"`
+	IsDefault      bool    `json:"is_default"`
+}
+
+// CardResponse never carries the card number. The vault token is only shown
+// to administrators, who need it to detokenize.
+type CardResponse struct {
+	ID             uint64  `json:"id"`
+	Brand          string  `json:"brand"`
+	Last4          string  `json:"last4"`
+	ExpMonth       int     `json:"exp_month"`
+	ExpYear        int     `json:"exp_year"`
+	HolderName     string  `json:"holder_name,omitempty"`
+	BillingAddress Address `json:"billing_address"`
+	IsDefault      bool    `json:"is_default"`
+	Token          string  `json:"token,omitempty"`
+}
+
+func (r CardRequest) ToModel() models.CreditCard {
+	return models.CreditCard{
+		Number:     r.Number,
+		ExpMonth:   r.ExpMonth,
+		ExpYear:    r.ExpYear,
+		HolderName: r.HolderName,
+		BillingAddress: models.Address{
+			Line1:      r.BillingAddress.Line1,
+			Line2:      r.BillingAddress.Line2,
+			City:       r.BillingAddress.City,
+			State:      r.BillingAddress.State,
+			PostalCode: r.BillingAddress.PostalCode,
+			Country:    r.BillingAddress.Country,
+		},
+		IsDefault: r.IsDefault,
+	}
+}
+
+func NewCardResponse(card *models.CreditCard, view View) CardResponse {
+	response := CardResponse{
+		ID:         uint64(card.ID),
+		Brand:      card.Brand,
+		Last4:      card.Last4,
+		ExpMonth:   card.ExpMonth,
+		ExpYear:    card.ExpYear,
+		HolderName: card.HolderName,
+		BillingAddress: Address{
+			Line1:      card.BillingAddress.Line1,
+			Line2:      card.BillingAddress.Line2,
+			City:       card.BillingAddress.City,
+			State:      card.BillingAddress.State,
+			PostalCode: card.BillingAddress.PostalCode,
+			Country:    card.BillingAddress.Country,
+		},
+		IsDefault: card.IsDefault,
+	}
+	if view == ViewAdmin {
+		response.Token = card.Token
+	}
+	return response
+}
+
+func NewCardResponses(cards []models.CreditCard, view View) []CardResponse {
+	responses := make([]CardResponse, 0, len(cards))
+	for i := range cards {
+		responses = append(responses, NewCardResponse(&cards[i], view))
+	}
+	return responses
+}
+
+func cardModels(requests []CardRequest) []models.CreditCard {
+	if len(requests) == 0 {
+		return nil
+	}
+	cards := make([]models.CreditCard, 0, len(requests))
+	for _, request := range requests {
+		cards = append(cards, request.ToModel())
+	}
+	return cards
+}
@@ -0,0 +1,34 @@
+package dto
+
+import (
+	"golang/models"
+	"time"
+)
+
+// EraseRequest chooses how POST /users/:id/erase removes a user. The mode
+// defaults to anonymize.
+type EraseRequest struct {
+	Mode string `json:"mode" binding:"omitempty,oneof=anonymize delete"`
+}
+
+// VaultAccessEntry is a detokenize attempt as shown in a data export. The
+// client IP is only included for the user's own attempts.
+type VaultAccessEntry struct {
+	At       time.Time `json:"at"`
+	ActorID  uint64    `json:"actor_id"`
+	Reason   string    `json:"reason"`
+	ClientIP string    `json:"client_ip,omitempty"`
+	Success  bool      `json:"success"`
+}
+
+func NewVaultAccessEntries(entries []models.VaultAuditEntry, userID uint64) []VaultAccessEntry {
+	responses := make([]VaultAccessEntry, 0, len(entries))
+	for _, entry := range entries {
+		response := VaultAccessEntry{At: entry.CreatedAt, ActorID: entry.ActorID, Reason: entry.Reason, Success: entry.Success}
+		if entry.ActorID == userID {
+			response.ClientIP = entry.ClientIP
+		}
+		responses = append(responses, response)
+	}
+	return responses
+}
@@ -0,0 +1,147 @@
+package dto
+
+import (
+	"golang/filter"
+	"golang/models"
+	"strconv"
+	"time"
+)
+
+const exportTimeLayout = time.RFC3339Nano
+
+// UserExport is a user as written to export files. It deliberately has no
+// password or card fields.
+type UserExport struct {
+	ID        uint64    `json:"id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	Version   uint64    `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type NoteExport struct {
+	ID        uint64    `json:"id"`
+	UserID    uint64    `json:"user_id"`
+	Name      string    `json:"name"`
+	Content   string    `json:"content"`
+	Version   uint64    `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type userParquetRow struct {
+	ID        int64  `parquet:"name=id, type=INT64, convertedtype=UINT_64"`
+	Username  string `parquet:"name=username, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Role      string `parquet:"name=role, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Version   int64  `parquet:"name=version, type=INT64, convertedtype=UINT_64"`
+	CreatedAt int64  `parquet:"name=created_at, type=INT64, convertedtype=TIMESTAMP_MICROS"`
+	UpdatedAt int64  `parquet:"name=updated_at, type=INT64, convertedtype=TIMESTAMP_MICROS"`
+}
+
+type noteParquetRow struct {
+	ID        int64  `parquet:"name=id, type=INT64, convertedtype=UINT_64"`
+	UserID    int64  `parquet:"name=user_id, type=INT64, convertedtype=UINT_64"`
+	Name      string `parquet:"name=name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Content   string `parquet:"name=content, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Version   int64  `parquet:"name=version, type=INT64, convertedtype=UINT_64"`
+	CreatedAt int64  `parquet:"name=created_at, type=INT64, convertedtype=TIMESTAMP_MICROS"`
+	UpdatedAt int64  `parquet:"name=updated_at, type=INT64, convertedtype=TIMESTAMP_MICROS"`
+}
+
+// NoteExportFilterSchema extends NoteFilterSchema with the owner, since an
+// export covers the notes of every user.
+var NoteExportFilterSchema = func() filter.Schema {
+	schema := filter.Schema{
+		"user_id": {Column: "user_id", Type: filter.Int, Operators: filter.Ordered, Sortable: true},
+	}
+	for name, field := range NoteFilterSchema {
+		schema[name] = field
+	}
+	return schema
+}()
+
+func NewUserExports(users []models.User) []UserExport {
+	exports := make([]UserExport, len(users))
+	for i, user := range users {
+		exports[i] = UserExport{
+			ID:        user.ID,
+			Username:  user.Username,
+			Role:      user.Role.String(),
+			Version:   user.Version,
+			CreatedAt: user.CreatedAt,
+			UpdatedAt: user.UpdatedAt,
+		}
+	}
+	return exports
+}
+
+func NewNoteExports(notes []models.Note) []NoteExport {
+	exports := make([]NoteExport, len(notes))
+	for i, note := range notes {
+		exports[i] = NoteExport{
+			ID:        note.ID,
+			UserID:    note.UserID,
+			Name:      note.Name,
+			Content:   note.Content,
+			Version:   note.Version,
+			CreatedAt: note.CreatedAt,
+			UpdatedAt: note.UpdatedAt,
+		}
+	}
+	return exports
+}
+
+func (UserExport) Header() []string {
+	return []string{"id", "username", "role", "version", "created_at", "updated_at"}
+}
+
+func (u UserExport) Values() []string {
+	return []string{
+		strconv.FormatUint(u.ID, 10),
+		u.Username,
+		u.Role,
+		strconv.FormatUint(u.Version, 10),
+		u.CreatedAt.Format(exportTimeLayout),
+		u.UpdatedAt.Format(exportTimeLayout),
+	}
+}
+
+func (NoteExport) Header() []string {
+	return []string{"id", "user_id", "name", "content", "version", "created_at", "updated_at"}
+}
+
+func (n NoteExport) Values() []string {
+	return []string{
+		strconv.FormatUint(n.ID, 10),
+		strconv.FormatUint(n.UserID, 10),
+		n.Name,
+		n.Content,
+		strconv.FormatUint(n.Version, 10),
+		n.CreatedAt.Format(exportTimeLayout),
+		n.UpdatedAt.Format(exportTimeLayout),
+	}
+}
+
+func (u UserExport) ParquetRow() interface{} {
+	return &userParquetRow{
+		ID:        int64(u.ID),
+		Username:  u.Username,
+		Role:      u.Role,
+		Version:   int64(u.Version),
+		CreatedAt: u.CreatedAt.UnixMicro(),
+		UpdatedAt: u.UpdatedAt.UnixMicro(),
+	}
+}
+
+func (n NoteExport) ParquetRow() interface{} {
+	return &noteParquetRow{
+		ID:        int64(n.ID),
+		UserID:    int64(n.UserID),
+		Name:      n.Name,
+		Content:   n.Content,
+		Version:   int64(n.Version),
+		CreatedAt: n.CreatedAt.UnixMicro(),
+		UpdatedAt: n.UpdatedAt.UnixMicro(),
+	}
+}
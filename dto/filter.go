@@ -0,0 +1,37 @@
+package dto
+
+import (
+	"golang/filter"
+	"golang/models"
+)
+
+// UserFilterSchema lists what users can be filtered and sorted by. Only
+// administrators may use fields the public view does not show.
+func UserFilterSchema(current *models.User) filter.Schema {
+	schema := filter.Schema{
+		"id":       {Column: "id", Type: filter.Int, Operators: filter.Ordered, Sortable: true},
+		"username": {Column: "username", Type: filter.String, Operators: filter.Text, Sortable: true},
+	}
+	if current == nil || current.Role != models.RoleAdmin {
+		return schema
+	}
+
+	schema["role"] = filter.Field{Column: "role", Type: filter.Enum, Operators: filter.Equality, Sortable: true,
+		Values: map[string]int64{
+			models.RoleAdmin.String(): int64(models.RoleAdmin),
+			models.RoleUser.String():  int64(models.RoleUser),
+		}}
+	schema["created_at"] = filter.Field{Column: "created_at", Type: filter.Time, Operators: filter.Ordered, Sortable: true}
+	schema["updated_at"] = filter.Field{Column: "updated_at", Type: filter.Time, Operators: filter.Ordered, Sortable: true}
+	return schema
+}
+
+// NoteFilterSchema lists what notes can be filtered and sorted by. Notes are
+// only ever listed for their owner or an administrator.
+var NoteFilterSchema = filter.Schema{
+	"id":         {Column: "id", Type: filter.Int, Operators: filter.Ordered, Sortable: true},
+	"name":       {Column: "name", Type: filter.String, Operators: filter.Text, Sortable: true},
+	"content":    {Column: "content", Type: filter.String, Operators: []filter.Operator{filter.Contains}},
+	"created_at": {Column: "created_at", Type: filter.Time, Operators: filter.Ordered, Sortable: true},
+	"updated_at": {Column: "updated_at", Type: filter.Time, Operators: filter.Ordered, Sortable: true},
+}
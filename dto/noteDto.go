@@ -0,0 +1,22 @@
+package dto
+
+import (
+	"golang/models"
+)
+
+type NoteRequest struct {
+	Name    string `json:"name" binding:"required,max=255"`
+	Content string `json:"content"`
+}
+
+func (r NoteRequest) ToModel() models.Note {
+	return models.Note{Name: r.Name, Content: r.Content}
+}
+
+func NewNoteResponses(notes []models.Note) []NoteResponse {
+	responses := make([]NoteResponse, 0, len(notes))
+	for i := range notes {
+		responses = append(responses, NewNoteResponse(&notes[i]))
+	}
+	return responses
+}
@@ -0,0 +1,133 @@
+package dto
+
+import (
+	"golang/models"
+	"time"
+)
+
+type SignupRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// CreateUserRequest creates a user with RoleUser unless an administrator
+// sets Role.
+type CreateUserRequest struct {
+	Username    string        `json:"username" binding:"required"`
+	Password    string        `json:"password" binding:"required"`
+	Role        *models.Role  `json:"role" binding:"omitempty,min=0,max=1"`
+	CreditCards []CardRequest `json:"credit_cards" binding:"dive"`
+}
+
+// UpdateUserRequest replaces a user's editable fields. The id always comes
+// from the path, and the role is kept unless an administrator sets Role.
+type UpdateUserRequest struct {
+	Username    string        `json:"username" binding:"required"`
+	Password    string        `json:"password" binding:"required"`
+	Role        *models.Role  `json:"role" binding:"omitempty,min=0,max=1"`
+	CreditCards []CardRequest `json:"credit_cards" binding:"dive"`
+}
+
+// UserResponse is the only shape a user is ever serialized in. Fields left
+// empty by the selected view are omitted.
+type UserResponse struct {
+	ID          uint64         `json:"id"`
+	Username    string         `json:"username"`
+	Role        string         `json:"role,omitempty"`
+	Version     uint64         `json:"version,omitempty"`
+	CreatedAt   *time.Time     `json:"created_at,omitempty"`
+	UpdatedAt   *time.Time     `json:"updated_at,omitempty"`
+	DeletedAt   *time.Time     `json:"deleted_at,omitempty"`
+	Notes       []NoteResponse `json:"notes,omitempty"`
+	CreditCards []CardResponse `json:"credit_cards,omitempty"`
+}
+
+type NoteResponse struct {
+	ID        uint64    `json:"id"`
+	Name      string    `json:"name"`
+	Content   string    `json:"content"`
+	Version   uint64    `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (r SignupRequest) ToModel() models.User {
+	return models.User{Username: r.Username, Password: r.Password, Role: models.RoleUser}
+}
+
+func (r CreateUserRequest) ToModel() models.User {
+	user := models.User{
+		Username:    r.Username,
+		Password:    r.Password,
+		Role:        models.RoleUser,
+		CreditCards: cardModels(r.CreditCards),
+	}
+	if r.Role != nil {
+		user.Role = *r.Role
+	}
+	return user
+}
+
+// ToModel returns the user with id, keeping role when the request sets none.
+func (r UpdateUserRequest) ToModel(id uint64, role models.Role) models.User {
+	user := models.User{
+		ID:          id,
+		Username:    r.Username,
+		Password:    r.Password,
+		Role:        role,
+		CreditCards: cardModels(r.CreditCards),
+	}
+	if r.Role != nil {
+		user.Role = *r.Role
+	}
+	return user
+}
+
+func NewUserResponse(user *models.User, view View) UserResponse {
+	response := UserResponse{ID: user.ID, Username: user.Username}
+	if view == ViewPublic {
+		return response
+	}
+
+	response.Role = user.Role.String()
+	response.Version = user.Version
+	response.CreatedAt = timePtr(user.CreatedAt)
+	response.UpdatedAt = timePtr(user.UpdatedAt)
+	for _, note := range user.Notes {
+		response.Notes = append(response.Notes, NewNoteResponse(&note))
+	}
+	for _, card := range user.CreditCards {
+		response.CreditCards = append(response.CreditCards, NewCardResponse(&card, view))
+	}
+	if view == ViewAdmin && user.DeletedAt.Valid {
+		response.DeletedAt = &user.DeletedAt.Time
+	}
+	return response
+}
+
+// NewUserResponses maps a list of users, choosing the view per user.
+func NewUserResponses(users []models.User, current *models.User) []UserResponse {
+	responses := make([]UserResponse, 0, len(users))
+	for i := range users {
+		responses = append(responses, NewUserResponse(&users[i], ViewFor(current, users[i].ID)))
+	}
+	return responses
+}
+
+func NewNoteResponse(note *models.Note) NoteResponse {
+	return NoteResponse{
+		ID:        note.ID,
+		Name:      note.Name,
+		Content:   note.Content,
+		Version:   note.Version,
+		CreatedAt: note.CreatedAt,
+		UpdatedAt: note.UpdatedAt,
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
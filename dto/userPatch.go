@@ -0,0 +1,92 @@
+package dto
+
+import (
+	"encoding/json"
+	"fmt"
+	"golang/models"
+	"reflect"
+	"sort"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+// UserPatchDocument is the JSON document PATCH /users/:id operates on. The
+// password is write-only: it is absent from the stored document and only
+// appears in the result when the patch sets it.
+type UserPatchDocument struct {
+	Username string      `json:"username" binding:"required,max=64"`
+	Password *string     `json:"password,omitempty" binding:"omitempty,min=1,max=255"`
+	Role     models.Role `json:"role" binding:"min=0,max=1"`
+}
+
+// patchableFields lists the fields each view may change.
+var patchableFields = map[View][]string{
+	ViewSelf:  {"username", "password"},
+	ViewAdmin: {"username", "password", "role"},
+}
+
+func NewUserPatchDocument(user *models.User) UserPatchDocument {
+	return UserPatchDocument{Username: user.Username, Role: user.Role}
+}
+
+// ApplyUserPatch decodes a patched document, rejects changes to fields the
+// view may not patch and validates the result. It returns the columns to
+// update.
+func ApplyUserPatch(original UserPatchDocument, patched []byte, view View) (map[string]interface{}, error) {
+	var before, after map[string]interface{}
+	originalJSON, _ := json.Marshal(original)
+	json.Unmarshal(originalJSON, &before)
+	if err := json.Unmarshal(patched, &after); err != nil {
+		return nil, fmt.Errorf("patched document must be a JSON object")
+	}
+
+	allowed := map[string]bool{}
+	for _, field := range patchableFields[view] {
+		allowed[field] = true
+	}
+	var rejected []string
+	for _, field := range changedFields(before, after) {
+		if !allowed[field] {
+			rejected = append(rejected, field)
+		}
+	}
+	if len(rejected) > 0 {
+		return nil, fmt.Errorf("fields cannot be patched: %v", rejected)
+	}
+
+	var result UserPatchDocument
+	if err := json.Unmarshal(patched, &result); err != nil {
+		return nil, err
+	}
+	if err := binding.Validator.ValidateStruct(&result); err != nil {
+		return nil, err
+	}
+
+	changes := map[string]interface{}{}
+	if result.Username != original.Username {
+		changes["username"] = result.Username
+	}
+	if result.Password != nil {
+		changes["password"] = *result.Password
+	}
+	if result.Role != original.Role {
+		changes["role"] = result.Role
+	}
+	return changes, nil
+}
+
+func changedFields(before map[string]interface{}, after map[string]interface{}) []string {
+	var fields []string
+	for key, value := range after {
+		if previous, ok := before[key]; !ok || !reflect.DeepEqual(previous, value) {
+			fields = append(fields, key)
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			fields = append(fields, key)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
@@ -0,0 +1,53 @@
+package dto
+
+import (
+	"encoding/json"
+	"golang/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestNewUserResponse(t *testing.T) {
+	created := time.Date(2026, time.January, 2, 3, 4, 5, 0, time.UTC)
+	user := &models.User{
+		Model:    gorm.Model{CreatedAt: created, UpdatedAt: created},
+		ID:       7,
+		Username: "alice",
+		Password: "secret",
+		Role:     models.RoleUser,
+		CreditCards: []models.CreditCard{
+			{Token: "tok_alice", Brand: "visa", Last4: "1111", ExpMonth: 1, ExpYear: 2030, IsDefault: true},
+		},
+	}
+
+	t.Run("Public", func(t *testing.T) {
+		body, _ := json.Marshal(NewUserResponse(user, ViewPublic))
+		assert.JSONEq(t, `{"id":7,"username":"alice"}`, string(body))
+	})
+
+	t.Run("Self", func(t *testing.T) {
+		body, _ := json.Marshal(NewUserResponse(user, ViewSelf))
+		assert.Contains(t, string(body), `"last4":"1111"`)
+		assert.NotContains(t, string(body), "tok_alice")
+		assert.NotContains(t, string(body), "secret")
+	})
+
+	t.Run("Admin", func(t *testing.T) {
+		body, _ := json.Marshal(NewUserResponse(user, ViewAdmin))
+		assert.Contains(t, string(body), "tok_alice")
+		assert.NotContains(t, string(body), "secret")
+	})
+}
+
+func TestViewFor(t *testing.T) {
+	admin := &models.User{ID: 1, Role: models.RoleAdmin}
+	user := &models.User{ID: 2, Role: models.RoleUser}
+
+	assert.Equal(t, ViewPublic, ViewFor(nil, 2))
+	assert.Equal(t, ViewAdmin, ViewFor(admin, 2))
+	assert.Equal(t, ViewSelf, ViewFor(user, 2))
+	assert.Equal(t, ViewPublic, ViewFor(user, 3))
+}
@@ -0,0 +1,31 @@
+package dto
+
+import (
+	"golang/models"
+)
+
+// View selects how much of a resource a caller may see.
+type View int
+
+const (
+	// ViewPublic is what any authenticated user may see about another user.
+	ViewPublic View = iota
+	// ViewSelf is what users see about themselves.
+	ViewSelf
+	// ViewAdmin adds bookkeeping and vault references for administrators.
+	ViewAdmin
+)
+
+// ViewFor returns the view current may have of the user with subjectID. A nil
+// current means the caller is anonymous.
+func ViewFor(current *models.User, subjectID uint64) View {
+	switch {
+	case current == nil:
+		return ViewPublic
+	case current.Role == models.RoleAdmin:
+		return ViewAdmin
+	case current.ID == subjectID:
+		return ViewSelf
+	}
+	return ViewPublic
+}
@@ -0,0 +1,118 @@
+package encryption
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestProvider(t *testing.T, keyID string) *LocalKeyProvider {
+	key := bytes.Repeat([]byte(keyID[:1]), MasterKeySize)
+	provider, err := NewLocalKeyProvider(keyID, map[string][]byte{keyID: key})
+	if err != nil {
+		t.Fatalf("Failed to create key provider: %v", err)
+	}
+	return provider
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	provider := newTestProvider(t, "a1")
+
+	t.Run("Round Trip", func(t *testing.T) {
+		value, err := Encrypt(provider, []byte("4111111111111111"))
+		assert.NoError(t, err)
+		assert.True(t, IsEncrypted(value))
+		assert.NotContains(t, value, "4111111111111111")
+
+		plaintext, err := Decrypt(provider, value)
+		assert.NoError(t, err)
+		assert.Equal(t, "4111111111111111", string(plaintext))
+	})
+
+	t.Run("Fresh Data Key Per Value", func(t *testing.T) {
+		first, err := Encrypt(provider, []byte("secret"))
+		assert.NoError(t, err)
+		second, err := Encrypt(provider, []byte("secret"))
+		assert.NoError(t, err)
+		assert.NotEqual(t, first, second)
+	})
+
+	t.Run("Unknown Master Key", func(t *testing.T) {
+		value, err := Encrypt(provider, []byte("secret"))
+		assert.NoError(t, err)
+
+		_, err = Decrypt(newTestProvider(t, "b1"), value)
+		assert.ErrorIs(t, err, ErrUnknownKey)
+	})
+
+	t.Run("Tampered Ciphertext", func(t *testing.T) {
+		value, err := Encrypt(provider, []byte("secret"))
+		assert.NoError(t, err)
+
+		_, err = Decrypt(provider, value[:len(value)-4]+"AAA=")
+		assert.Error(t, err)
+	})
+}
+
+func TestRewrap(t *testing.T) {
+	from := newTestProvider(t, "a1")
+	to := newTestProvider(t, "b1")
+
+	value, err := Encrypt(from, []byte("secret"))
+	assert.NoError(t, err)
+
+	rewrapped, err := Rewrap(value, from, to)
+	assert.NoError(t, err)
+
+	keyID, err := KeyIDOf(rewrapped)
+	assert.NoError(t, err)
+	assert.Equal(t, "b1", keyID)
+
+	plaintext, err := Decrypt(to, rewrapped)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", string(plaintext))
+}
+
+func TestFileKeyProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "master.key")
+
+	t.Run("Generate And Load", func(t *testing.T) {
+		err := GenerateKeyFile(path, "k1")
+		assert.NoError(t, err)
+
+		provider, err := NewFileKeyProvider(path)
+		assert.NoError(t, err)
+		assert.Equal(t, "k1", provider.KeyID())
+	})
+
+	t.Run("Refuse To Overwrite", func(t *testing.T) {
+		err := GenerateKeyFile(path, "k2")
+		assert.Error(t, err)
+	})
+
+	t.Run("Old Keys Still Unwrap", func(t *testing.T) {
+		old, err := NewFileKeyProvider(path)
+		assert.NoError(t, err)
+		value, err := Encrypt(old, []byte("secret"))
+		assert.NoError(t, err)
+
+		rotated := filepath.Join(t.TempDir(), "rotated.key")
+		assert.NoError(t, GenerateKeyFile(rotated, "k2"))
+		newKey, err := os.ReadFile(rotated)
+		assert.NoError(t, err)
+		oldKey, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(rotated, append(newKey, oldKey...), 0600))
+
+		provider, err := NewFileKeyProvider(rotated)
+		assert.NoError(t, err)
+		assert.Equal(t, "k2", provider.KeyID())
+
+		plaintext, err := Decrypt(provider, value)
+		assert.NoError(t, err)
+		assert.Equal(t, "secret", string(plaintext))
+	})
+}
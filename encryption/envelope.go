@@ -0,0 +1,99 @@
+package encryption
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// prefix marks a value produced by Encrypt. The full format is
+// enc:v1:<key id>:<wrapped data key>:<nonce+ciphertext>, both blobs base64.
+const prefix = "enc:v1:"
+
+var ErrMalformedCiphertext = errors.New("malformed ciphertext")
+
+// IsEncrypted reports whether value was produced by Encrypt.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// Encrypt seals plaintext under a fresh data key and wraps that data key with
+// the provider's active master key.
+func Encrypt(provider KeyProvider, plaintext []byte) (string, error) {
+	dataKey := make([]byte, MasterKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	wrapped, err := provider.WrapKey(dataKey)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := seal(dataKey, plaintext)
+	if err != nil {
+		return "", err
+	}
+	return format(provider.KeyID(), wrapped, sealed), nil
+}
+
+func Decrypt(provider KeyProvider, value string) ([]byte, error) {
+	keyID, wrapped, sealed, err := parse(value)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := provider.UnwrapKey(keyID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	return open(dataKey, sealed)
+}
+
+// Rewrap re-encrypts the data key of value under the active master key of to.
+// The payload itself is left untouched, so rotating a master key never needs
+// the plaintext.
+func Rewrap(value string, from, to KeyProvider) (string, error) {
+	keyID, wrapped, sealed, err := parse(value)
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := from.UnwrapKey(keyID, wrapped)
+	if err != nil {
+		return "", err
+	}
+	rewrapped, err := to.WrapKey(dataKey)
+	if err != nil {
+		return "", err
+	}
+	return format(to.KeyID(), rewrapped, sealed), nil
+}
+
+// KeyIDOf returns the id of the master key value is currently wrapped with.
+func KeyIDOf(value string) (string, error) {
+	keyID, _, _, err := parse(value)
+	return keyID, err
+}
+
+func format(keyID string, wrapped, sealed []byte) string {
+	return prefix + keyID + ":" +
+		base64.StdEncoding.EncodeToString(wrapped) + ":" +
+		base64.StdEncoding.EncodeToString(sealed)
+}
+
+func parse(value string) (string, []byte, []byte, error) {
+	if !IsEncrypted(value) {
+		return "", nil, nil, ErrMalformedCiphertext
+	}
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return "", nil, nil, ErrMalformedCiphertext
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, nil, ErrMalformedCiphertext
+	}
+	sealed, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, nil, ErrMalformedCiphertext
+	}
+	return parts[0], wrapped, sealed, nil
+}
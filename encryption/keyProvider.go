@@ -0,0 +1,154 @@
+package encryption
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// MasterKeySize is the length in bytes of a master key (AES-256).
+const MasterKeySize = 32
+
+var ErrUnknownKey = errors.New("unknown master key")
+
+// KeyProvider wraps and unwraps data keys with a master key. The file based
+// provider below is meant for local development; a KMS backed provider only
+// has to implement this interface to be plugged in.
+type KeyProvider interface {
+	// KeyID identifies the master key new data keys are wrapped with.
+	KeyID() string
+	WrapKey(dataKey []byte) ([]byte, error)
+	UnwrapKey(keyID string, wrapped []byte) ([]byte, error)
+}
+
+// LocalKeyProvider holds master keys in memory. The first key is the active
+// one, the others are only used to unwrap data keys written before a rotation.
+type LocalKeyProvider struct {
+	activeID string
+	keys     map[string][]byte
+}
+
+func NewLocalKeyProvider(activeID string, keys map[string][]byte) (*LocalKeyProvider, error) {
+	if _, ok := keys[activeID]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, activeID)
+	}
+	for id, key := range keys {
+		if id == "" || strings.ContainsAny(id, ": \t") {
+			return nil, fmt.Errorf("invalid master key id %q", id)
+		}
+		if len(key) != MasterKeySize {
+			return nil, fmt.Errorf("master key %s must be %d bytes", id, MasterKeySize)
+		}
+	}
+	return &LocalKeyProvider{activeID: activeID, keys: keys}, nil
+}
+
+// NewFileKeyProvider reads master keys from a file with one "id:base64key"
+// entry per line. The first entry is the active key.
+func NewFileKeyProvider(path string) (*LocalKeyProvider, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var activeID string
+	keys := map[string][]byte{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, encoded, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid line in master key file %s", path)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("master key %s: %w", id, err)
+		}
+		if activeID == "" {
+			activeID = id
+		}
+		keys[id] = key
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if activeID == "" {
+		return nil, fmt.Errorf("no master key found in %s", path)
+	}
+	return NewLocalKeyProvider(activeID, keys)
+}
+
+// GenerateKeyFile writes a new random master key to path, refusing to
+// overwrite an existing file.
+func GenerateKeyFile(path string, keyID string) error {
+	key := make([]byte, MasterKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = fmt.Fprintf(file, "%s:%s\n", keyID, base64.StdEncoding.EncodeToString(key))
+	return err
+}
+
+func (p *LocalKeyProvider) KeyID() string {
+	return p.activeID
+}
+
+func (p *LocalKeyProvider) WrapKey(dataKey []byte) ([]byte, error) {
+	return seal(p.keys[p.activeID], dataKey)
+}
+
+func (p *LocalKeyProvider) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	key, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+	return open(key, wrapped)
+}
+
+// seal encrypts plaintext with AES-GCM and prepends the random nonce.
+func seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(key, sealed []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,85 @@
+package encryption
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"gorm.io/gorm/schema"
+)
+
+var ErrNoKeyProvider = errors.New("encryption key provider is not configured")
+
+var (
+	mu       sync.RWMutex
+	provider KeyProvider
+)
+
+func init() {
+	schema.RegisterSerializer("encrypted", Serializer{})
+}
+
+// SetKeyProvider sets the provider used by the "encrypted" GORM serializer.
+func SetKeyProvider(p KeyProvider) {
+	mu.Lock()
+	defer mu.Unlock()
+	provider = p
+}
+
+func CurrentKeyProvider() (KeyProvider, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if provider == nil {
+		return nil, ErrNoKeyProvider
+	}
+	return provider, nil
+}
+
+// Serializer encrypts string fields tagged with `gorm:"serializer:encrypted"`.
+// Values that are not in the encrypted format are read back as-is so rows
+// written before the column was encrypted stay readable until migrated.
+type Serializer struct{}
+
+func (Serializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var value string
+	switch v := dbValue.(type) {
+	case nil:
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	default:
+		return fmt.Errorf("unsupported type %T for encrypted field %s", dbValue, field.Name)
+	}
+
+	if IsEncrypted(value) {
+		p, err := CurrentKeyProvider()
+		if err != nil {
+			return err
+		}
+		plaintext, err := Decrypt(p, value)
+		if err != nil {
+			return fmt.Errorf("decrypting %s: %w", field.Name, err)
+		}
+		value = string(plaintext)
+	}
+
+	return field.Set(ctx, dst, value)
+}
+
+func (Serializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	value, ok := fieldValue.(string)
+	if !ok {
+		return nil, fmt.Errorf("encrypted field %s must be a string", field.Name)
+	}
+	if value == "" {
+		return "", nil
+	}
+	p, err := CurrentKeyProvider()
+	if err != nil {
+		return nil, err
+	}
+	return Encrypt(p, []byte(value))
+}
@@ -0,0 +1,162 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+type Format string
+
+const (
+	CSV       Format = "csv"
+	JSONLines Format = "jsonl"
+	Parquet   Format = "parquet"
+)
+
+// Record is a row that can be exported. JSON Lines uses the record's json
+// tags, CSV uses Header and Values and Parquet writes the struct returned by
+// ParquetRow, which must carry parquet tags even on a zero Record.
+type Record interface {
+	Header() []string
+	Values() []string
+	ParquetRow() interface{}
+}
+
+// ParseFormat reads the format query parameter. An empty value means CSV.
+func ParseFormat(value string) (Format, error) {
+	switch Format(value) {
+	case "", CSV:
+		return CSV, nil
+	case JSONLines, Parquet:
+		return Format(value), nil
+	}
+	return "", fmt.Errorf("unknown export format %q", value)
+}
+
+func (f Format) ContentType() string {
+	switch f {
+	case JSONLines:
+		return "application/x-ndjson"
+	case Parquet:
+		return "application/vnd.apache.parquet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Writer writes records in batches. Nothing but the current batch is kept in
+// memory, so an export of any size can be streamed. Close must be called to
+// complete the file.
+type Writer[T Record] interface {
+	Write(records []T) error
+	Close() error
+}
+
+// NewWriter returns a writer for format. Nothing is written to w before the
+// first call to Write or Close.
+func NewWriter[T Record](format Format, w io.Writer) Writer[T] {
+	switch format {
+	case JSONLines:
+		return &jsonLinesWriter[T]{out: bufio.NewWriter(w)}
+	case Parquet:
+		return &parquetRecordWriter[T]{out: w}
+	}
+	return &csvWriter[T]{writer: csv.NewWriter(w)}
+}
+
+type csvWriter[T Record] struct {
+	writer      *csv.Writer
+	wroteHeader bool
+}
+
+func (c *csvWriter[T]) Write(records []T) error {
+	for _, record := range records {
+		if !c.wroteHeader {
+			if err := c.writer.Write(record.Header()); err != nil {
+				return err
+			}
+			c.wroteHeader = true
+		}
+		if err := c.writer.Write(record.Values()); err != nil {
+			return err
+		}
+	}
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+func (c *csvWriter[T]) Close() error {
+	if !c.wroteHeader {
+		var empty T
+		if err := c.writer.Write(empty.Header()); err != nil {
+			return err
+		}
+	}
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+type jsonLinesWriter[T Record] struct {
+	out *bufio.Writer
+}
+
+func (j *jsonLinesWriter[T]) Write(records []T) error {
+	encoder := json.NewEncoder(j.out)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return j.out.Flush()
+}
+
+func (j *jsonLinesWriter[T]) Close() error {
+	return j.out.Flush()
+}
+
+// parquetRecordWriter writes every batch as its own row group, so only one
+// batch is buffered at a time.
+type parquetRecordWriter[T Record] struct {
+	out    io.Writer
+	writer *writer.ParquetWriter
+}
+
+// start writes the file header. It is delayed until there is something to
+// write so that a failing query can still be answered with an error status.
+func (p *parquetRecordWriter[T]) start() error {
+	if p.writer != nil {
+		return nil
+	}
+	var empty T
+	parquetWriter, err := writer.NewParquetWriterFromWriter(p.out, empty.ParquetRow(), 1)
+	if err != nil {
+		return err
+	}
+	parquetWriter.CompressionType = parquet.CompressionCodec_SNAPPY
+	p.writer = parquetWriter
+	return nil
+}
+
+func (p *parquetRecordWriter[T]) Write(records []T) error {
+	if err := p.start(); err != nil {
+		return err
+	}
+	for _, record := range records {
+		if err := p.writer.Write(record.ParquetRow()); err != nil {
+			return err
+		}
+	}
+	return p.writer.Flush(true)
+}
+
+func (p *parquetRecordWriter[T]) Close() error {
+	if err := p.start(); err != nil {
+		return err
+	}
+	return p.writer.WriteStop()
+}
@@ -0,0 +1,97 @@
+package export
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+type testRecord struct {
+	ID   uint64 `json:"id"`
+	Name string `json:"name"`
+}
+
+type testParquetRow struct {
+	ID   int64  `parquet:"name=id, type=INT64"`
+	Name string `parquet:"name=name, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+func (testRecord) Header() []string {
+	return []string{"id", "name"}
+}
+
+func (r testRecord) Values() []string {
+	return []string{strconv.FormatUint(r.ID, 10), r.Name}
+}
+
+func (r testRecord) ParquetRow() interface{} {
+	return &testParquetRow{ID: int64(r.ID), Name: r.Name}
+}
+
+func writeAll(t *testing.T, format Format, batches ...[]testRecord) []byte {
+	var out bytes.Buffer
+	writer := NewWriter[testRecord](format, &out)
+	for _, batch := range batches {
+		assert.NoError(t, writer.Write(batch))
+	}
+	assert.NoError(t, writer.Close())
+	return out.Bytes()
+}
+
+func TestParseFormat(t *testing.T) {
+	for value, expected := range map[string]Format{"": CSV, "csv": CSV, "jsonl": JSONLines, "parquet": Parquet} {
+		format, err := ParseFormat(value)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, format)
+	}
+	_, err := ParseFormat("xlsx")
+	assert.EqualError(t, err, `unknown export format "xlsx"`)
+}
+
+func TestWriter(t *testing.T) {
+	first := []testRecord{{ID: 1, Name: "alice"}, {ID: 2, Name: "bob, jr."}}
+	second := []testRecord{{ID: 3, Name: `"carol"`}}
+
+	t.Run("CSV", func(t *testing.T) {
+		assert.Equal(t, "id,name\n1,alice\n2,\"bob, jr.\"\n3,\"\"\"carol\"\"\"\n", string(writeAll(t, CSV, first, second)))
+	})
+
+	t.Run("Empty CSV Has A Header", func(t *testing.T) {
+		assert.Equal(t, "id,name\n", string(writeAll(t, CSV)))
+	})
+
+	t.Run("JSON Lines", func(t *testing.T) {
+		lines := strings.Split(strings.TrimSpace(string(writeAll(t, JSONLines, first, second))), "\n")
+		assert.Equal(t, []string{`{"id":1,"name":"alice"}`, `{"id":2,"name":"bob, jr."}`, `{"id":3,"name":"\"carol\""}`}, lines)
+	})
+
+	t.Run("Nothing Written Before First Batch", func(t *testing.T) {
+		for _, format := range []Format{CSV, JSONLines, Parquet} {
+			var out bytes.Buffer
+			NewWriter[testRecord](format, &out)
+			assert.Zero(t, out.Len(), format)
+		}
+	})
+
+	t.Run("Parquet", func(t *testing.T) {
+		data := writeAll(t, Parquet, first, second)
+		file, err := buffer.NewBufferFile(data)
+		assert.NoError(t, err)
+		parquetReader, err := reader.NewParquetReader(file, new(testParquetRow), 1)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer parquetReader.ReadStop()
+
+		// One row group per batch.
+		assert.Len(t, parquetReader.Footer.RowGroups, 2)
+		rows := make([]testParquetRow, parquetReader.GetNumRows())
+		assert.NoError(t, parquetReader.Read(&rows))
+		assert.Equal(t, []testParquetRow{{1, "alice"}, {2, "bob, jr."}, {3, `"carol"`}}, rows)
+	})
+}
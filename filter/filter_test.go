@@ -0,0 +1,155 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type record struct {
+	ID        uint64
+	Name      string
+	Score     int64
+	Active    bool
+	Kind      int64
+	CreatedAt string
+}
+
+var testSchema = Schema{
+	"id":         {Column: "id", Type: Int, Operators: Ordered, Sortable: true},
+	"name":       {Column: "name", Type: String, Operators: Text, Sortable: true},
+	"score":      {Column: "score", Type: Int, Operators: Ordered},
+	"active":     {Column: "active", Type: Bool, Operators: Equality},
+	"kind":       {Column: "kind", Type: Enum, Operators: Equality, Values: map[string]int64{"Small": 0, "Large": 1}},
+	"created_at": {Column: "created_at", Type: Time, Operators: Ordered, Sortable: true},
+}
+
+func TestParse(t *testing.T) {
+	t.Run("And Binds Tighter Than Or", func(t *testing.T) {
+		node, err := Parse("a eq 1 or b eq 2 and c eq 3")
+		assert.NoError(t, err)
+		assert.Equal(t, Logical{Or: true, Nodes: []Node{
+			Comparison{Field: "a", Operator: Eq, Values: []string{"1"}},
+			Logical{Nodes: []Node{
+				Comparison{Field: "b", Operator: Eq, Values: []string{"2"}},
+				Comparison{Field: "c", Operator: Eq, Values: []string{"3"}},
+			}},
+		}}, node)
+	})
+
+	t.Run("Parentheses, Not And In", func(t *testing.T) {
+		node, err := Parse("NOT (a IN (1, 'two words', \"it's\")) AND b contains 'o''clock'")
+		assert.NoError(t, err)
+		assert.Equal(t, Logical{Nodes: []Node{
+			Not{Node: Comparison{Field: "a", Operator: In, Values: []string{"1", "two words", "it's"}}},
+			Comparison{Field: "b", Operator: Contains, Values: []string{"o'clock"}},
+		}}, node)
+	})
+
+	errors := []string{
+		"",
+		"a eq",
+		"a like 1",
+		"a eq 1 and",
+		"(a eq 1",
+		"a eq 1)",
+		"a in 1",
+		"a in (1 2)",
+		"a eq 'open",
+		"eq eq",
+		"((((((((((((((((((a eq 1))))))))))))))))))",
+	}
+	for _, expression := range errors {
+		t.Run("Invalid "+expression, func(t *testing.T) {
+			_, err := Parse(expression)
+			var filterErr *Error
+			assert.ErrorAs(t, err, &filterErr)
+		})
+	}
+}
+
+func TestSchema_Parse(t *testing.T) {
+	t.Run("Sort", func(t *testing.T) {
+		query, err := testSchema.Parse("", "-created_at, name")
+		assert.NoError(t, err)
+		assert.Nil(t, query.Where)
+		assert.Len(t, query.Order, 2)
+		assert.Equal(t, "created_at", query.Order[0].Column)
+		assert.True(t, query.Order[0].Desc)
+		assert.Equal(t, "name", query.Order[1].Column)
+		assert.False(t, query.Order[1].Desc)
+	})
+
+	invalid := []struct {
+		name   string
+		filter string
+		sort   string
+	}{
+		{"Unknown Field", "password eq x", ""},
+		{"Operator Not Allowed", "active gt true", ""},
+		{"Contains On Number", "score contains 1", ""},
+		{"Bad Number", "score eq many", ""},
+		{"Bad Bool", "active eq maybe", ""},
+		{"Bad Time", "created_at gt yesterday", ""},
+		{"Unknown Enum Value", "kind eq Medium", ""},
+		{"Unsortable Field", "", "score"},
+		{"Unknown Sort Field", "", "password"},
+		{"Sorted Twice", "", "name,-name"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := testSchema.Parse(tt.filter, tt.sort)
+			var filterErr *Error
+			assert.ErrorAs(t, err, &filterErr)
+		})
+	}
+}
+
+func TestSchema_SQL(t *testing.T) {
+	sqliteDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{DryRun: true})
+	assert.NoError(t, err)
+	postgresDB, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	assert.NoError(t, err)
+
+	render := func(db *gorm.DB, expression string) string {
+		query, err := testSchema.Parse(expression, "")
+		assert.NoError(t, err)
+		var records []record
+		statement := db.Where(query.Where).Find(&records).Statement
+		return statement.SQL.String()
+	}
+
+	tests := []struct {
+		filter   string
+		sqlite   string
+		postgres string
+	}{
+		{
+			"kind eq Large and (score ge 10 or not active eq true)",
+			"SELECT * FROM `records` WHERE (`records`.`kind` = ? AND (`records`.`score` >= ? OR NOT (`records`.`active` = ?)))",
+			`SELECT * FROM "records" WHERE ("records"."kind" = $1 AND ("records"."score" >= $2 OR NOT ("records"."active" = $3)))`,
+		},
+		{
+			"name contains '50%_off!' or id in (1, 2)",
+			"SELECT * FROM `records` WHERE (LOWER(`records`.`name`) LIKE ? ESCAPE '!' OR `records`.`id` IN (?,?))",
+			`SELECT * FROM "records" WHERE (LOWER("records"."name") LIKE $1 ESCAPE '!' OR "records"."id" IN ($2,$3))`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			assert.Equal(t, tt.sqlite, render(sqliteDB, tt.filter))
+			assert.Equal(t, tt.postgres, render(postgresDB, tt.filter))
+		})
+	}
+
+	t.Run("Escaped Pattern", func(t *testing.T) {
+		query, err := testSchema.Parse("name startswith '50%_Off!'", "")
+		assert.NoError(t, err)
+		var records []record
+		statement := sqliteDB.Where(query.Where).Find(&records).Statement
+		assert.Equal(t, []interface{}{"50!%!_off!!%"}, statement.Vars)
+	})
+}
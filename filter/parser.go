@@ -0,0 +1,293 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+const (
+	maxExpressionLength = 1024
+	maxDepth            = 16
+	maxInValues         = 100
+)
+
+// Error describes a filter or sort expression that cannot be used. Callers
+// usually answer it with 400 Bad Request.
+type Error struct {
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return "invalid filter: " + e.Reason
+}
+
+type Operator string
+
+const (
+	Eq         Operator = "eq"
+	Ne         Operator = "ne"
+	Gt         Operator = "gt"
+	Ge         Operator = "ge"
+	Lt         Operator = "lt"
+	Le         Operator = "le"
+	In         Operator = "in"
+	Contains   Operator = "contains"
+	StartsWith Operator = "startswith"
+)
+
+var operators = map[string]Operator{
+	"eq": Eq, "ne": Ne, "gt": Gt, "ge": Ge, "lt": Lt, "le": Le,
+	"in": In, "contains": Contains, "startswith": StartsWith,
+}
+
+// Node is an element of a parsed filter expression.
+type Node interface {
+	node()
+}
+
+// Comparison compares a field with one value, or with a list of values for
+// the in operator. Values are still the raw text of the expression.
+type Comparison struct {
+	Field    string
+	Operator Operator
+	Values   []string
+}
+
+// Logical joins two or more nodes with "and" or "or".
+type Logical struct {
+	Or    bool
+	Nodes []Node
+}
+
+type Not struct {
+	Node Node
+}
+
+func (Comparison) node() {}
+func (Logical) node()    {}
+func (Not) node()        {}
+
+// Parse turns a filter expression such as
+//
+//	role eq RoleAdmin and (username startswith 'jo' or created_at gt 2026-01-01)
+//
+// into its AST. "and" binds tighter than "or"; keywords are case-insensitive
+// and values containing spaces or parentheses are quoted with ' or ".
+func Parse(expression string) (Node, error) {
+	if len(expression) > maxExpressionLength {
+		return nil, &Error{Reason: fmt.Sprintf("expression is longer than %d characters", maxExpressionLength)}
+	}
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	node, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, &Error{Reason: fmt.Sprintf("unexpected %q", p.tokens[p.pos].text)}
+	}
+	return node, nil
+}
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenString
+	tokenOpen
+	tokenClose
+	tokenComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func tokenize(expression string) ([]token, error) {
+	var tokens []token
+	runes := []rune(expression)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenOpen, text: "("})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenClose, text: ")"})
+			i++
+		case r == ',':
+			tokens = append(tokens, token{kind: tokenComma, text: ","})
+			i++
+		case r == '\'' || r == '"':
+			// A doubled quote inside a quoted value stands for the quote itself.
+			var value strings.Builder
+			i++
+			for {
+				if i >= len(runes) {
+					return nil, &Error{Reason: "unterminated quoted value"}
+				}
+				if runes[i] == r {
+					if i+1 < len(runes) && runes[i+1] == r {
+						value.WriteRune(r)
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				value.WriteRune(runes[i])
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenString, text: value.String()})
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune("(),'\"", runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: string(runes[start:i])})
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peekKeyword(keyword string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].kind == tokenWord && strings.EqualFold(p.tokens[p.pos].text, keyword)
+}
+
+func (p *parser) next() (token, error) {
+	if p.pos >= len(p.tokens) {
+		return token{}, &Error{Reason: "unexpected end of expression"}
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	return t, nil
+}
+
+func (p *parser) parseOr(depth int) (Node, error) {
+	return p.parseLogical(depth, "or", p.parseAnd)
+}
+
+func (p *parser) parseAnd(depth int) (Node, error) {
+	return p.parseLogical(depth, "and", p.parseUnary)
+}
+
+func (p *parser) parseLogical(depth int, keyword string, operand func(int) (Node, error)) (Node, error) {
+	first, err := operand(depth)
+	if err != nil {
+		return nil, err
+	}
+	nodes := []Node{first}
+	for p.peekKeyword(keyword) {
+		p.pos++
+		node, err := operand(depth)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 1 {
+		return first, nil
+	}
+	return Logical{Or: keyword == "or", Nodes: nodes}, nil
+}
+
+func (p *parser) parseUnary(depth int) (Node, error) {
+	if depth >= maxDepth {
+		return nil, &Error{Reason: "expression is nested too deeply"}
+	}
+	if p.peekKeyword("not") {
+		p.pos++
+		node, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return Not{Node: node}, nil
+	}
+	if p.pos < len(p.tokens) && p.tokens[p.pos].kind == tokenOpen {
+		p.pos++
+		node, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if t, err := p.next(); err != nil || t.kind != tokenClose {
+			return nil, &Error{Reason: "missing )"}
+		}
+		return node, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (Node, error) {
+	field, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if field.kind != tokenWord {
+		return nil, &Error{Reason: fmt.Sprintf("expected a field name, got %q", field.text)}
+	}
+	name, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	operator, ok := operators[strings.ToLower(name.text)]
+	if name.kind != tokenWord || !ok {
+		return nil, &Error{Reason: fmt.Sprintf("unknown operator %q", name.text)}
+	}
+
+	comparison := Comparison{Field: field.text, Operator: operator}
+	if operator != In {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		comparison.Values = []string{value}
+		return comparison, nil
+	}
+
+	if t, err := p.next(); err != nil || t.kind != tokenOpen {
+		return nil, &Error{Reason: "in expects a parenthesized list of values"}
+	}
+	for {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		comparison.Values = append(comparison.Values, value)
+		if len(comparison.Values) > maxInValues {
+			return nil, &Error{Reason: fmt.Sprintf("in accepts at most %d values", maxInValues)}
+		}
+		t, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if t.kind == tokenClose {
+			return comparison, nil
+		}
+		if t.kind != tokenComma {
+			return nil, &Error{Reason: fmt.Sprintf("unexpected %q in value list", t.text)}
+		}
+	}
+}
+
+func (p *parser) parseValue() (string, error) {
+	t, err := p.next()
+	if err != nil {
+		return "", err
+	}
+	if t.kind != tokenWord && t.kind != tokenString {
+		return "", &Error{Reason: fmt.Sprintf("expected a value, got %q", t.text)}
+	}
+	return t.text, nil
+}
@@ -0,0 +1,230 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"golang/pagination"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+const maxSortFields = 4
+
+type Type int
+
+const (
+	String Type = iota
+	Int
+	Time
+	Bool
+	Enum
+)
+
+// Operator sets for the usual kinds of fields.
+var (
+	Equality = []Operator{Eq, Ne, In}
+	Ordered  = []Operator{Eq, Ne, In, Gt, Ge, Lt, Le}
+	Text     = []Operator{Eq, Ne, In, Contains, StartsWith}
+)
+
+// Field exposes one column to filtering and sorting under a public name.
+type Field struct {
+	Column    string
+	Type      Type
+	Operators []Operator
+	Sortable  bool
+	// Values maps the names clients use for an Enum field to stored values.
+	Values map[string]int64
+}
+
+// Schema is the allow-list of one resource's fields, keyed by the names
+// clients use. Anything not listed can neither be filtered nor sorted by.
+type Schema map[string]Field
+
+// Query is a validated filter and sort, ready to be applied to a listing.
+type Query struct {
+	Where clause.Expression
+	Order []pagination.Order
+}
+
+// Parse validates a filter and a sort expression against the schema. Either
+// may be empty. The sort is a comma separated list of fields, each prefixed
+// with - for descending order.
+func (s Schema) Parse(filterExpression string, sortExpression string) (*Query, error) {
+	query := &Query{}
+
+	if strings.TrimSpace(filterExpression) != "" {
+		node, err := Parse(filterExpression)
+		if err != nil {
+			return nil, err
+		}
+		if query.Where, err = s.translate(node); err != nil {
+			return nil, err
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, name := range strings.Split(sortExpression, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		desc := strings.HasPrefix(name, "-")
+		name = strings.TrimLeft(name, "+-")
+
+		field, ok := s[name]
+		if !ok || !field.Sortable {
+			return nil, &Error{Reason: fmt.Sprintf("cannot sort by %q", name)}
+		}
+		if seen[name] {
+			return nil, &Error{Reason: fmt.Sprintf("%q is sorted by twice", name)}
+		}
+		seen[name] = true
+		query.Order = append(query.Order, pagination.Order{Column: field.Column, Desc: desc, Decode: field.decode})
+	}
+	if len(query.Order) > maxSortFields {
+		return nil, &Error{Reason: fmt.Sprintf("at most %d sort fields are allowed", maxSortFields)}
+	}
+
+	return query, nil
+}
+
+func (s Schema) translate(node Node) (clause.Expression, error) {
+	switch n := node.(type) {
+	case Logical:
+		separator := " AND "
+		if n.Or {
+			separator = " OR "
+		}
+		vars := make([]interface{}, len(n.Nodes))
+		for i, child := range n.Nodes {
+			expression, err := s.translate(child)
+			if err != nil {
+				return nil, err
+			}
+			vars[i] = expression
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?"+separator, len(vars)), separator)
+		return clause.Expr{SQL: "(" + placeholders + ")", Vars: vars}, nil
+	case Not:
+		expression, err := s.translate(n.Node)
+		if err != nil {
+			return nil, err
+		}
+		return clause.Expr{SQL: "NOT (?)", Vars: []interface{}{expression}}, nil
+	case Comparison:
+		return s.compare(n)
+	}
+	return nil, &Error{Reason: "unsupported expression"}
+}
+
+func (s Schema) compare(comparison Comparison) (clause.Expression, error) {
+	field, ok := s[comparison.Field]
+	if !ok {
+		return nil, &Error{Reason: fmt.Sprintf("cannot filter by %q", comparison.Field)}
+	}
+	if !field.allows(comparison.Operator) {
+		return nil, &Error{Reason: fmt.Sprintf("%q does not support %s", comparison.Field, comparison.Operator)}
+	}
+
+	values := make([]interface{}, len(comparison.Values))
+	for i, raw := range comparison.Values {
+		value, err := field.parse(raw)
+		if err != nil {
+			return nil, &Error{Reason: fmt.Sprintf("%q: %s", comparison.Field, err)}
+		}
+		values[i] = value
+	}
+
+	column := clause.Column{Table: clause.CurrentTable, Name: field.Column}
+	switch comparison.Operator {
+	case Eq:
+		return clause.Eq{Column: column, Value: values[0]}, nil
+	case Ne:
+		return clause.Neq{Column: column, Value: values[0]}, nil
+	case Gt:
+		return clause.Gt{Column: column, Value: values[0]}, nil
+	case Ge:
+		return clause.Gte{Column: column, Value: values[0]}, nil
+	case Lt:
+		return clause.Lt{Column: column, Value: values[0]}, nil
+	case Le:
+		return clause.Lte{Column: column, Value: values[0]}, nil
+	case In:
+		return clause.IN{Column: column, Values: values}, nil
+	case Contains:
+		return like(column, "%"+escapeLike(comparison.Values[0])+"%"), nil
+	default:
+		return like(column, escapeLike(comparison.Values[0])+"%"), nil
+	}
+}
+
+// like matches case-insensitively. LOWER on both sides behaves the same on
+// every database, unlike ILIKE or the case rules of plain LIKE.
+func like(column clause.Column, pattern string) clause.Expression {
+	return clause.Expr{SQL: "LOWER(?) LIKE ? ESCAPE '!'", Vars: []interface{}{column, strings.ToLower(pattern)}}
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(value)
+}
+
+func (f Field) allows(operator Operator) bool {
+	if (operator == Contains || operator == StartsWith) && f.Type != String {
+		return false
+	}
+	for _, allowed := range f.Operators {
+		if allowed == operator {
+			return true
+		}
+	}
+	return false
+}
+
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"}
+
+func (f Field) parse(value string) (interface{}, error) {
+	switch f.Type {
+	case Int:
+		return strconv.ParseInt(value, 10, 64)
+	case Bool:
+		return strconv.ParseBool(value)
+	case Time:
+		for _, layout := range timeLayouts {
+			if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+				return t.Local(), nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not a date or RFC 3339 time", value)
+	case Enum:
+		if stored, ok := f.Values[value]; ok {
+			return stored, nil
+		}
+		return nil, fmt.Errorf("unknown value %q", value)
+	}
+	return value, nil
+}
+
+// decode reads a sort key of the field back from a cursor.
+func (f Field) decode(raw json.RawMessage) (interface{}, error) {
+	switch f.Type {
+	case Int, Enum:
+		var value int64
+		err := json.Unmarshal(raw, &value)
+		return value, err
+	case Bool:
+		var value bool
+		err := json.Unmarshal(raw, &value)
+		return value, err
+	case Time:
+		var value time.Time
+		err := json.Unmarshal(raw, &value)
+		return value.Local(), err
+	}
+	var value string
+	err := json.Unmarshal(raw, &value)
+	return value, err
+}
@@ -0,0 +1,129 @@
+// Package health reports whether the server can take traffic.
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Statuses of a Report.
+const (
+	StatusOK          = "ok"
+	StatusDegraded    = "degraded"
+	StatusUnavailable = "unavailable"
+	StatusDraining    = "draining"
+)
+
+// Check returns an error when a dependency is not usable.
+type Check func(ctx context.Context) error
+
+// Result is the outcome of one check.
+type Result struct {
+	Up        bool    `json:"up"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+	// Optional dependencies are reported but a failure only degrades the
+	// server, which still takes traffic.
+	Optional bool `json:"optional,omitempty"`
+}
+
+// Report is the readiness of the server and of each dependency by name.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+// Ready reports whether the server should take traffic.
+func (r Report) Ready() bool {
+	return r.Status == StatusOK || r.Status == StatusDegraded
+}
+
+type dependency struct {
+	name     string
+	fn       Check
+	optional bool
+}
+
+// Checker runs the readiness checks. Once Drain was called the server is
+// never ready again, so load balancers stop sending requests before it
+// shuts down.
+type Checker struct {
+	// Timeout bounds each check.
+	Timeout time.Duration
+
+	checks   []dependency
+	draining atomic.Bool
+	now      func() time.Time
+}
+
+func NewChecker() *Checker {
+	return &Checker{Timeout: 2 * time.Second, now: time.Now}
+}
+
+// Add adds a dependency the server cannot work without.
+func (c *Checker) Add(name string, fn Check) {
+	c.checks = append(c.checks, dependency{name: name, fn: fn})
+}
+
+// AddOptional adds a dependency the server works around, like a cache.
+func (c *Checker) AddOptional(name string, fn Check) {
+	c.checks = append(c.checks, dependency{name: name, fn: fn, optional: true})
+}
+
+// Drain makes the server unready for good.
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Ready runs every check at once and reports the results.
+func (c *Checker) Ready(ctx context.Context) Report {
+	if c.draining.Load() {
+		return Report{Status: StatusDraining}
+	}
+
+	results := make([]Result, len(c.checks))
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func(i int, check dependency) {
+			defer wg.Done()
+			results[i] = c.run(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(c.checks))}
+	for i, check := range c.checks {
+		result := results[i]
+		report.Checks[check.name] = result
+		switch {
+		case result.Up:
+		case check.optional:
+			if report.Status == StatusOK {
+				report.Status = StatusDegraded
+			}
+		default:
+			report.Status = StatusUnavailable
+		}
+	}
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, check dependency) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	start := c.now()
+	err := check.fn(ctx)
+	result := Result{
+		Up:        err == nil,
+		LatencyMS: float64(c.now().Sub(start).Microseconds()) / 1000,
+		Optional:  check.optional,
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,78 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func up(ctx context.Context) error {
+	return nil
+}
+
+func down(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+func TestChecker_Ready(t *testing.T) {
+	t.Run("Every Dependency Up", func(t *testing.T) {
+		checker := NewChecker()
+		checker.Add("database", up)
+		checker.AddOptional("cache", up)
+
+		report := checker.Ready(context.Background())
+		assert.Equal(t, StatusOK, report.Status)
+		assert.True(t, report.Ready())
+		assert.True(t, report.Checks["database"].Up)
+		assert.True(t, report.Checks["cache"].Optional)
+	})
+
+	t.Run("Optional Dependency Down", func(t *testing.T) {
+		checker := NewChecker()
+		checker.Add("database", up)
+		checker.AddOptional("cache", down)
+
+		report := checker.Ready(context.Background())
+		assert.Equal(t, StatusDegraded, report.Status)
+		assert.True(t, report.Ready())
+		assert.Equal(t, Result{Error: "connection refused", Optional: true}, report.Checks["cache"])
+	})
+
+	t.Run("Required Dependency Down", func(t *testing.T) {
+		checker := NewChecker()
+		checker.Add("database", down)
+		checker.AddOptional("cache", down)
+
+		report := checker.Ready(context.Background())
+		assert.Equal(t, StatusUnavailable, report.Status)
+		assert.False(t, report.Ready())
+	})
+
+	t.Run("Slow Dependency", func(t *testing.T) {
+		checker := NewChecker()
+		checker.Timeout = 10 * time.Millisecond
+		checker.Add("database", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		report := checker.Ready(context.Background())
+		assert.False(t, report.Ready())
+		result := report.Checks["database"]
+		assert.Equal(t, context.DeadlineExceeded.Error(), result.Error)
+		assert.GreaterOrEqual(t, result.LatencyMS, 10.0)
+	})
+
+	t.Run("Draining", func(t *testing.T) {
+		checker := NewChecker()
+		checker.Add("database", up)
+		checker.Drain()
+
+		report := checker.Ready(context.Background())
+		assert.Equal(t, Report{Status: StatusDraining}, report)
+		assert.False(t, report.Ready())
+	})
+}
@@ -0,0 +1,61 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps records in process memory. It suits a single instance
+// and tests; records are lost on restart.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]*Record
+	now     func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: map[string]*Record{}, now: time.Now}
+}
+
+func (m *MemoryStore) Claim(ctx context.Context, key string, fingerprint string, owner string, lockUntil time.Time) (*Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	for stored, record := range m.records {
+		if !record.ExpiresAt.After(now) {
+			delete(m.records, stored)
+		}
+	}
+
+	if record, ok := m.records[key]; ok {
+		copied := *record
+		return &copied, nil
+	}
+	m.records[key] = &Record{Key: key, Fingerprint: fingerprint, Owner: owner, ExpiresAt: lockUntil}
+	return nil, nil
+}
+
+func (m *MemoryStore) Complete(ctx context.Context, key string, owner string, response Response, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record, ok := m.records[key]
+	if !ok || record.Owner != owner || record.Response != nil {
+		return ErrNotOwner
+	}
+	record.Response = &response
+	record.ExpiresAt = expiresAt
+	return nil
+}
+
+func (m *MemoryStore) Release(ctx context.Context, key string, owner string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if record, ok := m.records[key]; ok && record.Owner == owner && record.Response == nil {
+		delete(m.records, key)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	record, err := store.Claim(context.Background(), "key", "fp", "first", now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Nil(t, record)
+
+	t.Run("Duplicate Sees The Lock", func(t *testing.T) {
+		record, err := store.Claim(context.Background(), "key", "fp", "second", now.Add(time.Minute))
+		assert.NoError(t, err)
+		assert.Equal(t, "first", record.Owner)
+		assert.Nil(t, record.Response)
+	})
+
+	t.Run("Only The Owner Completes", func(t *testing.T) {
+		response := Response{Status: http.StatusCreated, Header: http.Header{"Location": {"/users/1"}}, Body: []byte("{}")}
+		assert.ErrorIs(t, store.Complete(context.Background(), "key", "second", response, now.Add(time.Hour)), ErrNotOwner)
+		assert.NoError(t, store.Complete(context.Background(), "key", "first", response, now.Add(time.Hour)))
+
+		record, err := store.Claim(context.Background(), "key", "fp", "third", now.Add(time.Minute))
+		assert.NoError(t, err)
+		assert.Equal(t, &response, record.Response)
+	})
+
+	t.Run("Release Frees The Key", func(t *testing.T) {
+		_, err := store.Claim(context.Background(), "other", "fp", "first", now.Add(time.Minute))
+		assert.NoError(t, err)
+		assert.NoError(t, store.Release(context.Background(), "other", "second"))
+		record, err := store.Claim(context.Background(), "other", "fp", "second", now.Add(time.Minute))
+		assert.NoError(t, err)
+		assert.NotNil(t, record)
+
+		assert.NoError(t, store.Release(context.Background(), "other", "first"))
+		record, err = store.Claim(context.Background(), "other", "fp", "second", now.Add(time.Minute))
+		assert.NoError(t, err)
+		assert.Nil(t, record)
+	})
+
+	t.Run("Expired Records Are Dropped", func(t *testing.T) {
+		now = now.Add(2 * time.Hour)
+		record, err := store.Claim(context.Background(), "key", "changed", "fourth", now.Add(time.Minute))
+		assert.NoError(t, err)
+		assert.Nil(t, record)
+		assert.Len(t, store.records, 1)
+	})
+}
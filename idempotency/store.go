@@ -0,0 +1,46 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ErrNotOwner is returned when a request tries to complete or release a key
+// it did not claim, typically because its lock expired and another request
+// took the key over.
+var ErrNotOwner = errors.New("idempotency key is held by another request")
+
+// Response is the stored outcome of the first request made with a key.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Record is what a store keeps per key. Response is nil while the first
+// request is still being handled.
+type Record struct {
+	Key         string
+	Fingerprint string
+	// Owner identifies the request that claimed the key.
+	Owner     string
+	Response  *Response
+	ExpiresAt time.Time
+}
+
+// Store keeps idempotency records until they expire. Implementations must be
+// safe for concurrent use by several requests and, for the database store,
+// several processes.
+type Store interface {
+	// Claim locks key for the request owner until lockUntil. It returns nil
+	// when the key was free or expired, and the current record otherwise.
+	Claim(ctx context.Context, key string, fingerprint string, owner string, lockUntil time.Time) (*Record, error)
+	// Complete stores the response of the request holding the lock and keeps
+	// it until expiresAt.
+	Complete(ctx context.Context, key string, owner string, response Response, expiresAt time.Time) error
+	// Release drops the lock of a request whose response should not be
+	// replayed, so a retry is handled afresh.
+	Release(ctx context.Context, key string, owner string) error
+}
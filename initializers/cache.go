@@ -0,0 +1,31 @@
+package initializers
+
+import (
+	"context"
+	"golang/cache"
+	"golang/config"
+	"log"
+	"time"
+)
+
+// InitializeCache returns the configured cache, or nil for the none
+// backend. An unreachable Redis is only logged: lookups go to the database
+// until it is back.
+func InitializeCache(cfg config.CacheConfig) cache.Cache {
+	switch cfg.Backend {
+	case "memory":
+		return cache.NewLRU(cfg.Size)
+	case "redis":
+		redis, err := cache.NewRedis(cfg.RedisURL)
+		if err != nil {
+			log.Fatal("Invalid Redis URL: ", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := redis.Ping(ctx); err != nil {
+			log.Println("Redis is not reachable, users are read from the database:", err)
+		}
+		return redis
+	}
+	return nil
+}
@@ -1,29 +1,146 @@
 package initializers
 
 import (
+	"errors"
+	"golang/config"
+	"golang/dao"
+	"golang/encryption"
+	"golang/migrations"
 	"golang/models"
+	"golang/services"
+	"golang/vault"
 	"log"
-	"os"
 
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-var DB *gorm.DB
+// InitializeDB connects to the database, makes sure its schema is current and
+// returns it.
+func InitializeDB(cfg config.DatabaseConfig) *gorm.DB {
+	db, err := OpenDB(cfg)
+	if err != nil {
+		log.Fatal("Failed to connect to the Database: ", err)
+	}
+	log.Printf("Connected to the %s database", cfg.Driver)
+
+	migrator, err := migrations.New(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cfg.MigrateOnStart {
+		if _, err := migrator.Up(); err != nil {
+			log.Fatal("Failed to migrate the database: ", err)
+		}
+	}
+	if err := migrator.Check(); errors.Is(err, migrations.ErrPending) {
+		log.Fatal(err, `; run "migrate up" first`)
+	} else if err != nil {
+		log.Fatal(err)
+	}
+
+	moveCardNumbersToVault(db)
+	hashPlaintextPasswords(db)
+
+	if err := dao.EnsureDefaultCards(db); err != nil {
+		log.Fatal("Failed to set default credit cards: ", err)
+	}
+	return db
+}
 
-func InitializeDB() {
-	var err error
-	dsn := os.Getenv("DB_URL")
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+type storedPassword struct {
+	ID       uint64
+	Password string
+}
+
+// passwordBatchSize is how many plaintext passwords are hashed per query.
+const passwordBatchSize = 500
 
+// hashPlaintextPasswords hashes the passwords stored in plaintext before
+// passwords were hashed. Only passwords that are not bcrypt hashes are read,
+// so once they are hashed this is a single query that finds nothing.
+func hashPlaintextPasswords(db *gorm.DB) {
+	hashed := 0
+	var batch []storedPassword
+	err := db.Table("users").Select("id", "password").
+		Where("password <> '' AND password NOT LIKE ?", "$2%").
+		FindInBatches(&batch, passwordBatchSize, func(tx *gorm.DB, _ int) error {
+			for _, user := range batch {
+				hash, changed, err := services.HashStoredPassword(user.Password)
+				if err != nil {
+					log.Printf("Failed to hash the password of user %d, who cannot log in: %v", user.ID, err)
+					continue
+				}
+				if !changed {
+					continue
+				}
+				if err := db.Table("users").Where("id = ?", user.ID).Update("password", hash).Error; err != nil {
+					return err
+				}
+				hashed++
+			}
+			return nil
+		}).Error
 	if err != nil {
-		log.Fatal("Failed to connect to the Database")
+		log.Fatal("Failed to hash plaintext passwords: ", err)
+	}
+	if hashed > 0 {
+		log.Printf("Hashed %d plaintext passwords", hashed)
 	}
+}
+
+type legacyCardNumber struct {
+	ID     uint
+	Number string
+}
+
+// moveCardNumbersToVault tokenizes card numbers still stored on credit_cards
+// from before the vault existed, then drops the column.
+func moveCardNumbersToVault(db *gorm.DB) {
+	if !db.Migrator().HasColumn(&models.CreditCard{}, "number") {
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var cards []legacyCardNumber
+		err := tx.Table("credit_cards").Select("id", "number").Where("number <> ''").Scan(&cards).Error
+		if err != nil {
+			return err
+		}
+
+		cardVault := vault.NewVault(dao.NewVaultDao(tx))
+		for _, card := range cards {
+			number := card.Number
+			if encryption.IsEncrypted(number) {
+				provider, err := encryption.CurrentKeyProvider()
+				if err != nil {
+					return err
+				}
+				plaintext, err := encryption.Decrypt(provider, number)
+				if err != nil {
+					return err
+				}
+				number = string(plaintext)
+			}
+			number = vault.NormalizeNumber(number)
+
+			token, err := cardVault.Tokenize(tx.Statement.Context, number)
+			if err != nil {
+				return err
+			}
+			err = tx.Table("credit_cards").Where("id = ?", card.ID).Updates(map[string]interface{}{
+				"token": token,
+				"brand": string(vault.DetectBrand(number)),
+				"last4": vault.Last4(number),
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		log.Printf("Moved %d credit card numbers to the vault", len(cards))
 
-	err = DB.AutoMigrate(&models.User{}, &models.Note{}, &models.CreditCard{})
+		return tx.Migrator().DropColumn(&models.CreditCard{}, "number")
+	})
 	if err != nil {
-		log.Println("Error during AutoMigrate:", err)
-	} else {
-		log.Println("Database schema migrated successfully")
+		log.Fatal("Failed to move credit card numbers to the vault: ", err)
 	}
 }
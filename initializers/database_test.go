@@ -0,0 +1,32 @@
+package initializers
+
+import (
+	"golang/config"
+	"golang/services"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHashPlaintextPasswords(t *testing.T) {
+	db, err := OpenDB(config.DatabaseConfig{Driver: "sqlite", URL: ":memory:"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	sqlDB, _ := db.DB()
+	defer sqlDB.Close()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("hashed"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	assert.NoError(t, db.Exec("CREATE TABLE users (id integer primary key, password text)").Error)
+	assert.NoError(t, db.Exec("INSERT INTO users VALUES (1, 'plain'), (2, ?), (3, '')", string(hash)).Error)
+
+	hashPlaintextPasswords(db)
+
+	var passwords []string
+	assert.NoError(t, db.Table("users").Order("id").Pluck("password", &passwords).Error)
+	assert.True(t, services.CheckPassword(passwords[0], "plain"))
+	assert.Equal(t, string(hash), passwords[1])
+	assert.Empty(t, passwords[2])
+}
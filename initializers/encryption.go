@@ -0,0 +1,15 @@
+package initializers
+
+import (
+	"golang/encryption"
+	"log"
+)
+
+func InitializeEncryption(keyFile string) {
+	provider, err := encryption.NewFileKeyProvider(keyFile)
+	if err != nil {
+		log.Fatal("Failed to load master key: ", err)
+	}
+
+	encryption.SetKeyProvider(provider)
+}
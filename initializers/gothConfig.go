@@ -2,9 +2,8 @@ package initializers
 
 import (
 	"fmt"
-	"log"
+	"golang/config"
 	"net/http"
-	"os"
 
 	"github.com/gin-gonic/gin"
 	"github.com/markbates/goth"
@@ -12,18 +11,10 @@ import (
 	"github.com/markbates/goth/providers/google"
 )
 
-func ConfigGoth() {
-
-	clientID := os.Getenv("CLIENT_ID")
-	clientSecret := os.Getenv("CLIENT_SECRET")
-	clientCallbackURL := os.Getenv("CLIENT_CALLBACK_URL")
-
-	if clientID == "" || clientSecret == "" || clientCallbackURL == "" {
-		log.Fatal("Environment variables (CLIENT_ID, CLIENT_SECRET, CLIENT_CALLBACK_URL) are required")
-	}
+func ConfigGoth(cfg config.OAuthConfig) {
 
 	goth.UseProviders(
-		google.New(clientID, clientSecret, clientCallbackURL),
+		google.New(cfg.ClientID, cfg.ClientSecret, cfg.CallbackURL),
 	)
 
 }
@@ -0,0 +1,42 @@
+package initializers
+
+import (
+	"context"
+	"golang/cache"
+	"golang/health"
+	"golang/migrations"
+	"golang/replicas"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// InitializeHealth checks that the primary database is reachable and its
+// schema current. Replicas and a Redis cache are optional: reads fall back
+// to the primary and lookups to the database while they are down.
+func InitializeHealth(db *gorm.DB, router *replicas.Router, store cache.Cache) *health.Checker {
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatal(err)
+	}
+	migrator, err := migrations.New(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	checker := health.NewChecker()
+	checker.Add("database", sqlDB.PingContext)
+	checker.Add("migrations", func(ctx context.Context) error {
+		return migrator.Current(replicas.Primary(ctx))
+	})
+	for _, replica := range router.Replicas() {
+		replica := replica
+		checker.AddOptional("replica "+replica.Name, func(ctx context.Context) error {
+			return router.Check(ctx, replica)
+		})
+	}
+	if redis, ok := store.(*cache.Redis); ok {
+		checker.AddOptional("cache", redis.Ping)
+	}
+	return checker
+}
@@ -1,14 +1,19 @@
 package initializers
 
 import (
+	"errors"
+	"io/fs"
 	"log"
 
 	"github.com/joho/godotenv"
 )
 
+// LoadEnvVariables adds the variables in .env to the environment, without
+// overriding variables already set. The file is optional: deployments
+// usually set real environment variables instead.
 func LoadEnvVariables() {
 	err := godotenv.Load()
-	if err != nil {
-		log.Fatal("Error loading .env file")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Fatal("Error loading .env file: ", err)
 	}
 }
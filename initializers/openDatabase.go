@@ -0,0 +1,121 @@
+package initializers
+
+import (
+	"fmt"
+	"golang/config"
+	"log"
+	"strings"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+const (
+	firstConnectRetry = 250 * time.Millisecond
+	maxConnectRetry   = 8 * time.Second
+)
+
+// sleep waits between connection attempts. Tests replace it.
+var sleep = time.Sleep
+
+// OpenDB connects to the database described by cfg and tunes its connection
+// pool. Failed attempts are retried with exponential backoff for as long as
+// the waits fit in cfg.ConnectTimeout.
+func OpenDB(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	dialector, err := newDialector(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var waited time.Duration
+	wait := firstConnectRetry
+	for {
+		db, err := connect(dialector, cfg)
+		if err == nil {
+			return db, nil
+		}
+		if waited+wait > cfg.ConnectTimeout {
+			return nil, err
+		}
+		log.Printf("Database is not reachable, retrying in %s: %v", wait, err)
+		sleep(wait)
+		waited += wait
+		wait *= 2
+		if wait > maxConnectRetry {
+			wait = maxConnectRetry
+		}
+	}
+}
+
+func connect(dialector gorm.Dialector, cfg config.DatabaseConfig) (*gorm.DB, error) {
+	db, err := gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Driver == "sqlite" && isSQLiteMemory(cfg.URL) {
+		// Every connection to :memory: opens a database of its own, so the
+		// pool must keep exactly one connection for good.
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+		sqlDB.SetConnMaxIdleTime(0)
+	} else {
+		sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+		sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+		sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+		sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	}
+
+	if err := sqlDB.Ping(); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func newDialector(cfg config.DatabaseConfig) (gorm.Dialector, error) {
+	switch cfg.Driver {
+	case "postgres":
+		return postgres.Open(cfg.URL), nil
+	case "mysql":
+		dsn, err := mysqldriver.ParseDSN(cfg.URL)
+		if err != nil {
+			return nil, err
+		}
+		// Timestamps are scanned into time.Time and kept in UTC, and
+		// migrations run several statements at once.
+		dsn.ParseTime = true
+		dsn.Loc = time.UTC
+		dsn.MultiStatements = true
+		return mysql.Open(dsn.FormatDSN()), nil
+	case "sqlite":
+		return sqlite.Open(sqliteDSN(cfg.URL)), nil
+	}
+	return nil, fmt.Errorf("unknown database driver %q", cfg.Driver)
+}
+
+// sqliteDSN lets a file database be read while it is written and makes
+// writers wait for each other instead of failing with "database is locked".
+func sqliteDSN(url string) string {
+	if isSQLiteMemory(url) {
+		return url
+	}
+	separator := "?"
+	if strings.Contains(url, "?") {
+		separator = "&"
+	}
+	return url + separator + "_journal_mode=WAL&_busy_timeout=5000"
+}
+
+func isSQLiteMemory(url string) bool {
+	return strings.Contains(url, ":memory:") || strings.Contains(url, "mode=memory")
+}
@@ -0,0 +1,59 @@
+package initializers
+
+import (
+	"golang/config"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenDB(t *testing.T) {
+	t.Run("SQLite Memory", func(t *testing.T) {
+		db, err := OpenDB(config.DatabaseConfig{Driver: "sqlite", URL: ":memory:", MaxOpenConns: 10})
+		if !assert.NoError(t, err) {
+			return
+		}
+		sqlDB, _ := db.DB()
+		defer sqlDB.Close()
+
+		assert.Equal(t, 1, sqlDB.Stats().MaxOpenConnections)
+		assert.NoError(t, db.Exec("CREATE TABLE things (id integer)").Error)
+		assert.True(t, db.Migrator().HasTable("things"))
+	})
+
+	t.Run("SQLite File", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.db")
+		db, err := OpenDB(config.DatabaseConfig{Driver: "sqlite", URL: path, MaxOpenConns: 4, MaxIdleConns: 2})
+		if !assert.NoError(t, err) {
+			return
+		}
+		sqlDB, _ := db.DB()
+		defer sqlDB.Close()
+
+		var mode string
+		assert.NoError(t, db.Raw("PRAGMA journal_mode").Scan(&mode).Error)
+		assert.Equal(t, "wal", mode)
+		assert.Equal(t, 4, sqlDB.Stats().MaxOpenConnections)
+	})
+
+	t.Run("Unknown Driver", func(t *testing.T) {
+		_, err := OpenDB(config.DatabaseConfig{Driver: "oracle"})
+		assert.EqualError(t, err, `unknown database driver "oracle"`)
+	})
+
+	t.Run("Retries With Backoff", func(t *testing.T) {
+		var waits []time.Duration
+		defer func(original func(time.Duration)) { sleep = original }(sleep)
+		sleep = func(d time.Duration) { waits = append(waits, d) }
+
+		missing := filepath.Join(t.TempDir(), "missing", "app.db")
+		_, err := OpenDB(config.DatabaseConfig{Driver: "sqlite", URL: missing, ConnectTimeout: 20 * time.Second})
+
+		assert.Error(t, err)
+		assert.Equal(t, []time.Duration{
+			250 * time.Millisecond, 500 * time.Millisecond, time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second,
+		}, waits)
+	})
+}
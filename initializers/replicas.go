@@ -0,0 +1,55 @@
+package initializers
+
+import (
+	"golang/config"
+	"golang/replicas"
+	"log"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+// InitializeReplicas connects to the configured read replicas and routes
+// reads of db to them. A replica that cannot be reached at startup is left
+// out and reads use the others or the primary.
+func InitializeReplicas(db *gorm.DB, cfg config.DatabaseConfig) *replicas.Router {
+	var list []*replicas.Replica
+	for i, url := range cfg.Replicas.List() {
+		name := strconv.Itoa(i + 1)
+		replicaCfg := cfg
+		replicaCfg.URL = url
+		replicaDB, err := OpenDB(replicaCfg)
+		if err != nil {
+			log.Printf("Replica %s is not reachable and will not serve reads: %v", name, err)
+			continue
+		}
+		sqlDB, err := replicaDB.DB()
+		if err != nil {
+			log.Fatal(err)
+		}
+		list = append(list, &replicas.Replica{Name: name, DB: sqlDB, Lag: lagFunc(cfg.Driver)})
+	}
+
+	router := replicas.NewRouter(list)
+	router.ReadYourWrites = cfg.Replicas.ReadYourWrites
+	router.MaxLag = cfg.Replicas.MaxLag
+	if err := db.Use(router); err != nil {
+		log.Fatal("Failed to set up read replicas: ", err)
+	}
+	if len(list) > 0 {
+		log.Printf("Reading from %d replicas", len(list))
+	}
+	return router
+}
+
+// lagFunc measures replication lag for the driver. SQLite has no
+// replication, so its replicas are only pinged.
+func lagFunc(driver string) replicas.LagFunc {
+	switch driver {
+	case "postgres":
+		return replicas.PostgresLag
+	case "mysql":
+		return replicas.MySQLLag
+	}
+	return nil
+}
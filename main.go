@@ -1,39 +1,133 @@
 package main
 
 import (
-	"golang/controllers"
-	"golang/dao"
+	"context"
+	"errors"
+	"flag"
+	"golang/config"
+	"golang/encryption"
 	"golang/initializers"
-	"golang/middleware"
-	"golang/services"
+	"golang/replicas"
+	"golang/server"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
-	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func init() {
 	initializers.LoadEnvVariables()
-	initializers.InitializeDB()
 }
 
 func main() {
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		runCommand(os.Args[1], os.Args[2:])
+		return
+	}
 
-	db := initializers.DB
-	newUserDao := dao.NewUserDao(db)
-	service := services.NewUserService(newUserDao)
-	controller := controllers.NewUserController(service)
+	cfg := loadConfig(os.Args[1:])
+	if cfg.Dev {
+		// A dev server creates its master key on first start.
+		err := encryption.GenerateKeyFile(cfg.Encryption.MasterKeyFile, "dev")
+		if err != nil && !errors.Is(err, fs.ErrExist) {
+			log.Fatal("Failed to create dev master key: ", err)
+		}
+	}
+	initializers.InitializeEncryption(cfg.Encryption.MasterKeyFile)
+	db := initializers.InitializeDB(cfg.Database)
+	replicaRouter := initializers.InitializeReplicas(db, cfg.Database)
+	a := newApp(cfg, db, replicaRouter)
 
-	authController := controllers.NewAuthController(*newUserDao)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	var workers sync.WaitGroup
+	workers.Add(2)
+	go func() {
+		defer workers.Done()
+		replicaRouter.Start(ctx, cfg.Database.Replicas.CheckInterval)
+	}()
+	go func() {
+		defer workers.Done()
+		a.reminder.Start(ctx, 24*time.Hour)
+	}()
 
-	router := gin.Default()
+	srv, err := server.New(cfg.Server, a.router())
+	if err != nil {
+		log.Fatal("Failed to load the TLS certificate: ", err)
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe(srv)
+	}()
+	log.Printf("Listening on %s", srv.Addr)
 
-	router.POST("/users", middleware.RequireAuth("RoleUser", "RoleAdmin"), controller.CreateUser)
-	router.GET("/users/:id", middleware.RequireAuth("RoleUser", "RoleAdmin"), controller.GetUserById)
-	router.GET("/users", middleware.RequireAuth("RoleUser", "RoleAdmin"), controller.GetAllUsers)
-	router.PUT("/users/:id", middleware.RequireAuth("RoleUser", "RoleAdmin"), controller.UpdateUser)
-	router.DELETE("/users/:id", middleware.RequireAuth("RoleUser", "RoleAdmin"), controller.DeleteUser)
+	select {
+	case err := <-serveErr:
+		log.Fatal("Server failed: ", err)
+	case <-ctx.Done():
+	}
+	// A second signal kills the process.
+	stop()
+	log.Printf("Shutting down, waiting up to %s for requests and jobs", cfg.Server.ShutdownTimeout)
+	shutdown(srv, a, db, replicaRouter, &workers, cfg.Server)
+	log.Println("Shut down")
+}
+
+// shutdown fails readiness for the shutdown delay, then stops accepting
+// requests and waits for the ones in flight, the
+// background workers and the running import jobs, in that order, before
+// closing the cache and the database pools. Whatever is still running when
+// the shutdown timeout is up is abandoned.
+func shutdown(srv *http.Server, a *app, db *gorm.DB, replicaRouter *replicas.Router, workers *sync.WaitGroup, cfg config.ServerConfig) {
+	a.health.Drain()
+	time.Sleep(cfg.ShutdownDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
 
-	router.POST("/signup", controller.CreateUser)
-	router.POST("/login", authController.Login)
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Println("Closing connections still in use:", err)
+		srv.Close()
+	}
+	workers.Wait()
+	if err := a.imports.Drain(ctx); err != nil {
+		log.Println("Abandoning import jobs:", err)
+	}
+
+	if closer, ok := a.cache.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Println("Failed to close the cache:", err)
+		}
+	}
+	if err := replicaRouter.Close(); err != nil {
+		log.Println("Failed to close the replicas:", err)
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			log.Println("Failed to close the database:", err)
+		}
+	}
+}
 
-	router.Run(":8080")
+// loadConfig exits listing every configuration problem when the config is
+// invalid.
+func loadConfig(args []string) *config.Config {
+	cfg, err := config.Load(args, os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		config.Usage(os.Stderr)
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	log.Printf("Configuration:\n%s", cfg)
+	return cfg
 }
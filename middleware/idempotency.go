@@ -0,0 +1,196 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"golang/idempotency"
+	"golang/models"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxIdempotencyKeyLength = 255
+	// maxIdempotentBodyBytes bounds the request bodies read for fingerprinting.
+	maxIdempotentBodyBytes = 1 << 20
+	// idempotencyLockTimeout is how long a request may hold a key before a
+	// retry is allowed to take it over, e.g. after the instance died.
+	idempotencyLockTimeout  = time.Minute
+	idempotencyPollInterval = 25 * time.Millisecond
+)
+
+// idempotencyWait is how long a duplicate waits for the first request to
+// finish before it is answered with 409.
+var idempotencyWait = 10 * time.Second
+
+// replayedHeaders are the response headers stored with the body and sent
+// again on a replay.
+var replayedHeaders = []string{"Content-Type", "ETag", "Location", "Link"}
+
+// Idempotency makes the wrapped POST handler safe to retry. The first request
+// with a given Idempotency-Key header is handled normally and its response is
+// stored for ttl; retries with the same key and body get that response back
+// with Idempotent-Replayed: true. Reusing a key with a different body is
+// answered with 422. A duplicate that arrives while the first request is in
+// flight waits for it to finish. Responses with a 5xx status are not stored,
+// so the request can be retried.
+//
+// Keys are scoped to the route and the authenticated user, so it must run
+// after RequireAuth. Requests without the header pass through untouched.
+func Idempotency(store idempotency.Store, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLength)})
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxIdempotentBodyBytes+1))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			return
+		}
+		if len(body) > maxIdempotentBodyBytes {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body is too large for an idempotent request"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		scopedKey := hashParts(c.Request.Method, c.FullPath(), idempotencyUser(c), key)
+		fingerprint := hashParts(c.Request.Method, c.Request.URL.RequestURI(), string(body))
+		owner, err := newIdempotencyOwner()
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		deadline := time.Now().Add(idempotencyWait)
+		for {
+			record, err := store.Claim(c.Request.Context(), scopedKey, fingerprint, owner, time.Now().Add(idempotencyLockTimeout))
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if record == nil {
+				break
+			}
+			if record.Fingerprint != fingerprint {
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used for a different request"})
+				return
+			}
+			if record.Response != nil {
+				replay(c, record.Response)
+				return
+			}
+			if time.Now().After(deadline) {
+				c.Header("Retry-After", "1")
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still in progress"})
+				return
+			}
+			select {
+			case <-c.Request.Context().Done():
+				c.AbortWithStatus(http.StatusRequestTimeout)
+				return
+			case <-time.After(idempotencyPollInterval):
+			}
+		}
+
+		// The outcome is stored even when the request's context has been
+		// cancelled by then, so it is not run under it.
+		recorder := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		completed := false
+		defer func() {
+			if !completed {
+				if err := store.Release(context.Background(), scopedKey, owner); err != nil {
+					log.Println("Failed to release idempotency key:", err)
+				}
+			}
+		}()
+
+		c.Next()
+
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+		header := http.Header{}
+		for _, name := range replayedHeaders {
+			if value := recorder.Header().Values(name); len(value) > 0 {
+				header[name] = value
+			}
+		}
+		response := idempotency.Response{Status: status, Header: header, Body: recorder.body.Bytes()}
+		if err := store.Complete(context.Background(), scopedKey, owner, response, time.Now().Add(ttl)); err != nil {
+			log.Println("Failed to store idempotent response:", err)
+		}
+		completed = true
+	}
+}
+
+func replay(c *gin.Context, response *idempotency.Response) {
+	for name, values := range response.Header {
+		for _, value := range values {
+			c.Writer.Header().Add(name, value)
+		}
+	}
+	c.Header("Idempotent-Replayed", "true")
+	c.Status(response.Status)
+	c.Writer.Write(response.Body)
+	c.Abort()
+}
+
+func idempotencyUser(c *gin.Context) string {
+	if user, ok := c.Get("currentUser"); ok {
+		if user, ok := user.(models.User); ok {
+			return fmt.Sprint(user.ID)
+		}
+	}
+	return "anonymous"
+}
+
+// hashParts hashes parts with their lengths, so that moving bytes from one
+// part to the next changes the result.
+func hashParts(parts ...string) string {
+	hash := sha256.New()
+	for _, part := range parts {
+		fmt.Fprintf(hash, "%d:%s", len(part), part)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func newIdempotencyOwner() (string, error) {
+	owner := make([]byte, 16)
+	if _, err := rand.Read(owner); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(owner), nil
+}
+
+// recordingWriter keeps a copy of the response body while writing it.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *recordingWriter) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *recordingWriter) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
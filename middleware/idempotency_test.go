@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"golang/idempotency"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	var calls int32
+	release := make(chan struct{})
+	r.POST("/items", Idempotency(idempotency.NewMemoryStore(), time.Hour), func(c *gin.Context) {
+		n := atomic.AddInt32(&calls, 1)
+		body, _ := c.GetRawData()
+		switch string(body) {
+		case "slow":
+			<-release
+		case "fail":
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "try again"})
+			return
+		}
+		c.Header("Location", "/items/1")
+		c.Header("X-Request-Count", "not replayed")
+		c.JSON(http.StatusCreated, gin.H{"call": n})
+	})
+
+	post := func(key string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/items", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Replays The First Response", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		first := post("a", "x")
+		second := post("a", "x")
+
+		assert.Equal(t, http.StatusCreated, first.Code)
+		assert.Equal(t, http.StatusCreated, second.Code)
+		assert.Equal(t, first.Body.String(), second.Body.String())
+		assert.Equal(t, "/items/1", second.Header().Get("Location"))
+		assert.Equal(t, "true", second.Header().Get("Idempotent-Replayed"))
+		assert.Empty(t, second.Header().Get("X-Request-Count"))
+		assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("Different Body", func(t *testing.T) {
+		w := post("a", "y")
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("Without Key", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		post("", "x")
+		post("", "x")
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("Key Too Long", func(t *testing.T) {
+		w := post(strings.Repeat("k", 256), "x")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Server Errors Are Not Stored", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		assert.Equal(t, http.StatusServiceUnavailable, post("b", "fail").Code)
+		assert.Equal(t, http.StatusServiceUnavailable, post("b", "fail").Code)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("Concurrent Duplicates Wait", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		var wg sync.WaitGroup
+		responses := make([]*httptest.ResponseRecorder, 3)
+		for i := range responses {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				responses[i] = post("c", "slow")
+			}(i)
+		}
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+		for _, w := range responses {
+			assert.Equal(t, http.StatusCreated, w.Code)
+			assert.JSONEq(t, `{"call":1}`, w.Body.String())
+		}
+	})
+
+	t.Run("Gives Up Waiting", func(t *testing.T) {
+		defer func(wait time.Duration) { idempotencyWait = wait }(idempotencyWait)
+		idempotencyWait = 50 * time.Millisecond
+
+		store := idempotency.NewMemoryStore()
+		blocked := make(chan struct{})
+		defer close(blocked)
+		r := gin.New()
+		r.POST("/items", Idempotency(store, time.Hour), func(c *gin.Context) {
+			<-blocked
+		})
+
+		go r.ServeHTTP(httptest.NewRecorder(), newIdempotentRequest("d"))
+		time.Sleep(20 * time.Millisecond)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, newIdempotentRequest("d"))
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	})
+}
+
+func newIdempotentRequest(key string) *http.Request {
+	req, _ := http.NewRequest("POST", "/items", strings.NewReader("{}"))
+	req.Header.Set("Idempotency-Key", key)
+	return req
+}
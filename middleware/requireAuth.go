@@ -1,11 +1,11 @@
 package middleware
 
 import (
+	"context"
 	"fmt"
-	"golang/initializers"
 	"golang/models"
+	"golang/replicas"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
@@ -13,7 +13,22 @@ import (
 	"github.com/golang-jwt/jwt"
 )
 
-func RequireAuth(allowedRoles ...string) gin.HandlerFunc {
+// UserFinder loads the user a token was issued to.
+type UserFinder interface {
+	FindByID(ctx context.Context, id uint64) (*models.User, error)
+}
+
+// Authenticator checks the bearer tokens issued by AuthController.
+type Authenticator struct {
+	secret []byte
+	users  UserFinder
+}
+
+func NewAuthenticator(secret string, users UserFinder) *Authenticator {
+	return &Authenticator{secret: []byte(secret), users: users}
+}
+
+func (a *Authenticator) RequireAuth(allowedRoles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get Authorization header
 		authHeader := c.GetHeader("Authorization")
@@ -38,7 +53,7 @@ func RequireAuth(allowedRoles ...string) gin.HandlerFunc {
 			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 			}
-			return []byte(os.Getenv("SECRET")), nil
+			return a.secret, nil
 		})
 
 		// Handle token parsing errors
@@ -63,10 +78,13 @@ func RequireAuth(allowedRoles ...string) gin.HandlerFunc {
 			return
 		}
 
-		// Get user from DB using ID from token claims (sub)
-		var user models.User
-		initializers.DB.Where("ID=?", claims["sub"]).First(&user)
-		if user.ID == 0 {
+		// Get user from DB using ID from token claims (sub). The lookup does
+		// not use a replica, so deleted users are rejected once the user
+		// cache has dropped them: at once with the redis backend or no
+		// cache, up to the cache TTL on other instances with memory.
+		subject, _ := claims["sub"].(float64)
+		user, err := a.users.FindByID(replicas.Primary(c.Request.Context()), uint64(subject))
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
 			c.AbortWithStatus(http.StatusUnauthorized)
 			return
@@ -95,8 +113,10 @@ func RequireAuth(allowedRoles ...string) gin.HandlerFunc {
 			return
 		}
 
-		// Set the user in the Gin context to be accessed by the next handlers
-		c.Set("currentUser", user)
+		// Set the user in the Gin context to be accessed by the next handlers,
+		// and let the user read their own writes
+		c.Set("currentUser", *user)
+		c.Request = c.Request.WithContext(replicas.WithUser(c.Request.Context(), user.ID))
 
 		// Continue to the next middleware/handler
 		c.Next()
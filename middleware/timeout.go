@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Timeout cancels the request's context after d, which aborts the database
+// queries still running for it. A zero d leaves the request unbounded.
+func Timeout(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if d <= 0 {
+			c.Next()
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// NoDeadlines lifts the server's read and write timeouts for the request,
+// for uploads and downloads that take as long as they need.
+func NoDeadlines() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		controller := http.NewResponseController(c.Writer)
+		// Only unsupported writers, like test recorders, fail here.
+		_ = controller.SetReadDeadline(time.Time{})
+		_ = controller.SetWriteDeadline(time.Time{})
+		c.Next()
+	}
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	serve := func(d time.Duration) error {
+		var err error
+		r := gin.New()
+		r.GET("/", Timeout(d), func(c *gin.Context) {
+			select {
+			case <-c.Request.Context().Done():
+				err = c.Request.Context().Err()
+			case <-time.After(50 * time.Millisecond):
+			}
+			c.Status(http.StatusOK)
+		})
+		req, _ := http.NewRequest("GET", "/", nil)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		return err
+	}
+
+	assert.ErrorIs(t, serve(time.Millisecond), context.DeadlineExceeded)
+	assert.NoError(t, serve(0))
+}
+
+func TestNoDeadlines(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	slow := func(c *gin.Context) {
+		time.Sleep(100 * time.Millisecond)
+		c.String(http.StatusOK, "done")
+	}
+	r.GET("/bounded", slow)
+	r.GET("/unbounded", NoDeadlines(), slow)
+
+	ts := httptest.NewUnstartedServer(r)
+	ts.Config.WriteTimeout = 20 * time.Millisecond
+	ts.Start()
+	defer ts.Close()
+
+	_, err := http.Get(ts.URL + "/bounded")
+	assert.Error(t, err, "the write timeout cuts the response off")
+
+	resp, err := http.Get(ts.URL + "/unbounded")
+	if assert.NoError(t, err) {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, "done", string(body))
+	}
+}
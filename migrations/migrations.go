@@ -0,0 +1,413 @@
+package migrations
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The schema of every supported database, one directory per dialect. Files
+// are named NNNN_name.up.sql and NNNN_name.down.sql; a version only needs
+// files for the dialects it changes.
+//
+//go:embed sql
+var files embed.FS
+
+// lockID identifies the Postgres advisory lock held while migrating.
+const lockID = 7_401_550_112
+
+// baselineTable tells a database created by AutoMigrate, before versioned
+// migrations existed, from an empty one.
+const baselineTable = "users"
+
+// baselineSchema lists the tables and columns the first migration creates.
+// An existing database is only adopted when it has all of them; AutoMigrate
+// of older models left out tables and columns the later code relies on.
+var baselineSchema = []struct {
+	table   string
+	columns []string
+}{
+	{"users", []string{"id", "created_at", "updated_at", "deleted_at", "username", "password", "role", "version"}},
+	{"notes", []string{"id", "created_at", "updated_at", "deleted_at", "name", "content", "user_id", "version"}},
+	{"credit_cards", []string{"id", "created_at", "updated_at", "deleted_at", "token", "brand", "last4", "exp_month", "exp_year",
+		"holder_name", "billing_line1", "billing_line2", "billing_city", "billing_state", "billing_postal_code", "billing_country",
+		"is_default", "expiry_reminder_sent_at", "user_id"}},
+	{"vault_entries", []string{"id", "created_at", "updated_at", "deleted_at", "token", "number"}},
+	{"vault_audit_entries", []string{"id", "created_at", "updated_at", "deleted_at", "token", "actor_id", "reason", "client_ip", "success"}},
+	{"charges", []string{"id", "created_at", "updated_at", "deleted_at", "user_id", "card_id", "amount", "currency", "status", "gateway",
+		"gateway_reference", "captured_amount", "refunded_amount", "failure_code", "idempotency_key"}},
+	{"charge_transactions", []string{"id", "created_at", "updated_at", "deleted_at", "charge_id", "type", "amount", "from_status",
+		"to_status", "gateway_reference", "failure_code", "idempotency_key"}},
+	{"webhook_events", []string{"id", "created_at", "updated_at", "deleted_at", "gateway", "event_id", "type", "payload"}},
+	{"idempotency_records", []string{"idempotency_key", "fingerprint", "owner", "status", "header", "body", "expires_at", "created_at"}},
+}
+
+// ErrUnknownSchema is returned when a database has tables but not the schema
+// the first migration would have created.
+var ErrUnknownSchema = errors.New("existing schema does not match the initial migration")
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// ErrPending is returned by Check when the database is behind the binary.
+var ErrPending = errors.New("database schema is behind")
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is a migration and when it was applied, if it was.
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+type schemaMigration struct {
+	Version   int64  `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"size:255;not null"`
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Load returns the migrations of dialect in version order.
+func Load(dialect string) ([]Migration, error) {
+	dir := path.Join("sql", dialect)
+	entries, err := fs.ReadDir(files, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for %s", dialect)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		content, err := fs.ReadFile(files, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Migrator applies and reverts the migrations of the database it is
+// connected to. Each migration runs in its own transaction, which MySQL
+// commits implicitly at every DDL statement. On Postgres and MySQL a
+// database lock keeps concurrent instances from migrating at the same time.
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+func New(db *gorm.DB) (*Migrator, error) {
+	migrations, err := Load(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Up applies every pending migration and returns them.
+func (m *Migrator) Up() ([]Migration, error) {
+	var applied []Migration
+	err := m.locked(func(db *gorm.DB) error {
+		done, err := m.applied(db)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+			if err := m.run(db, migration, true); err != nil {
+				return err
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the last steps applied migrations and returns them.
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.locked(func(db *gorm.DB) error {
+		done, err := m.applied(db)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+			if err := m.run(db, migration, false); err != nil {
+				return err
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Redo reverts and reapplies the last applied migration.
+func (m *Migrator) Redo() (*Migration, error) {
+	var redone *Migration
+	err := m.locked(func(db *gorm.DB) error {
+		done, err := m.applied(db)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+			if err := m.run(db, migration, false); err != nil {
+				return err
+			}
+			redone = &migration
+			return m.run(db, migration, true)
+		}
+		return nil
+	})
+	return redone, err
+}
+
+// Status lists every migration the binary knows of.
+func (m *Migrator) Status() ([]Status, error) {
+	done, err := m.lockedApplied()
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, len(m.migrations))
+	for i, migration := range m.migrations {
+		statuses[i] = Status{Migration: migration}
+		if appliedAt, ok := done[migration.Version]; ok {
+			statuses[i].AppliedAt = &appliedAt
+		}
+	}
+	return statuses, nil
+}
+
+// Check returns ErrPending when a migration of the binary has not been
+// applied. Versions applied by a newer binary are only logged, so an older
+// instance keeps running during a rolling deploy. While another instance is
+// migrating, Check waits for it to finish.
+func (m *Migrator) Check() error {
+	done, err := m.lockedApplied()
+	if err != nil {
+		return err
+	}
+
+	known := map[int64]bool{}
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+	}
+	for version := range done {
+		if !known[version] {
+			log.Printf("Database has migration %04d, which this binary does not know", version)
+		}
+	}
+	return m.pending(done)
+}
+
+// Current is Check for health probes: it only reads the applied versions,
+// without waiting for a running migration or creating the migrations table.
+func (m *Migrator) Current(ctx context.Context) error {
+	var versions []int64
+	if err := m.db.WithContext(ctx).Model(&schemaMigration{}).Pluck("version", &versions).Error; err != nil {
+		return err
+	}
+	done := make(map[int64]time.Time, len(versions))
+	for _, version := range versions {
+		done[version] = time.Time{}
+	}
+	return m.pending(done)
+}
+
+// pending returns ErrPending listing the migrations missing from done.
+func (m *Migrator) pending(done map[int64]time.Time) error {
+	var pending []string
+	for _, migration := range m.migrations {
+		if _, ok := done[migration.Version]; !ok {
+			pending = append(pending, fmt.Sprintf("%04d_%s", migration.Version, migration.Name))
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w, pending migrations: %s", ErrPending, strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// applied returns the applied versions and when they were applied. A
+// database that was created by AutoMigrate is adopted: the initial schema
+// is recorded as applied without running it, provided the database has
+// everything it creates.
+func (m *Migrator) applied(db *gorm.DB) (map[int64]time.Time, error) {
+	if !db.Migrator().HasTable(&schemaMigration{}) {
+		existing := db.Migrator().HasTable(baselineTable)
+		if existing {
+			if missing := missingBaseline(db); len(missing) > 0 {
+				return nil, fmt.Errorf("%w, missing %s", ErrUnknownSchema, strings.Join(missing, ", "))
+			}
+		}
+		if err := db.Migrator().CreateTable(&schemaMigration{}); err != nil {
+			return nil, err
+		}
+		if existing && len(m.migrations) > 0 {
+			baseline := m.migrations[0]
+			log.Printf("Adopting the existing schema as migration %04d_%s", baseline.Version, baseline.Name)
+			err := db.Create(&schemaMigration{Version: baseline.Version, Name: baseline.Name, AppliedAt: time.Now()}).Error
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var rows []schemaMigration
+	if err := db.Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	done := make(map[int64]time.Time, len(rows))
+	for _, row := range rows {
+		done[row.Version] = row.AppliedAt
+	}
+	return done, nil
+}
+
+// missingBaseline lists the tables and columns of baselineSchema db lacks.
+func missingBaseline(db *gorm.DB) []string {
+	var missing []string
+	for _, table := range baselineSchema {
+		if !db.Migrator().HasTable(table.table) {
+			missing = append(missing, "table "+table.table)
+			continue
+		}
+		for _, column := range table.columns {
+			if !db.Migrator().HasColumn(table.table, column) {
+				missing = append(missing, "column "+table.table+"."+column)
+			}
+		}
+	}
+	return missing
+}
+
+func (m *Migrator) lockedApplied() (map[int64]time.Time, error) {
+	var done map[int64]time.Time
+	err := m.locked(func(db *gorm.DB) error {
+		var err error
+		done, err = m.applied(db)
+		return err
+	})
+	return done, err
+}
+
+func (m *Migrator) run(db *gorm.DB, migration Migration, up bool) error {
+	script, direction := migration.Up, "up"
+	if !up {
+		script, direction = migration.Down, "down"
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if !isEmpty(script) {
+			if err := tx.Exec(script).Error; err != nil {
+				return err
+			}
+		}
+		if up {
+			return tx.Create(&schemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+		}
+		return tx.Delete(&schemaMigration{}, migration.Version).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migration %04d_%s %s: %w", migration.Version, migration.Name, direction, err)
+	}
+	log.Printf("Migrated %s %04d_%s", direction, migration.Version, migration.Name)
+	return nil
+}
+
+// locked runs fn on a single connection holding the migration lock.
+func (m *Migrator) locked(fn func(db *gorm.DB) error) error {
+	switch m.db.Dialector.Name() {
+	case "postgres":
+		return m.db.Connection(func(conn *gorm.DB) error {
+			if err := conn.Exec("SELECT pg_advisory_lock(?)", lockID).Error; err != nil {
+				return err
+			}
+			defer conn.Exec("SELECT pg_advisory_unlock(?)", lockID)
+			return fn(conn)
+		})
+	case "mysql":
+		return m.db.Connection(func(conn *gorm.DB) error {
+			var acquired int
+			if err := conn.Raw("SELECT GET_LOCK('schema_migrations', 300)").Scan(&acquired).Error; err != nil {
+				return err
+			}
+			if acquired != 1 {
+				return errors.New("timed out waiting for the migration lock")
+			}
+			defer conn.Exec("SELECT RELEASE_LOCK('schema_migrations')")
+			return fn(conn)
+		})
+	}
+	// SQLite allows one writer at a time; a second migrating process fails
+	// on the tables the first one created and rolls back.
+	return fn(m.db)
+}
+
+// isEmpty reports whether script holds nothing but comments, which MySQL
+// refuses to execute.
+func isEmpty(script string) bool {
+	for _, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,189 @@
+package migrations
+
+import (
+	"context"
+	"golang/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// allModels are the models the schema must match.
+var allModels = []interface{}{
+	&models.User{}, &models.Note{}, &models.CreditCard{}, &models.VaultEntry{}, &models.VaultAuditEntry{},
+	&models.Charge{}, &models.ChargeTransaction{}, &models.WebhookEvent{}, &models.IdempotencyRecord{},
+}
+
+func openTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to in-memory database: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+func TestLoad(t *testing.T) {
+	for dialect, latest := range map[string]int64{"postgres": 3, "mysql": 2, "sqlite": 2} {
+		migrations, err := Load(dialect)
+		if !assert.NoError(t, err, dialect) {
+			continue
+		}
+		assert.Equal(t, int64(1), migrations[0].Version, dialect)
+		assert.Equal(t, "initial_schema", migrations[0].Name, dialect)
+		assert.Equal(t, latest, migrations[len(migrations)-1].Version, dialect)
+	}
+
+	_, err := Load("oracle")
+	assert.EqualError(t, err, "no migrations for oracle")
+}
+
+func TestMigrator(t *testing.T) {
+	db := openTestDB(t)
+	migrator, err := New(db)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ctx := context.Background()
+	assert.Error(t, migrator.Current(ctx), "no migrations table yet")
+	assert.ErrorIs(t, migrator.Check(), ErrPending)
+	assert.ErrorIs(t, migrator.Current(ctx), ErrPending)
+
+	applied, err := migrator.Up()
+	assert.NoError(t, err)
+	assert.Len(t, applied, 2)
+	assert.NoError(t, migrator.Check())
+	assert.NoError(t, migrator.Current(ctx))
+
+	t.Run("Schema Matches The Models", func(t *testing.T) {
+		for _, model := range allModels {
+			stmt := &gorm.Statement{DB: db}
+			if !assert.NoError(t, stmt.Parse(model)) {
+				continue
+			}
+			for _, field := range stmt.Schema.Fields {
+				if field.DBName != "" {
+					assert.True(t, db.Migrator().HasColumn(model, field.DBName), "%s.%s", stmt.Schema.Table, field.DBName)
+				}
+			}
+			for _, index := range stmt.Schema.ParseIndexes() {
+				assert.True(t, db.Migrator().HasIndex(model, index.Name), "%s %s", stmt.Schema.Table, index.Name)
+			}
+		}
+	})
+
+	t.Run("Up Again Does Nothing", func(t *testing.T) {
+		applied, err := migrator.Up()
+		assert.NoError(t, err)
+		assert.Empty(t, applied)
+	})
+
+	t.Run("Status", func(t *testing.T) {
+		statuses, err := migrator.Status()
+		assert.NoError(t, err)
+		assert.Len(t, statuses, 2)
+		assert.NotNil(t, statuses[1].AppliedAt)
+	})
+
+	t.Run("Redo", func(t *testing.T) {
+		assert.NoError(t, db.Create(&models.User{Username: "alice"}).Error)
+
+		redone, err := migrator.Redo()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), redone.Version)
+
+		// Only the last migration ran again.
+		var count int64
+		assert.NoError(t, db.Model(&models.User{}).Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+		assert.True(t, db.Migrator().HasColumn(&models.User{}, "erased_at"))
+	})
+
+	t.Run("Down", func(t *testing.T) {
+		reverted, err := migrator.Down(5)
+		assert.NoError(t, err)
+		assert.Len(t, reverted, 2)
+		for _, model := range allModels {
+			assert.False(t, db.Migrator().HasTable(model))
+		}
+		assert.ErrorIs(t, migrator.Check(), ErrPending)
+	})
+}
+
+func TestMigrator_AdoptsAutoMigratedSchema(t *testing.T) {
+	db := openTestDB(t)
+	assert.NoError(t, db.AutoMigrate(allModels...))
+	// AutoMigrate was replaced before users.erased_at was added.
+	assert.NoError(t, db.Migrator().DropColumn(&models.User{}, "erased_at"))
+
+	migrator, err := New(db)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.ErrorIs(t, migrator.Check(), ErrPending)
+
+	applied, err := migrator.Up()
+	assert.NoError(t, err)
+	if assert.Len(t, applied, 1) {
+		assert.Equal(t, "user_erased_at", applied[0].Name)
+	}
+	assert.NoError(t, migrator.Check())
+}
+
+// The models of the first release, which the server AutoMigrated.
+type baselineUser struct {
+	gorm.Model
+	ID         uint64             `gorm:"primaryKey"`
+	Username   string             `gorm:"size:64"`
+	Password   string             `gorm:"size:255"`
+	Notes      []baselineNote     `gorm:"foreignKey:UserID"`
+	CreditCard baselineCreditCard `gorm:"foreignKey:UserID"`
+	Role       models.Role
+}
+
+func (baselineUser) TableName() string { return "users" }
+
+type baselineNote struct {
+	gorm.Model
+	ID      uint64 `gorm:"primaryKey"`
+	Name    string `gorm:"size:255"`
+	Content string `gorm:"type:text"`
+	UserID  uint64 `gorm:"index"`
+}
+
+func (baselineNote) TableName() string { return "notes" }
+
+type baselineCreditCard struct {
+	gorm.Model
+	Number string
+	UserID uint64 `gorm:"primaryKey"`
+}
+
+func (baselineCreditCard) TableName() string { return "credit_cards" }
+
+func TestMigrator_RejectsUnknownSchema(t *testing.T) {
+	db := openTestDB(t)
+	assert.NoError(t, db.AutoMigrate(&baselineUser{}, &baselineNote{}, &baselineCreditCard{}))
+
+	migrator, err := New(db)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = migrator.Up()
+	assert.ErrorIs(t, err, ErrUnknownSchema)
+	assert.ErrorContains(t, err, "table vault_entries")
+	assert.ErrorContains(t, err, "column users.version")
+	assert.ErrorContains(t, err, "column credit_cards.token")
+	assert.ErrorIs(t, migrator.Check(), ErrUnknownSchema)
+	assert.False(t, db.Migrator().HasTable(&schemaMigration{}))
+}
+
+func TestIsEmpty(t *testing.T) {
+	assert.True(t, isEmpty("-- nothing to do\n\n"))
+	assert.False(t, isEmpty("-- comment\nDROP TABLE users;"))
+}
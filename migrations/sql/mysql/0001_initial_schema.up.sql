@@ -0,0 +1,153 @@
+-- MySQL has no partial indexes, so usernames stay taken by deleted users.
+CREATE TABLE `users` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    `deleted_at` datetime(3) NULL,
+    `username` varchar(64),
+    `password` varchar(255),
+    `role` bigint,
+    `version` bigint unsigned NOT NULL DEFAULT 1,
+    PRIMARY KEY (`id`),
+    INDEX `idx_users_deleted_at` (`deleted_at`),
+    UNIQUE INDEX `idx_users_username_live` (`username`)
+);
+
+CREATE TABLE `notes` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    `deleted_at` datetime(3) NULL,
+    `name` varchar(255),
+    `content` text,
+    `user_id` bigint unsigned,
+    `version` bigint unsigned NOT NULL DEFAULT 1,
+    PRIMARY KEY (`id`),
+    INDEX `idx_notes_deleted_at` (`deleted_at`),
+    INDEX `idx_notes_user_id` (`user_id`),
+    CONSTRAINT `fk_users_notes` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`)
+);
+
+CREATE TABLE `credit_cards` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    `deleted_at` datetime(3) NULL,
+    `token` varchar(64),
+    `brand` varchar(16),
+    `last4` varchar(4),
+    `exp_month` bigint,
+    `exp_year` bigint,
+    `holder_name` varchar(255),
+    `billing_line1` varchar(255),
+    `billing_line2` varchar(255),
+    `billing_city` varchar(128),
+    `billing_state` varchar(128),
+    `billing_postal_code` varchar(32),
+    `billing_country` varchar(2),
+    `is_default` boolean,
+    `expiry_reminder_sent_at` datetime(3) NULL,
+    `user_id` bigint unsigned,
+    PRIMARY KEY (`id`),
+    INDEX `idx_credit_cards_deleted_at` (`deleted_at`),
+    INDEX `idx_credit_cards_token` (`token`),
+    INDEX `idx_credit_cards_user_id` (`user_id`),
+    CONSTRAINT `fk_users_credit_cards` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`)
+);
+
+CREATE TABLE `vault_entries` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    `deleted_at` datetime(3) NULL,
+    `token` varchar(64),
+    `number` longtext,
+    PRIMARY KEY (`id`),
+    INDEX `idx_vault_entries_deleted_at` (`deleted_at`),
+    UNIQUE INDEX `idx_vault_entries_token` (`token`)
+);
+
+CREATE TABLE `vault_audit_entries` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    `deleted_at` datetime(3) NULL,
+    `token` varchar(64),
+    `actor_id` bigint unsigned,
+    `reason` varchar(255),
+    `client_ip` varchar(64),
+    `success` boolean,
+    PRIMARY KEY (`id`),
+    INDEX `idx_vault_audit_entries_deleted_at` (`deleted_at`),
+    INDEX `idx_vault_audit_entries_token` (`token`),
+    INDEX `idx_vault_audit_entries_actor_id` (`actor_id`)
+);
+
+CREATE TABLE `charges` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    `deleted_at` datetime(3) NULL,
+    `user_id` bigint unsigned,
+    `card_id` bigint unsigned,
+    `amount` bigint,
+    `currency` varchar(3),
+    `status` varchar(32),
+    `gateway` varchar(32),
+    `gateway_reference` varchar(128),
+    `captured_amount` bigint,
+    `refunded_amount` bigint,
+    `failure_code` varchar(64),
+    `idempotency_key` varchar(255),
+    PRIMARY KEY (`id`),
+    UNIQUE INDEX `idx_charges_user_idempotency` (`user_id`,`idempotency_key`),
+    INDEX `idx_charges_card_id` (`card_id`),
+    INDEX `idx_charges_status` (`status`),
+    UNIQUE INDEX `idx_charges_gateway_reference` (`gateway`,`gateway_reference`),
+    INDEX `idx_charges_deleted_at` (`deleted_at`)
+);
+
+CREATE TABLE `charge_transactions` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    `deleted_at` datetime(3) NULL,
+    `charge_id` bigint unsigned,
+    `type` varchar(16),
+    `amount` bigint,
+    `from_status` varchar(32),
+    `to_status` varchar(32),
+    `gateway_reference` varchar(128),
+    `failure_code` varchar(64),
+    `idempotency_key` varchar(255),
+    PRIMARY KEY (`id`),
+    UNIQUE INDEX `idx_charge_transactions_idempotency` (`charge_id`,`idempotency_key`),
+    INDEX `idx_charge_transactions_deleted_at` (`deleted_at`)
+);
+
+CREATE TABLE `webhook_events` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    `deleted_at` datetime(3) NULL,
+    `gateway` varchar(32),
+    `event_id` varchar(128),
+    `type` varchar(64),
+    `payload` text,
+    PRIMARY KEY (`id`),
+    INDEX `idx_webhook_events_deleted_at` (`deleted_at`),
+    UNIQUE INDEX `idx_webhook_events_gateway_event` (`gateway`,`event_id`)
+);
+
+CREATE TABLE `idempotency_records` (
+    `idempotency_key` varchar(64),
+    `fingerprint` varchar(64),
+    `owner` varchar(32),
+    `status` bigint,
+    `header` text,
+    `body` longblob,
+    `expires_at` datetime(3) NULL,
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`idempotency_key`),
+    INDEX `idx_idempotency_records_expires_at` (`expires_at`)
+);
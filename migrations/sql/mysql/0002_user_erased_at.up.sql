@@ -0,0 +1,2 @@
+-- Marks users whose personal data was erased; they can never be restored.
+ALTER TABLE `users` ADD COLUMN `erased_at` datetime(3) NULL;
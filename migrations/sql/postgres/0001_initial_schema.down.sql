@@ -0,0 +1,9 @@
+DROP TABLE "idempotency_records";
+DROP TABLE "webhook_events";
+DROP TABLE "charge_transactions";
+DROP TABLE "charges";
+DROP TABLE "vault_audit_entries";
+DROP TABLE "vault_entries";
+DROP TABLE "credit_cards";
+DROP TABLE "notes";
+DROP TABLE "users";
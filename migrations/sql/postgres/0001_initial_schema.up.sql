@@ -0,0 +1,152 @@
+CREATE TABLE "users" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "username" varchar(64),
+    "password" varchar(255),
+    "role" bigint,
+    "version" bigint NOT NULL DEFAULT 1,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_users_deleted_at" ON "users" ("deleted_at");
+CREATE UNIQUE INDEX "idx_users_username_live" ON "users" ("username") WHERE deleted_at IS NULL;
+
+CREATE TABLE "notes" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "name" varchar(255),
+    "content" text,
+    "user_id" bigint,
+    "version" bigint NOT NULL DEFAULT 1,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_users_notes" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX "idx_notes_user_id" ON "notes" ("user_id");
+CREATE INDEX "idx_notes_deleted_at" ON "notes" ("deleted_at");
+
+CREATE TABLE "credit_cards" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "token" varchar(64),
+    "brand" varchar(16),
+    "last4" varchar(4),
+    "exp_month" bigint,
+    "exp_year" bigint,
+    "holder_name" varchar(255),
+    "billing_line1" varchar(255),
+    "billing_line2" varchar(255),
+    "billing_city" varchar(128),
+    "billing_state" varchar(128),
+    "billing_postal_code" varchar(32),
+    "billing_country" varchar(2),
+    "is_default" boolean,
+    "expiry_reminder_sent_at" timestamptz,
+    "user_id" bigint,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_users_credit_cards" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX "idx_credit_cards_user_id" ON "credit_cards" ("user_id");
+CREATE INDEX "idx_credit_cards_token" ON "credit_cards" ("token");
+CREATE INDEX "idx_credit_cards_deleted_at" ON "credit_cards" ("deleted_at");
+
+CREATE TABLE "vault_entries" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "token" varchar(64),
+    "number" text,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX "idx_vault_entries_token" ON "vault_entries" ("token");
+CREATE INDEX "idx_vault_entries_deleted_at" ON "vault_entries" ("deleted_at");
+
+CREATE TABLE "vault_audit_entries" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "token" varchar(64),
+    "actor_id" bigint,
+    "reason" varchar(255),
+    "client_ip" varchar(64),
+    "success" boolean,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_vault_audit_entries_actor_id" ON "vault_audit_entries" ("actor_id");
+CREATE INDEX "idx_vault_audit_entries_token" ON "vault_audit_entries" ("token");
+CREATE INDEX "idx_vault_audit_entries_deleted_at" ON "vault_audit_entries" ("deleted_at");
+
+CREATE TABLE "charges" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint,
+    "card_id" bigint,
+    "amount" bigint,
+    "currency" varchar(3),
+    "status" varchar(32),
+    "gateway" varchar(32),
+    "gateway_reference" varchar(128),
+    "captured_amount" bigint,
+    "refunded_amount" bigint,
+    "failure_code" varchar(64),
+    "idempotency_key" varchar(255),
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_charges_deleted_at" ON "charges" ("deleted_at");
+CREATE UNIQUE INDEX "idx_charges_gateway_reference" ON "charges" ("gateway","gateway_reference");
+CREATE INDEX "idx_charges_status" ON "charges" ("status");
+CREATE INDEX "idx_charges_card_id" ON "charges" ("card_id");
+CREATE UNIQUE INDEX "idx_charges_user_idempotency" ON "charges" ("user_id","idempotency_key");
+
+CREATE TABLE "charge_transactions" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "charge_id" bigint,
+    "type" varchar(16),
+    "amount" bigint,
+    "from_status" varchar(32),
+    "to_status" varchar(32),
+    "gateway_reference" varchar(128),
+    "failure_code" varchar(64),
+    "idempotency_key" varchar(255),
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX "idx_charge_transactions_idempotency" ON "charge_transactions" ("charge_id","idempotency_key");
+CREATE INDEX "idx_charge_transactions_deleted_at" ON "charge_transactions" ("deleted_at");
+
+CREATE TABLE "webhook_events" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "gateway" varchar(32),
+    "event_id" varchar(128),
+    "type" varchar(64),
+    "payload" text,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX "idx_webhook_events_gateway_event" ON "webhook_events" ("gateway","event_id");
+CREATE INDEX "idx_webhook_events_deleted_at" ON "webhook_events" ("deleted_at");
+
+CREATE TABLE "idempotency_records" (
+    "idempotency_key" varchar(64),
+    "fingerprint" varchar(64),
+    "owner" varchar(32),
+    "status" bigint,
+    "header" text,
+    "body" bytea,
+    "expires_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("idempotency_key")
+);
+CREATE INDEX "idx_idempotency_records_expires_at" ON "idempotency_records" ("expires_at");
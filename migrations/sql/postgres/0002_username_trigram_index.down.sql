@@ -0,0 +1,2 @@
+-- pg_trgm stays installed; other schemas in the database may use it.
+DROP INDEX IF EXISTS "idx_users_username_trgm";
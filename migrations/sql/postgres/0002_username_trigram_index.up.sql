@@ -0,0 +1,21 @@
+-- Lets contains, prefix and fuzzy username searches use an index.
+-- Creating pg_trgm needs the CREATE privilege on the database (or superuser
+-- before PostgreSQL 13). Without it, or when the server does not ship the
+-- extension, the index is skipped: searches still work, unindexed, and fuzzy
+-- search falls back to portable SQL.
+DO $$
+BEGIN
+	CREATE EXTENSION IF NOT EXISTS pg_trgm;
+EXCEPTION
+	WHEN insufficient_privilege OR undefined_file OR feature_not_supported THEN
+		RAISE NOTICE 'pg_trgm is not available (%); skipping the username trigram index', SQLERRM;
+END
+$$;
+
+DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_trgm') THEN
+		CREATE INDEX IF NOT EXISTS "idx_users_username_trgm" ON "users" USING gin ("username" gin_trgm_ops);
+	END IF;
+END
+$$;
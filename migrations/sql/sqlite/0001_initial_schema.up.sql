@@ -0,0 +1,144 @@
+CREATE TABLE `users` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    `username` text,
+    `password` text,
+    `role` integer,
+    `version` integer NOT NULL DEFAULT 1
+);
+CREATE UNIQUE INDEX `idx_users_username_live` ON `users`(`username`) WHERE deleted_at IS NULL;
+CREATE INDEX `idx_users_deleted_at` ON `users`(`deleted_at`);
+
+CREATE TABLE `notes` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    `name` text,
+    `content` text,
+    `user_id` integer,
+    `version` integer NOT NULL DEFAULT 1,
+    CONSTRAINT `fk_users_notes` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`)
+);
+CREATE INDEX `idx_notes_deleted_at` ON `notes`(`deleted_at`);
+CREATE INDEX `idx_notes_user_id` ON `notes`(`user_id`);
+
+CREATE TABLE `credit_cards` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    `token` text,
+    `brand` text,
+    `last4` text,
+    `exp_month` integer,
+    `exp_year` integer,
+    `holder_name` text,
+    `billing_line1` text,
+    `billing_line2` text,
+    `billing_city` text,
+    `billing_state` text,
+    `billing_postal_code` text,
+    `billing_country` text,
+    `is_default` numeric,
+    `expiry_reminder_sent_at` datetime,
+    `user_id` integer,
+    CONSTRAINT `fk_users_credit_cards` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`)
+);
+CREATE INDEX `idx_credit_cards_deleted_at` ON `credit_cards`(`deleted_at`);
+CREATE INDEX `idx_credit_cards_user_id` ON `credit_cards`(`user_id`);
+CREATE INDEX `idx_credit_cards_token` ON `credit_cards`(`token`);
+
+CREATE TABLE `vault_entries` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    `token` text,
+    `number` text
+);
+CREATE UNIQUE INDEX `idx_vault_entries_token` ON `vault_entries`(`token`);
+CREATE INDEX `idx_vault_entries_deleted_at` ON `vault_entries`(`deleted_at`);
+
+CREATE TABLE `vault_audit_entries` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    `token` text,
+    `actor_id` integer,
+    `reason` text,
+    `client_ip` text,
+    `success` numeric
+);
+CREATE INDEX `idx_vault_audit_entries_deleted_at` ON `vault_audit_entries`(`deleted_at`);
+CREATE INDEX `idx_vault_audit_entries_actor_id` ON `vault_audit_entries`(`actor_id`);
+CREATE INDEX `idx_vault_audit_entries_token` ON `vault_audit_entries`(`token`);
+
+CREATE TABLE `charges` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    `user_id` integer,
+    `card_id` integer,
+    `amount` integer,
+    `currency` text,
+    `status` text,
+    `gateway` text,
+    `gateway_reference` text,
+    `captured_amount` integer,
+    `refunded_amount` integer,
+    `failure_code` text,
+    `idempotency_key` text
+);
+CREATE INDEX `idx_charges_card_id` ON `charges`(`card_id`);
+CREATE UNIQUE INDEX `idx_charges_user_idempotency` ON `charges`(`user_id`,`idempotency_key`);
+CREATE INDEX `idx_charges_deleted_at` ON `charges`(`deleted_at`);
+CREATE UNIQUE INDEX `idx_charges_gateway_reference` ON `charges`(`gateway`,`gateway_reference`);
+CREATE INDEX `idx_charges_status` ON `charges`(`status`);
+
+CREATE TABLE `charge_transactions` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    `charge_id` integer,
+    `type` text,
+    `amount` integer,
+    `from_status` text,
+    `to_status` text,
+    `gateway_reference` text,
+    `failure_code` text,
+    `idempotency_key` text
+);
+CREATE UNIQUE INDEX `idx_charge_transactions_idempotency` ON `charge_transactions`(`charge_id`,`idempotency_key`);
+CREATE INDEX `idx_charge_transactions_deleted_at` ON `charge_transactions`(`deleted_at`);
+
+CREATE TABLE `webhook_events` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    `gateway` text,
+    `event_id` text,
+    `type` text,
+    `payload` text
+);
+CREATE UNIQUE INDEX `idx_webhook_events_gateway_event` ON `webhook_events`(`gateway`,`event_id`);
+CREATE INDEX `idx_webhook_events_deleted_at` ON `webhook_events`(`deleted_at`);
+
+CREATE TABLE `idempotency_records` (
+    `idempotency_key` text,
+    `fingerprint` text,
+    `owner` text,
+    `status` integer,
+    `header` text,
+    `body` blob,
+    `expires_at` datetime,
+    `created_at` datetime,
+    PRIMARY KEY (`idempotency_key`)
+);
+CREATE INDEX `idx_idempotency_records_expires_at` ON `idempotency_records`(`expires_at`);
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// IdempotencyRecord remembers the first response to a request sent with an
+// Idempotency-Key header. Status is zero while that request is in flight.
+type IdempotencyRecord struct {
+	Key         string `gorm:"column:idempotency_key;primaryKey;size:64"`
+	Fingerprint string `gorm:"size:64"`
+	Owner       string `gorm:"size:32"`
+	Status      int
+	Header      string `gorm:"type:text"`
+	Body        []byte
+	ExpiresAt   time.Time `gorm:"index"`
+	CreatedAt   time.Time
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+type ChargeStatus string
+
+const (
+	ChargePending           ChargeStatus = "pending"
+	ChargeAuthorized        ChargeStatus = "authorized"
+	ChargeCaptured          ChargeStatus = "captured"
+	ChargePartiallyRefunded ChargeStatus = "partially_refunded"
+	ChargeRefunded          ChargeStatus = "refunded"
+	ChargeVoided            ChargeStatus = "voided"
+	ChargeFailed            ChargeStatus = "failed"
+)
+
+// Charge is a single payment against one of a user's cards. Amounts are in
+// the currency's minor unit.
+type Charge struct {
+	gorm.Model
+	UserID           uint64 `gorm:"uniqueIndex:idx_charges_user_idempotency"`
+	CardID           uint64 `gorm:"index"`
+	Amount           int64
+	Currency         string       `gorm:"size:3"`
+	Status           ChargeStatus `gorm:"size:32;index"`
+	Gateway          string       `gorm:"size:32;uniqueIndex:idx_charges_gateway_reference"`
+	GatewayReference string       `gorm:"size:128;uniqueIndex:idx_charges_gateway_reference"`
+	CapturedAmount   int64
+	RefundedAmount   int64
+	FailureCode      string `gorm:"size:64"`
+	IdempotencyKey   string `gorm:"size:255;uniqueIndex:idx_charges_user_idempotency"`
+}
+
+type TransactionType string
+
+const (
+	TransactionAuthorize TransactionType = "authorize"
+	TransactionCapture   TransactionType = "capture"
+	TransactionRefund    TransactionType = "refund"
+	TransactionVoid      TransactionType = "void"
+	TransactionWebhook   TransactionType = "webhook"
+)
+
+// ChargeTransaction is an append-only ledger entry for every operation
+// performed on a charge.
+type ChargeTransaction struct {
+	gorm.Model
+	ChargeID         uint64          `gorm:"uniqueIndex:idx_charge_transactions_idempotency"`
+	Type             TransactionType `gorm:"size:16"`
+	Amount           int64
+	FromStatus       ChargeStatus `gorm:"size:32"`
+	ToStatus         ChargeStatus `gorm:"size:32"`
+	GatewayReference string       `gorm:"size:128"`
+	FailureCode      string       `gorm:"size:64"`
+	IdempotencyKey   *string      `gorm:"size:255;uniqueIndex:idx_charge_transactions_idempotency"`
+}
+
+// WebhookEvent remembers processed gateway events so redeliveries are ignored.
+type WebhookEvent struct {
+	gorm.Model
+	Gateway string `gorm:"size:32;uniqueIndex:idx_webhook_events_gateway_event"`
+	EventID string `gorm:"size:128;uniqueIndex:idx_webhook_events_gateway_event"`
+	Type    string `gorm:"size:64"`
+	Payload string `gorm:"type:text"`
+}
@@ -1,17 +1,25 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
 type User struct {
 	gorm.Model
-	ID         uint64     `gorm:"primaryKey"`
-	Username   string     `gorm:"size:64"`
-	Password   string     `gorm:"size:255"`
-	Notes      []Note     `gorm:"foreignKey:UserID"`
-	CreditCard CreditCard `gorm:"foreignKey:UserID"`
-	Role       Role       `json:"role"`
+	ID uint64 `gorm:"primaryKey"`
+	// Usernames are unique among users that are not deleted, so a deleted
+	// user's name can be taken again.
+	Username    string       `gorm:"size:64;uniqueIndex:idx_users_username_live,where:deleted_at IS NULL"`
+	Password    string       `gorm:"size:255"`
+	Notes       []Note       `gorm:"foreignKey:UserID"`
+	CreditCards []CreditCard `gorm:"foreignKey:UserID"`
+	Role        Role         `json:"role"`
+	Version     uint64       `gorm:"not null;default:1"`
+	// ErasedAt is set once the user's personal data was erased. An erased
+	// user is never restored.
+	ErasedAt *time.Time
 }
 
 type Note struct {
@@ -20,10 +28,31 @@ type Note struct {
 	Name    string `gorm:"size:255"`
 	Content string `gorm:"type:text"`
 	UserID  uint64 `gorm:"index"`
+	Version uint64 `gorm:"not null;default:1"`
 }
 
+// CreditCard only references the card number through its vault token.
+// Number is accepted on input and cleared once the card has been tokenized.
 type CreditCard struct {
 	gorm.Model
-	Number string
-	UserID uint64 `gorm:"primaryKey"`
+	Number               string `gorm:"-" json:"Number,omitempty"`
+	Token                string `gorm:"size:64;index"`
+	Brand                string `gorm:"size:16"`
+	Last4                string `gorm:"size:4"`
+	ExpMonth             int
+	ExpYear              int
+	HolderName           string  `gorm:"size:255"`
+	BillingAddress       Address `gorm:"embedded;embeddedPrefix:billing_"`
+	IsDefault            bool
+	ExpiryReminderSentAt *time.Time `json:"-"`
+	UserID               uint64     `gorm:"index"`
+}
+
+type Address struct {
+	Line1      string `gorm:"size:255"`
+	Line2      string `gorm:"size:255"`
+	City       string `gorm:"size:128"`
+	State      string `gorm:"size:128"`
+	PostalCode string `gorm:"size:32"`
+	Country    string `gorm:"size:2"`
 }
@@ -0,0 +1,24 @@
+package models
+
+import (
+	_ "golang/encryption"
+
+	"gorm.io/gorm"
+)
+
+// VaultEntry is the only place a full card number is stored.
+type VaultEntry struct {
+	gorm.Model
+	Token  string `gorm:"size:64;uniqueIndex"`
+	Number string `gorm:"serializer:encrypted"`
+}
+
+// VaultAuditEntry records a single detokenize attempt.
+type VaultAuditEntry struct {
+	gorm.Model
+	Token    string `gorm:"size:64;index"`
+	ActorID  uint64 `gorm:"index"`
+	Reason   string `gorm:"size:255"`
+	ClientIP string `gorm:"size:64"`
+	Success  bool
+}
@@ -0,0 +1,140 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"gorm.io/gorm/clause"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks the last item of a page. Clients only ever see it encoded and
+// hand it back unchanged to fetch the page that follows. Besides the id it
+// records the sort the page was read with and the item's values for it.
+type Cursor struct {
+	ID   uint64            `json:"id"`
+	Sort string            `json:"sort,omitempty"`
+	Keys []json.RawMessage `json:"keys,omitempty"`
+}
+
+// NewCursor builds the cursor for the item with the given id and sort keys.
+func NewCursor(id uint64, order []Order, keys []interface{}) (Cursor, error) {
+	cursor := Cursor{ID: id, Sort: sortSignature(order)}
+	for _, key := range keys {
+		data, err := json.Marshal(key)
+		if err != nil {
+			return Cursor{}, err
+		}
+		cursor.Keys = append(cursor.Keys, data)
+	}
+	return cursor, nil
+}
+
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(value string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// Order sorts a listing by one column. Decode turns a value of the column
+// read back from a cursor into the type the column is compared with.
+type Order struct {
+	Column string
+	Desc   bool
+	Decode func(json.RawMessage) (interface{}, error)
+}
+
+// Request selects one page of a listing. Rows matching Where are sorted by
+// Order and then by id. With After set the page starts right behind that
+// cursor (keyset paging); otherwise Offset rows are skipped, which gets slower
+// the further a client pages.
+type Request struct {
+	Limit     int
+	Offset    int
+	After     *Cursor
+	WithTotal bool
+	Where     clause.Expression
+	Order     []Order
+	// IncludeDeleted lists soft-deleted rows as well.
+	IncludeDeleted bool
+}
+
+// AfterKeys returns the typed sort keys of After. A cursor read with a
+// different sort cannot be continued and yields ErrInvalidCursor.
+func (r Request) AfterKeys() ([]interface{}, error) {
+	if r.After == nil {
+		return nil, nil
+	}
+	if r.After.Sort != sortSignature(r.Order) || len(r.After.Keys) != len(r.Order) {
+		return nil, ErrInvalidCursor
+	}
+
+	keys := make([]interface{}, len(r.Order))
+	for i, order := range r.Order {
+		key, err := order.Decode(r.After.Keys[i])
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		keys[i] = key
+	}
+	return keys, nil
+}
+
+// Normalize applies the default and maximum page size and drops a negative
+// offset.
+func (r Request) Normalize() Request {
+	if r.Limit <= 0 {
+		r.Limit = DefaultLimit
+	}
+	if r.Limit > MaxLimit {
+		r.Limit = MaxLimit
+	}
+	if r.Offset < 0 {
+		r.Offset = 0
+	}
+	return r
+}
+
+func sortSignature(order []Order) string {
+	columns := make([]string, len(order))
+	for i, o := range order {
+		columns[i] = o.Column
+		if o.Desc {
+			columns[i] = "-" + o.Column
+		}
+	}
+	return strings.Join(columns, ",")
+}
+
+// Page is the envelope every listing is returned in. NextCursor is empty on
+// the last page and Total is only filled in when it was asked for.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      *int64 `json:"total,omitempty"`
+}
+
+// Map returns a page at the same position whose items are converted by
+// convert.
+func Map[T any, R any](page *Page[T], convert func([]T) []R) *Page[R] {
+	return &Page[R]{Items: convert(page.Items), NextCursor: page.NextCursor, Total: page.Total}
+}
@@ -0,0 +1,259 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Error describes a patch that cannot be applied. Callers usually answer it
+// with 422 Unprocessable Entity.
+type Error struct {
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return "invalid patch: " + e.Reason
+}
+
+// Operation is a single RFC 6902 operation. Value is empty when the
+// operation has no value member; a JSON null value is the literal null.
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// DecodeJSONPatch parses an RFC 6902 JSON Patch document.
+func DecodeJSONPatch(patch []byte) ([]Operation, error) {
+	var operations []Operation
+	if err := json.Unmarshal(patch, &operations); err != nil {
+		return nil, &Error{Reason: "patch must be a JSON array of operations"}
+	}
+	return operations, nil
+}
+
+// ApplyJSONPatch applies the operations to doc in order. Either every
+// operation succeeds or doc is left unchanged.
+func ApplyJSONPatch(doc []byte, operations []Operation) ([]byte, error) {
+	var target interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, &Error{Reason: "document is not valid JSON"}
+	}
+
+	for i, operation := range operations {
+		var err error
+		target, err = apply(target, operation)
+		if err != nil {
+			return nil, &Error{Reason: fmt.Sprintf("operation %d (%s %s): %s", i, operation.Op, operation.Path, err)}
+		}
+	}
+	return json.Marshal(target)
+}
+
+func apply(doc interface{}, operation Operation) (interface{}, error) {
+	path, err := parsePointer(operation.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch operation.Op {
+	case "add", "replace", "test":
+		if len(operation.Value) == 0 {
+			return nil, fmt.Errorf("missing value")
+		}
+		var value interface{}
+		if err := json.Unmarshal(operation.Value, &value); err != nil {
+			return nil, err
+		}
+		switch operation.Op {
+		case "add":
+			return add(doc, path, value)
+		case "replace":
+			if _, err := get(doc, path); err != nil {
+				return nil, err
+			}
+			doc, _, err = remove(doc, path)
+			if err != nil {
+				return nil, err
+			}
+			return add(doc, path, value)
+		default:
+			current, err := get(doc, path)
+			if err != nil {
+				return nil, err
+			}
+			if !reflect.DeepEqual(current, value) {
+				return nil, fmt.Errorf("test failed")
+			}
+			return doc, nil
+		}
+	case "remove":
+		doc, _, err = remove(doc, path)
+		return doc, err
+	case "move", "copy":
+		from, err := parsePointer(operation.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if operation.Op == "move" {
+			if strings.HasPrefix(operation.Path+"/", operation.From+"/") && operation.Path != operation.From {
+				return nil, fmt.Errorf("cannot move a value into itself")
+			}
+			doc, _, err = remove(doc, from)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			value = deepCopy(value)
+		}
+		return add(doc, path, value)
+	}
+	return nil, fmt.Errorf("unknown operation %q", operation.Op)
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into unescaped tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("path %q must start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func get(doc interface{}, path []string) (interface{}, error) {
+	current := doc
+	for _, token := range path {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("path not found")
+			}
+			current = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			current = node[index]
+		default:
+			return nil, fmt.Errorf("path not found")
+		}
+	}
+	return current, nil
+}
+
+func add(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		node[last] = value
+		return doc, nil
+	case []interface{}:
+		index := len(node)
+		if last != "-" {
+			index, err = arrayIndex(last, len(node))
+			if err != nil {
+				return nil, err
+			}
+		}
+		updated := append(node[:index:index], append([]interface{}{value}, node[index:]...)...)
+		return replaceAt(doc, path[:len(path)-1], updated)
+	}
+	return nil, fmt.Errorf("parent is not a container")
+}
+
+func remove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	last := path[len(path)-1]
+
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		value, ok := node[last]
+		if !ok {
+			return nil, nil, fmt.Errorf("path not found")
+		}
+		delete(node, last)
+		return doc, value, nil
+	case []interface{}:
+		index, err := arrayIndex(last, len(node)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		value := node[index]
+		updated := append(node[:index:index], node[index+1:]...)
+		doc, err = replaceAt(doc, path[:len(path)-1], updated)
+		return doc, value, err
+	}
+	return nil, nil, fmt.Errorf("parent is not a container")
+}
+
+// replaceAt swaps the array at path for updated, since growing or shrinking
+// a slice produces a new value that the parent must point to.
+func replaceAt(doc interface{}, path []string, updated []interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return updated, nil
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		node[last] = updated
+	case []interface{}:
+		index, err := arrayIndex(last, len(node)-1)
+		if err != nil {
+			return nil, err
+		}
+		node[index] = updated
+	}
+	return doc, nil
+}
+
+func arrayIndex(token string, max int) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || index > max {
+		return 0, fmt.Errorf("array index %q out of range", token)
+	}
+	return index, nil
+}
+
+func deepCopy(value interface{}) interface{} {
+	data, _ := json.Marshal(value)
+	var copied interface{}
+	json.Unmarshal(data, &copied)
+	return copied
+}
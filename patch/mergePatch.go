@@ -0,0 +1,45 @@
+package patch
+
+import (
+	"encoding/json"
+)
+
+const (
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+)
+
+// MergePatch applies an RFC 7386 JSON Merge Patch to doc and returns the
+// patched document.
+func MergePatch(doc []byte, patch []byte) ([]byte, error) {
+	var target interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, &Error{Reason: "document is not valid JSON"}
+	}
+	var patchValue interface{}
+	if err := json.Unmarshal(patch, &patchValue); err != nil {
+		return nil, &Error{Reason: "patch is not valid JSON"}
+	}
+
+	return json.Marshal(mergeValue(target, patchValue))
+}
+
+func mergeValue(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergeValue(targetObject[key], value)
+	}
+	return targetObject
+}
@@ -0,0 +1,85 @@
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      string
+		patch    string
+		expected string
+	}{
+		{"Replace Field", `{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"Add Field", `{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{"Remove Field", `{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{"Nested Object", `{"a":{"b":"c","d":"e"}}`, `{"a":{"d":null,"f":"g"}}`, `{"a":{"b":"c","f":"g"}}`},
+		{"Arrays Are Replaced", `{"a":[1,2]}`, `{"a":[3]}`, `{"a":[3]}`},
+		{"Non Object Patch", `{"a":"b"}`, `["c"]`, `["c"]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.expected, string(result))
+		})
+	}
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      string
+		patch    string
+		expected string
+	}{
+		{"Add", `{"a":1}`, `[{"op":"add","path":"/b","value":2}]`, `{"a":1,"b":2}`},
+		{"Add To Array", `{"a":[1,3]}`, `[{"op":"add","path":"/a/1","value":2}]`, `{"a":[1,2,3]}`},
+		{"Append To Array", `{"a":[1]}`, `[{"op":"add","path":"/a/-","value":2}]`, `{"a":[1,2]}`},
+		{"Remove", `{"a":1,"b":2}`, `[{"op":"remove","path":"/a"}]`, `{"b":2}`},
+		{"Remove From Array", `{"a":[1,2,3]}`, `[{"op":"remove","path":"/a/1"}]`, `{"a":[1,3]}`},
+		{"Replace", `{"a":1}`, `[{"op":"replace","path":"/a","value":"x"}]`, `{"a":"x"}`},
+		{"Move", `{"a":1}`, `[{"op":"move","from":"/a","path":"/b"}]`, `{"b":1}`},
+		{"Copy", `{"a":{"x":1}}`, `[{"op":"copy","from":"/a","path":"/b"}]`, `{"a":{"x":1},"b":{"x":1}}`},
+		{"Test", `{"a":[1,2]}`, `[{"op":"test","path":"/a","value":[1,2]}]`, `{"a":[1,2]}`},
+		{"Null Value", `{"a":1}`, `[{"op":"replace","path":"/a","value":null},{"op":"test","path":"/a","value":null},{"op":"add","path":"/b","value":null}]`, `{"a":null,"b":null}`},
+		{"Escaped Pointer", `{"a/b":1,"c~d":2}`, `[{"op":"remove","path":"/a~1b"},{"op":"remove","path":"/c~0d"}]`, `{}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			operations, err := DecodeJSONPatch([]byte(tt.patch))
+			assert.NoError(t, err)
+			result, err := ApplyJSONPatch([]byte(tt.doc), operations)
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.expected, string(result))
+		})
+	}
+
+	failures := []struct {
+		name  string
+		patch string
+	}{
+		{"Failed Test", `[{"op":"test","path":"/a","value":2}]`},
+		{"Replace Missing", `[{"op":"replace","path":"/missing","value":2}]`},
+		{"Remove Missing", `[{"op":"remove","path":"/missing"}]`},
+		{"Index Out Of Range", `[{"op":"add","path":"/list/5","value":2}]`},
+		{"Missing Value", `[{"op":"replace","path":"/a"}]`},
+		{"Unknown Operation", `[{"op":"frobnicate","path":"/a"}]`},
+		{"Relative Path", `[{"op":"remove","path":"a"}]`},
+	}
+
+	for _, tt := range failures {
+		t.Run(tt.name, func(t *testing.T) {
+			operations, err := DecodeJSONPatch([]byte(tt.patch))
+			assert.NoError(t, err)
+			_, err = ApplyJSONPatch([]byte(`{"a":1,"list":[]}`), operations)
+			var patchErr *Error
+			assert.ErrorAs(t, err, &patchErr)
+		})
+	}
+}
@@ -0,0 +1,407 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"golang/dao"
+	"golang/models"
+	"golang/vault"
+	"log"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrIdempotencyKeyRequired = errors.New("an idempotency key is required")
+	ErrIdempotencyConflict    = errors.New("idempotency key was already used for a different request")
+	ErrInvalidAmount          = errors.New("amount must be positive and within the charge")
+	ErrInvalidCurrency        = errors.New("currency must be a three letter ISO code")
+	ErrCardNotFound           = errors.New("card not found")
+	ErrChargeNotFound         = errors.New("charge not found")
+	ErrUnknownGateway         = errors.New("unknown payment gateway")
+)
+
+// DeclinedError is returned when the gateway declines an operation. The
+// charge and ledger still record the attempt.
+type DeclinedError struct {
+	Code string
+}
+
+func (e *DeclinedError) Error() string {
+	return "payment declined: " + e.Code
+}
+
+type ChargeRequest struct {
+	CardID   uint64 `json:"card_id" binding:"required"`
+	Amount   int64  `json:"amount" binding:"required"`
+	Currency string `json:"currency" binding:"required"`
+	// Capture captures the full amount right after a successful authorization.
+	Capture bool `json:"capture"`
+}
+
+type IChargeService interface {
+	Authorize(ctx context.Context, userID uint64, request ChargeRequest, idempotencyKey string) (*models.Charge, error)
+	Capture(ctx context.Context, chargeID uint64, amount int64, idempotencyKey string) (*models.Charge, error)
+	Refund(ctx context.Context, chargeID uint64, amount int64, idempotencyKey string) (*models.Charge, error)
+	Void(ctx context.Context, chargeID uint64, idempotencyKey string) (*models.Charge, error)
+	Get(ctx context.Context, chargeID uint64) (*models.Charge, []models.ChargeTransaction, error)
+	HandleWebhook(ctx context.Context, gateway string, header http.Header, payload []byte) error
+}
+
+type ChargeService struct {
+	chargeDao      dao.IChargeDao
+	cardDao        dao.ICardDao
+	cardVault      vault.IVault
+	gateways       map[string]Gateway
+	defaultGateway string
+}
+
+// NewChargeService creates a charge service. New charges go through the first
+// gateway; the others are kept so existing charges and webhooks still work.
+func NewChargeService(chargeDao dao.IChargeDao, cardDao dao.ICardDao, cardVault vault.IVault, gateway Gateway, others ...Gateway) *ChargeService {
+	gateways := map[string]Gateway{gateway.Name(): gateway}
+	for _, other := range others {
+		gateways[other.Name()] = other
+	}
+	return &ChargeService{
+		chargeDao:      chargeDao,
+		cardDao:        cardDao,
+		cardVault:      cardVault,
+		gateways:       gateways,
+		defaultGateway: gateway.Name(),
+	}
+}
+
+func (s *ChargeService) Authorize(ctx context.Context, userID uint64, request ChargeRequest, idempotencyKey string) (*models.Charge, error) {
+	if idempotencyKey == "" {
+		return nil, ErrIdempotencyKeyRequired
+	}
+
+	existing, err := s.chargeDao.FindByIdempotencyKey(ctx, userID, idempotencyKey)
+	if err == nil {
+		return replayCharge(existing, request)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if request.Amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	if len(request.Currency) != 3 {
+		return nil, ErrInvalidCurrency
+	}
+
+	card, err := s.cardDao.Get(ctx, userID, request.CardID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCardNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	number, err := s.cardVault.Detokenize(ctx, card.Token, vault.Access{ActorID: userID, Reason: "payment authorization"})
+	if err != nil {
+		return nil, err
+	}
+
+	gateway := s.gateways[s.defaultGateway]
+	result, err := gateway.Authorize(ctx, AuthorizeRequest{
+		CardNumber:     number,
+		ExpMonth:       card.ExpMonth,
+		ExpYear:        card.ExpYear,
+		Amount:         request.Amount,
+		Currency:       request.Currency,
+		IdempotencyKey: idempotencyKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	charge := &models.Charge{
+		UserID:           userID,
+		CardID:           request.CardID,
+		Amount:           request.Amount,
+		Currency:         request.Currency,
+		Status:           authorizeStatus(result.Status),
+		Gateway:          gateway.Name(),
+		GatewayReference: result.Reference,
+		FailureCode:      result.FailureCode,
+		IdempotencyKey:   idempotencyKey,
+	}
+	entry := &models.ChargeTransaction{
+		Type:             models.TransactionAuthorize,
+		Amount:           request.Amount,
+		ToStatus:         charge.Status,
+		GatewayReference: result.Reference,
+		FailureCode:      result.FailureCode,
+	}
+	ledgerCtx, cancel := ledgerContext(ctx)
+	defer cancel()
+	if err := s.chargeDao.Create(ledgerCtx, charge, entry); err != nil {
+		// A concurrent request with the same key may have won the insert.
+		if existing, findErr := s.chargeDao.FindByIdempotencyKey(ledgerCtx, userID, idempotencyKey); findErr == nil {
+			return replayCharge(existing, request)
+		}
+		return nil, err
+	}
+
+	if result.Status == ResultDeclined {
+		return charge, &DeclinedError{Code: result.FailureCode}
+	}
+	if request.Capture && charge.Status == models.ChargeAuthorized {
+		return s.Capture(ctx, uint64(charge.ID), charge.Amount, idempotencyKey+":capture")
+	}
+	return charge, nil
+}
+
+// Capture captures amount of an authorized charge; zero captures the full
+// authorized amount.
+func (s *ChargeService) Capture(ctx context.Context, chargeID uint64, amount int64, idempotencyKey string) (*models.Charge, error) {
+	return s.operate(ctx, chargeID, models.TransactionCapture, idempotencyKey, func(charge *models.Charge, gateway Gateway) (int64, models.ChargeStatus, Result, error) {
+		if amount == 0 {
+			amount = charge.Amount
+		}
+		if amount < 0 || amount > charge.Amount {
+			return 0, "", Result{}, ErrInvalidAmount
+		}
+		if err := checkTransition(charge.Status, models.ChargeCaptured); err != nil {
+			return 0, "", Result{}, err
+		}
+
+		result, err := gateway.Capture(ctx, charge.GatewayReference, amount, idempotencyKey)
+		if err == nil && result.Status == ResultApproved {
+			charge.CapturedAmount = amount
+		}
+		return amount, models.ChargeCaptured, result, err
+	})
+}
+
+// Refund refunds amount of a captured charge; zero refunds whatever has not
+// been refunded yet.
+func (s *ChargeService) Refund(ctx context.Context, chargeID uint64, amount int64, idempotencyKey string) (*models.Charge, error) {
+	return s.operate(ctx, chargeID, models.TransactionRefund, idempotencyKey, func(charge *models.Charge, gateway Gateway) (int64, models.ChargeStatus, Result, error) {
+		remaining := charge.CapturedAmount - charge.RefundedAmount
+		if amount == 0 {
+			amount = remaining
+		}
+		if amount <= 0 || amount > remaining {
+			return 0, "", Result{}, ErrInvalidAmount
+		}
+		next := models.ChargePartiallyRefunded
+		if amount == remaining {
+			next = models.ChargeRefunded
+		}
+		if err := checkTransition(charge.Status, next); err != nil {
+			return 0, "", Result{}, err
+		}
+
+		result, err := gateway.Refund(ctx, charge.GatewayReference, amount, idempotencyKey)
+		if err == nil && result.Status == ResultApproved {
+			charge.RefundedAmount += amount
+		}
+		return amount, next, result, err
+	})
+}
+
+func (s *ChargeService) Void(ctx context.Context, chargeID uint64, idempotencyKey string) (*models.Charge, error) {
+	return s.operate(ctx, chargeID, models.TransactionVoid, idempotencyKey, func(charge *models.Charge, gateway Gateway) (int64, models.ChargeStatus, Result, error) {
+		if err := checkTransition(charge.Status, models.ChargeVoided); err != nil {
+			return 0, "", Result{}, err
+		}
+		result, err := gateway.Void(ctx, charge.GatewayReference, idempotencyKey)
+		return charge.Amount, models.ChargeVoided, result, err
+	})
+}
+
+func (s *ChargeService) Get(ctx context.Context, chargeID uint64) (*models.Charge, []models.ChargeTransaction, error) {
+	charge, err := s.getCharge(ctx, chargeID)
+	if err != nil {
+		return nil, nil, err
+	}
+	entries, err := s.chargeDao.ListTransactions(ctx, chargeID)
+	return charge, entries, err
+}
+
+// HandleWebhook applies an asynchronous status update from a gateway.
+// Redelivered events and events for charges that already settled are ignored.
+func (s *ChargeService) HandleWebhook(ctx context.Context, gatewayName string, header http.Header, payload []byte) error {
+	gateway, ok := s.gateways[gatewayName]
+	if !ok {
+		return ErrUnknownGateway
+	}
+
+	event, err := gateway.ParseWebhook(header, payload)
+	if err != nil {
+		return err
+	}
+
+	record := &models.WebhookEvent{
+		Gateway: gatewayName,
+		EventID: event.ID,
+		Type:    string(event.Type),
+		Payload: string(payload),
+	}
+	// Events that change nothing are only recorded.
+	ignore := func() error {
+		_, err := s.chargeDao.RecordWebhookEvent(ctx, record)
+		return err
+	}
+
+	charge, err := s.chargeDao.FindByReference(ctx, gatewayName, event.Reference)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("Ignoring %s webhook %s for unknown charge %s", gatewayName, event.ID, event.Reference)
+		return ignore()
+	}
+	if err != nil {
+		return err
+	}
+
+	var next models.ChargeStatus
+	switch event.Type {
+	case EventChargeSucceeded:
+		next = models.ChargeAuthorized
+	case EventChargeFailed:
+		next = models.ChargeFailed
+	default:
+		return ignore()
+	}
+	if !canTransition(charge.Status, next) {
+		return ignore()
+	}
+
+	before := *charge
+	charge.Status = next
+	charge.FailureCode = event.FailureCode
+	// The event is recorded with the change, so if the change fails the
+	// gateway's redelivery is processed again instead of being dropped.
+	err = s.chargeDao.Apply(ctx, before, charge, &models.ChargeTransaction{
+		Type:             models.TransactionWebhook,
+		FromStatus:       before.Status,
+		ToStatus:         next,
+		GatewayReference: event.Reference,
+		FailureCode:      event.FailureCode,
+	}, record)
+	if errors.Is(err, dao.ErrDuplicateWebhookEvent) {
+		return nil
+	}
+	return err
+}
+
+// gatewayCall performs one operation on a charge and returns the amount
+// involved, the status the charge moves to on approval and the gateway result.
+type gatewayCall func(charge *models.Charge, gateway Gateway) (int64, models.ChargeStatus, Result, error)
+
+// operate runs a gateway call for an existing charge and records it in the
+// ledger. A repeated idempotency key returns the charge without calling the
+// gateway again.
+func (s *ChargeService) operate(ctx context.Context, chargeID uint64, txType models.TransactionType, idempotencyKey string, call gatewayCall) (*models.Charge, error) {
+	if idempotencyKey == "" {
+		return nil, ErrIdempotencyKeyRequired
+	}
+
+	charge, err := s.getCharge(ctx, chargeID)
+	if err != nil {
+		return nil, err
+	}
+
+	previous, err := s.chargeDao.FindTransaction(ctx, chargeID, idempotencyKey)
+	if err == nil {
+		if previous.Type != txType {
+			return nil, ErrIdempotencyConflict
+		}
+		if previous.FailureCode != "" {
+			return charge, &DeclinedError{Code: previous.FailureCode}
+		}
+		return charge, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	gateway, ok := s.gateways[charge.Gateway]
+	if !ok {
+		return nil, ErrUnknownGateway
+	}
+
+	before := *charge
+	amount, next, result, err := call(charge, gateway)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &models.ChargeTransaction{
+		Type:             txType,
+		Amount:           amount,
+		FromStatus:       before.Status,
+		ToStatus:         before.Status,
+		GatewayReference: result.Reference,
+		FailureCode:      result.FailureCode,
+		IdempotencyKey:   &idempotencyKey,
+	}
+	if result.Status == ResultApproved {
+		charge.Status = next
+		entry.ToStatus = next
+	}
+	ledgerCtx, cancel := ledgerContext(ctx)
+	defer cancel()
+	if err := s.chargeDao.Apply(ledgerCtx, before, charge, entry, nil); err != nil {
+		return nil, err
+	}
+
+	if result.Status == ResultDeclined {
+		return charge, &DeclinedError{Code: result.FailureCode}
+	}
+	return charge, nil
+}
+
+func (s *ChargeService) getCharge(ctx context.Context, chargeID uint64) (*models.Charge, error) {
+	charge, err := s.chargeDao.GetByID(ctx, chargeID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrChargeNotFound
+	}
+	return charge, err
+}
+
+func replayCharge(existing *models.Charge, request ChargeRequest) (*models.Charge, error) {
+	if existing.CardID != request.CardID || existing.Amount != request.Amount || existing.Currency != request.Currency {
+		return nil, ErrIdempotencyConflict
+	}
+	if existing.Status == models.ChargeFailed {
+		return existing, &DeclinedError{Code: existing.FailureCode}
+	}
+	return existing, nil
+}
+
+// ledgerTimeout bounds a ledger write that outlives its request.
+const ledgerTimeout = 10 * time.Second
+
+// ledgerContext returns the context for recording a gateway call that
+// already happened. It keeps the values of ctx but not its cancellation: a
+// client that disconnects or a request timeout must not leave money moved
+// at the gateway without a ledger entry.
+func ledgerContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(detachedContext{ctx}, ledgerTimeout)
+}
+
+// detachedContext is context.WithoutCancel, which needs Go 1.21.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
+
+func authorizeStatus(status ResultStatus) models.ChargeStatus {
+	switch status {
+	case ResultApproved:
+		return models.ChargeAuthorized
+	case ResultPending:
+		return models.ChargePending
+	}
+	return models.ChargeFailed
+}
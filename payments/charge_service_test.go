@@ -0,0 +1,302 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"golang/dao"
+	"golang/encryption"
+	"golang/models"
+	"golang/vault"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type testEnv struct {
+	db        *gorm.DB
+	gateway   *FakeGateway
+	service   *ChargeService
+	cardVault *vault.Vault
+}
+
+// setupPayments wires the charge service to an in-memory SQLite database,
+// a real vault and the fake gateway.
+func setupPayments(t *testing.T) *testEnv {
+	provider, err := encryption.NewLocalKeyProvider("test", map[string][]byte{
+		"test": bytes.Repeat([]byte{7}, encryption.MasterKeySize),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create key provider: %v", err)
+	}
+	encryption.SetKeyProvider(provider)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to in-memory database: %v", err)
+	}
+	err = db.AutoMigrate(&models.User{}, &models.CreditCard{}, &models.VaultEntry{}, &models.VaultAuditEntry{},
+		&models.Charge{}, &models.ChargeTransaction{}, &models.WebhookEvent{})
+	if err != nil {
+		t.Fatalf("Failed to migrate database schema: %v", err)
+	}
+	t.Cleanup(func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	})
+
+	cardVault := vault.NewVault(dao.NewVaultDao(db))
+	gateway := NewFakeGateway("secret")
+	service := NewChargeService(dao.NewChargeDao(db), dao.NewCardDao(db), cardVault, gateway)
+	return &testEnv{db: db, gateway: gateway, service: service, cardVault: cardVault}
+}
+
+func (e *testEnv) addCard(t *testing.T, userID uint64, number string) uint64 {
+	token, err := e.cardVault.Tokenize(context.Background(), number)
+	assert.NoError(t, err)
+	card := &models.CreditCard{UserID: userID, Token: token, Last4: vault.Last4(number), ExpMonth: 12, ExpYear: 2099}
+	assert.NoError(t, dao.NewCardDao(e.db).Create(context.Background(), card))
+	return uint64(card.ID)
+}
+
+func TestChargeService_FullFlow(t *testing.T) {
+	env := setupPayments(t)
+	cardID := env.addCard(t, 1, "4242424242424242")
+
+	charge, err := env.service.Authorize(context.Background(), 1, ChargeRequest{CardID: cardID, Amount: 1000, Currency: "EUR"}, "order-1")
+	assert.NoError(t, err)
+	assert.Equal(t, models.ChargeAuthorized, charge.Status)
+	chargeID := uint64(charge.ID)
+
+	t.Run("Authorize Is Idempotent", func(t *testing.T) {
+		again, err := env.service.Authorize(context.Background(), 1, ChargeRequest{CardID: cardID, Amount: 1000, Currency: "EUR"}, "order-1")
+		assert.NoError(t, err)
+		assert.Equal(t, charge.ID, again.ID)
+
+		_, err = env.service.Authorize(context.Background(), 1, ChargeRequest{CardID: cardID, Amount: 2000, Currency: "EUR"}, "order-1")
+		assert.ErrorIs(t, err, ErrIdempotencyConflict)
+	})
+
+	t.Run("Capture", func(t *testing.T) {
+		charge, err := env.service.Capture(context.Background(), chargeID, 0, "capture-1")
+		assert.NoError(t, err)
+		assert.Equal(t, models.ChargeCaptured, charge.Status)
+		assert.Equal(t, int64(1000), charge.CapturedAmount)
+	})
+
+	t.Run("Void After Capture Is Rejected", func(t *testing.T) {
+		_, err := env.service.Void(context.Background(), chargeID, "void-1")
+		var invalid *InvalidTransitionError
+		assert.True(t, errors.As(err, &invalid))
+	})
+
+	t.Run("Partial Then Full Refund", func(t *testing.T) {
+		charge, err := env.service.Refund(context.Background(), chargeID, 400, "refund-1")
+		assert.NoError(t, err)
+		assert.Equal(t, models.ChargePartiallyRefunded, charge.Status)
+
+		// Retrying the same refund must not refund twice.
+		charge, err = env.service.Refund(context.Background(), chargeID, 400, "refund-1")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(400), charge.RefundedAmount)
+
+		_, err = env.service.Refund(context.Background(), chargeID, 700, "refund-2")
+		assert.ErrorIs(t, err, ErrInvalidAmount)
+
+		charge, err = env.service.Refund(context.Background(), chargeID, 0, "refund-3")
+		assert.NoError(t, err)
+		assert.Equal(t, models.ChargeRefunded, charge.Status)
+		assert.Equal(t, int64(1000), charge.RefundedAmount)
+	})
+
+	t.Run("Ledger", func(t *testing.T) {
+		_, entries, err := env.service.Get(context.Background(), chargeID)
+		assert.NoError(t, err)
+		types := []models.TransactionType{}
+		for _, entry := range entries {
+			types = append(types, entry.Type)
+		}
+		assert.Equal(t, []models.TransactionType{
+			models.TransactionAuthorize, models.TransactionCapture, models.TransactionRefund, models.TransactionRefund,
+		}, types)
+	})
+
+	t.Run("Detokenize Is Audited", func(t *testing.T) {
+		var count int64
+		env.db.Model(&models.VaultAuditEntry{}).Where("reason = ?", "payment authorization").Count(&count)
+		assert.Equal(t, int64(1), count)
+	})
+}
+
+func TestChargeService_Concurrency(t *testing.T) {
+	env := setupPayments(t)
+	cardID := env.addCard(t, 1, "4242424242424242")
+	ctx := context.Background()
+
+	charge, err := env.service.Authorize(ctx, 1, ChargeRequest{CardID: cardID, Amount: 1000, Currency: "EUR"}, "order-1")
+	assert.NoError(t, err)
+	chargeID := uint64(charge.ID)
+	_, err = env.service.Capture(ctx, chargeID, 0, "capture-1")
+	assert.NoError(t, err)
+	_, err = env.service.Refund(ctx, chargeID, 100, "refund-1")
+	assert.NoError(t, err)
+
+	t.Run("Partial Refunds Do Not Overwrite Each Other", func(t *testing.T) {
+		chargeDao := dao.NewChargeDao(env.db)
+		stale, err := chargeDao.GetByID(ctx, chargeID)
+		assert.NoError(t, err)
+		_, err = env.service.Refund(ctx, chargeID, 300, "refund-2")
+		assert.NoError(t, err)
+
+		// A second refund that read the charge before the first was saved.
+		other := *stale
+		other.RefundedAmount += 200
+		err = chargeDao.Apply(ctx, *stale, &other, &models.ChargeTransaction{
+			Type:       models.TransactionRefund,
+			Amount:     200,
+			FromStatus: stale.Status,
+			ToStatus:   models.ChargePartiallyRefunded,
+		}, nil)
+		assert.ErrorIs(t, err, dao.ErrStaleCharge)
+
+		stored, err := chargeDao.GetByID(ctx, chargeID)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(400), stored.RefundedAmount)
+	})
+
+	t.Run("References Are Unique Across Restarts", func(t *testing.T) {
+		restarted := NewChargeService(dao.NewChargeDao(env.db), dao.NewCardDao(env.db), env.cardVault, NewFakeGateway("secret"))
+		charge, err := restarted.Authorize(ctx, 1, ChargeRequest{CardID: cardID, Amount: 500, Currency: "EUR"}, "order-2")
+		assert.NoError(t, err)
+		assert.Equal(t, models.ChargeAuthorized, charge.Status)
+	})
+}
+
+// cancellingGateway cancels the request once the gateway has acted, as a
+// client that disconnects or a request timeout would.
+type cancellingGateway struct {
+	Gateway
+	cancel context.CancelFunc
+}
+
+func (g cancellingGateway) Authorize(ctx context.Context, request AuthorizeRequest) (Result, error) {
+	defer g.cancel()
+	return g.Gateway.Authorize(ctx, request)
+}
+
+func (g cancellingGateway) Capture(ctx context.Context, reference string, amount int64, idempotencyKey string) (Result, error) {
+	defer g.cancel()
+	return g.Gateway.Capture(ctx, reference, amount, idempotencyKey)
+}
+
+func TestChargeService_CancelledRequest(t *testing.T) {
+	env := setupPayments(t)
+	cardID := env.addCard(t, 1, "4242424242424242")
+	chargeDao := dao.NewChargeDao(env.db)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	service := NewChargeService(chargeDao, dao.NewCardDao(env.db), env.cardVault, cancellingGateway{env.gateway, cancel})
+	charge, err := service.Authorize(ctx, 1, ChargeRequest{CardID: cardID, Amount: 1000, Currency: "EUR"}, "order-1")
+	assert.NoError(t, err)
+	assert.NotZero(t, charge.ID)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	service = NewChargeService(chargeDao, dao.NewCardDao(env.db), env.cardVault, cancellingGateway{env.gateway, cancel})
+	_, err = service.Capture(ctx, uint64(charge.ID), 0, "capture-1")
+	assert.NoError(t, err)
+
+	stored, entries, err := env.service.Get(context.Background(), uint64(charge.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, models.ChargeCaptured, stored.Status)
+	assert.Len(t, entries, 2)
+}
+
+func TestChargeService_Declines(t *testing.T) {
+	env := setupPayments(t)
+
+	tests := []struct {
+		number string
+		code   string
+	}{
+		{FakeCardDeclined, "card_declined"},
+		{FakeCardInsufficientFunds, "insufficient_funds"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			cardID := env.addCard(t, 1, tt.number)
+
+			charge, err := env.service.Authorize(context.Background(), 1, ChargeRequest{CardID: cardID, Amount: 500, Currency: "USD"}, "decline-"+tt.code)
+
+			var declined *DeclinedError
+			assert.True(t, errors.As(err, &declined))
+			assert.Equal(t, tt.code, declined.Code)
+			assert.Equal(t, models.ChargeFailed, charge.Status)
+		})
+	}
+}
+
+// staleOnceChargeDao makes the first Apply lose to a concurrent change.
+type staleOnceChargeDao struct {
+	dao.IChargeDao
+	failed bool
+}
+
+func (d *staleOnceChargeDao) Apply(ctx context.Context, before models.Charge, charge *models.Charge, entry *models.ChargeTransaction, event *models.WebhookEvent) error {
+	if !d.failed {
+		d.failed = true
+		before.Status = models.ChargeFailed
+	}
+	return d.IChargeDao.Apply(ctx, before, charge, entry, event)
+}
+
+func TestChargeService_Webhook(t *testing.T) {
+	env := setupPayments(t)
+	cardID := env.addCard(t, 1, FakeCardPending)
+
+	charge, err := env.service.Authorize(context.Background(), 1, ChargeRequest{CardID: cardID, Amount: 700, Currency: "USD"}, "pending-1")
+	assert.NoError(t, err)
+	assert.Equal(t, models.ChargePending, charge.Status)
+
+	t.Run("Invalid Signature", func(t *testing.T) {
+		header, payload := env.gateway.SettlePending(charge.GatewayReference, true)
+		header.Set(fakeSignatureHeader, "00")
+		err := env.service.HandleWebhook(context.Background(), "fake", header, payload)
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+	})
+
+	t.Run("Succeeded", func(t *testing.T) {
+		header, payload := env.gateway.SettlePending(charge.GatewayReference, true)
+		assert.NoError(t, env.service.HandleWebhook(context.Background(), "fake", header, payload))
+		// Redelivery of the same event is ignored.
+		assert.NoError(t, env.service.HandleWebhook(context.Background(), "fake", header, payload))
+
+		updated, entries, err := env.service.Get(context.Background(), uint64(charge.ID))
+		assert.NoError(t, err)
+		assert.Equal(t, models.ChargeAuthorized, updated.Status)
+		assert.Len(t, entries, 2)
+	})
+
+	t.Run("Failed Update Is Redelivered", func(t *testing.T) {
+		pending, err := env.service.Authorize(context.Background(), 1, ChargeRequest{CardID: cardID, Amount: 300, Currency: "USD"}, "pending-2")
+		assert.NoError(t, err)
+		chargeDao := &staleOnceChargeDao{IChargeDao: dao.NewChargeDao(env.db)}
+		service := NewChargeService(chargeDao, dao.NewCardDao(env.db), env.cardVault, env.gateway)
+
+		header, payload := env.gateway.SettlePending(pending.GatewayReference, true)
+		assert.ErrorIs(t, service.HandleWebhook(context.Background(), "fake", header, payload), dao.ErrStaleCharge)
+		assert.NoError(t, service.HandleWebhook(context.Background(), "fake", header, payload))
+
+		updated, entries, err := env.service.Get(context.Background(), uint64(pending.ID))
+		assert.NoError(t, err)
+		assert.Equal(t, models.ChargeAuthorized, updated.Status)
+		assert.Len(t, entries, 2)
+	})
+
+	t.Run("Unknown Gateway", func(t *testing.T) {
+		err := env.service.HandleWebhook(context.Background(), "other", nil, nil)
+		assert.ErrorIs(t, err, ErrUnknownGateway)
+	})
+}
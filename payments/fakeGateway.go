@@ -0,0 +1,174 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Magic card numbers understood by FakeGateway. Any other number is approved.
+const (
+	FakeCardDeclined          = "4000000000000002"
+	FakeCardInsufficientFunds = "4000000000009995"
+	FakeCardPending           = "4000000000003220"
+)
+
+const fakeSignatureHeader = "X-Fake-Signature"
+
+// FakeGateway is a deterministic in-process gateway for development and
+// tests. Outcomes depend only on the card number, so whole payment flows can
+// run offline. References are random so they stay unique across restarts.
+type FakeGateway struct {
+	secret []byte
+
+	mu           sync.Mutex
+	authorized   map[string]int64
+	captured     map[string]int64
+	refunded     map[string]int64
+	idempotent   map[string]Result
+	eventCounter int
+}
+
+func NewFakeGateway(webhookSecret string) *FakeGateway {
+	return &FakeGateway{
+		secret:     []byte(webhookSecret),
+		authorized: map[string]int64{},
+		captured:   map[string]int64{},
+		refunded:   map[string]int64{},
+		idempotent: map[string]Result{},
+	}
+}
+
+func (f *FakeGateway) Name() string {
+	return "fake"
+}
+
+func (f *FakeGateway) Authorize(ctx context.Context, request AuthorizeRequest) (Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.once("authorize", request.IdempotencyKey, func() Result {
+		reference := "fake_ch_" + randomID()
+
+		switch request.CardNumber {
+		case FakeCardDeclined:
+			return Result{Reference: reference, Status: ResultDeclined, FailureCode: "card_declined"}
+		case FakeCardInsufficientFunds:
+			return Result{Reference: reference, Status: ResultDeclined, FailureCode: "insufficient_funds"}
+		case FakeCardPending:
+			f.authorized[reference] = request.Amount
+			return Result{Reference: reference, Status: ResultPending}
+		}
+		f.authorized[reference] = request.Amount
+		return Result{Reference: reference, Status: ResultApproved}
+	}), nil
+}
+
+func (f *FakeGateway) Capture(ctx context.Context, reference string, amount int64, idempotencyKey string) (Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.once("capture:"+reference, idempotencyKey, func() Result {
+		authorized, ok := f.authorized[reference]
+		if !ok || f.captured[reference]+amount > authorized {
+			return Result{Reference: reference, Status: ResultDeclined, FailureCode: "amount_too_large"}
+		}
+		f.captured[reference] += amount
+		return Result{Reference: reference, Status: ResultApproved}
+	}), nil
+}
+
+func (f *FakeGateway) Refund(ctx context.Context, reference string, amount int64, idempotencyKey string) (Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.once("refund:"+reference, idempotencyKey, func() Result {
+		if f.refunded[reference]+amount > f.captured[reference] {
+			return Result{Reference: reference, Status: ResultDeclined, FailureCode: "amount_too_large"}
+		}
+		f.refunded[reference] += amount
+		return Result{Reference: reference, Status: ResultApproved}
+	}), nil
+}
+
+func (f *FakeGateway) Void(ctx context.Context, reference string, idempotencyKey string) (Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.once("void:"+reference, idempotencyKey, func() Result {
+		if _, ok := f.authorized[reference]; !ok || f.captured[reference] > 0 {
+			return Result{Reference: reference, Status: ResultDeclined, FailureCode: "not_voidable"}
+		}
+		delete(f.authorized, reference)
+		return Result{Reference: reference, Status: ResultApproved}
+	}), nil
+}
+
+func (f *FakeGateway) ParseWebhook(header http.Header, payload []byte) (WebhookEvent, error) {
+	signature, err := hex.DecodeString(header.Get(fakeSignatureHeader))
+	if err != nil || !hmac.Equal(signature, f.sign(payload)) {
+		return WebhookEvent{}, ErrInvalidSignature
+	}
+
+	var event WebhookEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return WebhookEvent{}, err
+	}
+	return event, nil
+}
+
+// SettlePending builds the signed webhook the fake would send once a
+// pending authorization succeeds or fails.
+func (f *FakeGateway) SettlePending(reference string, succeed bool) (http.Header, []byte) {
+	f.mu.Lock()
+	f.eventCounter++
+	event := WebhookEvent{ID: fmt.Sprintf("fake_evt_%d", f.eventCounter), Reference: reference}
+	if succeed {
+		event.Type = EventChargeSucceeded
+	} else {
+		event.Type = EventChargeFailed
+		event.FailureCode = "card_declined"
+		delete(f.authorized, reference)
+	}
+	f.mu.Unlock()
+
+	payload, _ := json.Marshal(event)
+	header := http.Header{}
+	header.Set(fakeSignatureHeader, hex.EncodeToString(f.sign(payload)))
+	return header, payload
+}
+
+// once replays the stored result for a repeated idempotency key. Operations
+// without a key always run.
+func (f *FakeGateway) once(scope string, idempotencyKey string, operation func() Result) Result {
+	if idempotencyKey == "" {
+		return operation()
+	}
+	key := scope + ":" + idempotencyKey
+	if result, ok := f.idempotent[key]; ok {
+		return result
+	}
+	result := operation()
+	f.idempotent[key] = result
+	return result
+}
+
+func randomID() string {
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(id)
+}
+
+func (f *FakeGateway) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, f.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
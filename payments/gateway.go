@@ -0,0 +1,62 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+type ResultStatus string
+
+const (
+	ResultApproved ResultStatus = "approved"
+	ResultDeclined ResultStatus = "declined"
+	// ResultPending means the gateway will report the outcome later through
+	// a webhook.
+	ResultPending ResultStatus = "pending"
+)
+
+// Result is the gateway's answer to an operation. A decline is a normal
+// result, not an error; errors are reserved for failing to reach the gateway.
+type Result struct {
+	Reference   string
+	Status      ResultStatus
+	FailureCode string
+}
+
+type AuthorizeRequest struct {
+	CardNumber     string
+	ExpMonth       int
+	ExpYear        int
+	Amount         int64
+	Currency       string
+	IdempotencyKey string
+}
+
+type WebhookEventType string
+
+const (
+	EventChargeSucceeded WebhookEventType = "charge.succeeded"
+	EventChargeFailed    WebhookEventType = "charge.failed"
+)
+
+type WebhookEvent struct {
+	ID          string           `json:"id"`
+	Type        WebhookEventType `json:"type"`
+	Reference   string           `json:"reference"`
+	FailureCode string           `json:"failure_code,omitempty"`
+}
+
+// Gateway is a payment provider. Implementations must treat the idempotency
+// key passed to them as the provider's own idempotency key where supported.
+type Gateway interface {
+	Name() string
+	Authorize(ctx context.Context, request AuthorizeRequest) (Result, error)
+	Capture(ctx context.Context, reference string, amount int64, idempotencyKey string) (Result, error)
+	Refund(ctx context.Context, reference string, amount int64, idempotencyKey string) (Result, error)
+	Void(ctx context.Context, reference string, idempotencyKey string) (Result, error)
+	// ParseWebhook verifies the signature of an incoming webhook and decodes it.
+	ParseWebhook(header http.Header, payload []byte) (WebhookEvent, error)
+}
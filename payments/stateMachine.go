@@ -0,0 +1,39 @@
+package payments
+
+import (
+	"fmt"
+	"golang/models"
+)
+
+// transitions lists the statuses a charge may move to from each status.
+var transitions = map[models.ChargeStatus][]models.ChargeStatus{
+	models.ChargePending:           {models.ChargeAuthorized, models.ChargeFailed},
+	models.ChargeAuthorized:        {models.ChargeCaptured, models.ChargeVoided},
+	models.ChargeCaptured:          {models.ChargePartiallyRefunded, models.ChargeRefunded},
+	models.ChargePartiallyRefunded: {models.ChargePartiallyRefunded, models.ChargeRefunded},
+}
+
+type InvalidTransitionError struct {
+	From models.ChargeStatus
+	To   models.ChargeStatus
+}
+
+func (e *InvalidTransitionError) Error() string {
+	return fmt.Sprintf("charge cannot move from %s to %s", e.From, e.To)
+}
+
+func canTransition(from models.ChargeStatus, to models.ChargeStatus) bool {
+	for _, allowed := range transitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+func checkTransition(from models.ChargeStatus, to models.ChargeStatus) error {
+	if !canTransition(from, to) {
+		return &InvalidTransitionError{From: from, To: to}
+	}
+	return nil
+}
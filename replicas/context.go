@@ -0,0 +1,28 @@
+package replicas
+
+import "context"
+
+type userKey struct{}
+
+type primaryKey struct{}
+
+// WithUser marks ctx as acting for the user, so that the user's reads go to
+// the primary for a while after they wrote something.
+func WithUser(ctx context.Context, userID uint64) context.Context {
+	return context.WithValue(ctx, userKey{}, userID)
+}
+
+// Primary makes every read made with ctx go to the primary.
+func Primary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+func userOf(ctx context.Context) (uint64, bool) {
+	userID, ok := ctx.Value(userKey{}).(uint64)
+	return userID, ok
+}
+
+func wantsPrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryKey{}).(bool)
+	return primary
+}
@@ -0,0 +1,69 @@
+package replicas
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// LagFunc reports how far a replica is behind the primary.
+type LagFunc func(ctx context.Context, db *sql.DB) (time.Duration, error)
+
+// PostgresLag measures the lag of a streaming replica. A replica that has
+// replayed everything it received is not behind, however long ago the last
+// transaction on the primary was.
+func PostgresLag(ctx context.Context, db *sql.DB) (time.Duration, error) {
+	var seconds float64
+	err := db.QueryRowContext(ctx, `
+		SELECT CASE
+			WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+			ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+		END`).Scan(&seconds)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// MySQLLag reads Seconds_Behind_Source from SHOW REPLICA STATUS.
+func MySQLLag(ctx context.Context, db *sql.DB) (time.Duration, error) {
+	rows, err := db.QueryContext(ctx, "SHOW REPLICA STATUS")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	if !rows.Next() {
+		// Not a replica at all, so nothing to catch up on.
+		return 0, rows.Err()
+	}
+	values := make([]sql.RawBytes, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if err := rows.Scan(pointers...); err != nil {
+		return 0, err
+	}
+
+	for i, column := range columns {
+		if column != "Seconds_Behind_Source" {
+			continue
+		}
+		if values[i] == nil {
+			return 0, errors.New("replication is not running")
+		}
+		seconds, err := strconv.ParseInt(string(values[i]), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return 0, errors.New("SHOW REPLICA STATUS has no Seconds_Behind_Source")
+}
@@ -0,0 +1,285 @@
+// Package replicas routes reads to read replicas of the database and writes
+// to the primary.
+package replicas
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"golang/cache"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// checkTimeout bounds one health check of one replica.
+const checkTimeout = 5 * time.Second
+
+// Replica is a read-only copy of the primary database.
+type Replica struct {
+	Name string
+	DB   *sql.DB
+	// Lag measures the replication lag. Without it the replica is only
+	// pinged.
+	Lag LagFunc
+
+	healthy atomic.Bool
+	checked atomic.Bool
+}
+
+// Healthy reports whether the last check found the replica reachable and
+// close enough to the primary. A replica is unhealthy until it was checked.
+func (r *Replica) Healthy() bool {
+	return r.healthy.Load()
+}
+
+// Router is a GORM plugin built on dbresolver. Reads go to a healthy
+// replica and writes to the primary. Reads fall back to the primary when no
+// replica is healthy, inside transactions, for contexts made with Primary,
+// and for ReadYourWrites after the user in the context (see WithUser) wrote
+// something.
+type Router struct {
+	replicas []*Replica
+
+	// ReadYourWrites is how long a user reads from the primary after a
+	// write.
+	ReadYourWrites time.Duration
+	// Writes shares the users' last writes with the other instances, so a
+	// read that lands on another instance also goes to the primary. Without
+	// it, or with a cache of this process only, the window only covers
+	// reads served by the instance that wrote.
+	Writes cache.Cache
+	// MaxLag is the replication lag at which a replica stops serving reads.
+	// Zero means no limit.
+	MaxLag time.Duration
+
+	primary gorm.ConnPool
+	next    atomic.Uint32
+	now     func() time.Time
+
+	mu     sync.Mutex
+	writes map[uint64]time.Time
+}
+
+func NewRouter(replicas []*Replica) *Router {
+	return &Router{replicas: replicas, now: time.Now, writes: map[uint64]time.Time{}}
+}
+
+func (r *Router) Name() string {
+	return "replicas"
+}
+
+// Initialize registers the router with db. Without replicas it does
+// nothing.
+func (r *Router) Initialize(db *gorm.DB) error {
+	if len(r.replicas) == 0 {
+		return nil
+	}
+	r.primary = db.Config.ConnPool
+
+	dialectors := make([]gorm.Dialector, len(r.replicas))
+	for i, replica := range r.replicas {
+		dialectors[i] = openDialector{Dialector: db.Dialector, conn: replica.DB}
+	}
+	if err := db.Use(dbresolver.Register(dbresolver.Config{Replicas: dialectors, Policy: r})); err != nil {
+		return err
+	}
+
+	callbacks := db.Callback()
+	for _, err := range []error{
+		callbacks.Query().After("gorm:db_resolver").Before("gorm:query").Register("replicas:route", r.route),
+		callbacks.Row().After("gorm:db_resolver").Before("gorm:row").Register("replicas:route", r.route),
+		callbacks.Raw().After("gorm:db_resolver").Before("gorm:raw").Register("replicas:route", r.route),
+		callbacks.Create().After("*").Register("replicas:record_write", r.recordWrite),
+		callbacks.Update().After("*").Register("replicas:record_write", r.recordWrite),
+		callbacks.Delete().After("*").Register("replicas:record_write", r.recordWrite),
+		callbacks.Raw().After("*").Register("replicas:record_write", r.recordWrite),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Resolve picks the next healthy replica for dbresolver. When none is
+// healthy, route moves the read to the primary.
+func (r *Router) Resolve(pools []gorm.ConnPool) gorm.ConnPool {
+	healthy := make([]gorm.ConnPool, 0, len(pools))
+	for _, pool := range pools {
+		if replica := r.replicaOf(pool); replica != nil && replica.Healthy() {
+			healthy = append(healthy, pool)
+		}
+	}
+	if len(healthy) == 0 {
+		return pools[0]
+	}
+	return healthy[int(r.next.Add(1))%len(healthy)]
+}
+
+// route runs after dbresolver picked a connection and sends reads that must
+// see the primary's latest state back to it.
+func (r *Router) route(db *gorm.DB) {
+	replica := r.replicaOf(db.Statement.ConnPool)
+	if replica == nil {
+		return
+	}
+	ctx := db.Statement.Context
+	if !replica.Healthy() || wantsPrimary(ctx) || r.wroteRecently(ctx) {
+		db.Statement.ConnPool = r.primary
+	}
+}
+
+func (r *Router) recordWrite(db *gorm.DB) {
+	if db.Error != nil || r.replicaOf(db.Statement.ConnPool) != nil {
+		return
+	}
+	ctx := db.Statement.Context
+	userID, ok := userOf(ctx)
+	if !ok || r.ReadYourWrites <= 0 {
+		return
+	}
+	r.mu.Lock()
+	r.writes[userID] = r.now()
+	r.mu.Unlock()
+
+	if r.Writes != nil {
+		if err := r.Writes.Set(ctx, writeKey(userID), []byte{1}, r.ReadYourWrites); err != nil {
+			log.Printf("Failed to share the write of user %d: %v", userID, err)
+		}
+	}
+}
+
+func (r *Router) wroteRecently(ctx context.Context) bool {
+	userID, ok := userOf(ctx)
+	if !ok {
+		return false
+	}
+	r.mu.Lock()
+	wrote, ok := r.writes[userID]
+	r.mu.Unlock()
+	if ok && r.now().Sub(wrote) < r.ReadYourWrites {
+		return true
+	}
+	if r.Writes == nil {
+		return false
+	}
+	_, found, err := r.Writes.Get(ctx, writeKey(userID))
+	// Without an answer the primary is the read that cannot be stale.
+	return found || err != nil
+}
+
+func writeKey(userID uint64) string {
+	return fmt.Sprintf("replicas:writes:%d", userID)
+}
+
+func (r *Router) replicaOf(pool gorm.ConnPool) *Replica {
+	for _, replica := range r.replicas {
+		if pool == gorm.ConnPool(replica.DB) {
+			return replica
+		}
+	}
+	return nil
+}
+
+// CheckOnce checks every replica and forgets writes older than
+// ReadYourWrites.
+func (r *Router) CheckOnce(ctx context.Context) {
+	for _, replica := range r.replicas {
+		err := r.Check(ctx, replica)
+		first := !replica.checked.Swap(true)
+		wasHealthy := replica.healthy.Swap(err == nil)
+		if err != nil && (wasHealthy || first) {
+			log.Printf("Replica %s is not serving reads: %v", replica.Name, err)
+		} else if err == nil && !wasHealthy {
+			log.Printf("Replica %s is serving reads", replica.Name)
+		}
+	}
+
+	r.mu.Lock()
+	for userID, wrote := range r.writes {
+		if r.now().Sub(wrote) >= r.ReadYourWrites {
+			delete(r.writes, userID)
+		}
+	}
+	r.mu.Unlock()
+}
+
+// Replicas returns the replicas reads are routed to.
+func (r *Router) Replicas() []*Replica {
+	return r.replicas
+}
+
+// Check pings the replica and compares its lag to MaxLag.
+func (r *Router) Check(ctx context.Context, replica *Replica) error {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	if err := replica.DB.PingContext(ctx); err != nil {
+		return err
+	}
+	if replica.Lag == nil {
+		return nil
+	}
+	lag, err := replica.Lag(ctx, replica.DB)
+	if err != nil {
+		return err
+	}
+	if r.MaxLag > 0 && lag > r.MaxLag {
+		return &LagError{Lag: lag, MaxLag: r.MaxLag}
+	}
+	return nil
+}
+
+// Start checks the replicas every interval until ctx is cancelled.
+func (r *Router) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		r.CheckOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close closes the replica connection pools.
+func (r *Router) Close() error {
+	var errs []error
+	for _, replica := range r.replicas {
+		if err := replica.DB.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("replica %s: %w", replica.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LagError reports a replica too far behind the primary.
+type LagError struct {
+	Lag    time.Duration
+	MaxLag time.Duration
+}
+
+func (e *LagError) Error() string {
+	return fmt.Sprintf("replication lag %s exceeds %s", e.Lag, e.MaxLag)
+}
+
+// openDialector hands dbresolver a connection pool that is already open and
+// tuned instead of opening another one.
+type openDialector struct {
+	gorm.Dialector
+	conn *sql.DB
+}
+
+func (d openDialector) Initialize(db *gorm.DB) error {
+	db.ConnPool = d.conn
+	return nil
+}
@@ -0,0 +1,177 @@
+package replicas
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"golang/cache"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type note struct {
+	ID   uint64
+	Text string
+}
+
+// openDatabase opens a SQLite file whose only row in sources names it, so a
+// query shows which database answered.
+func openDatabase(t *testing.T, name string) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), name+".db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	t.Cleanup(func() { sqlDB.Close() })
+
+	for _, statement := range []string{
+		"CREATE TABLE sources (name text)",
+		"CREATE TABLE notes (id integer primary key, text text)",
+	} {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Exec("INSERT INTO sources VALUES (?)", name).Error; err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func source(t *testing.T, db *gorm.DB) string {
+	var names []string
+	if err := db.Table("sources").Pluck("name", &names).Error; err != nil {
+		t.Fatal(err)
+	}
+	return names[0]
+}
+
+func TestRouter(t *testing.T) {
+	db := openDatabase(t, "primary")
+	replicaDB, _ := openDatabase(t, "replica").DB()
+	var lag time.Duration
+	var lagErr error
+	replica := &Replica{Name: "test", DB: replicaDB, Lag: func(context.Context, *sql.DB) (time.Duration, error) {
+		return lag, lagErr
+	}}
+
+	router := NewRouter([]*Replica{replica})
+	router.ReadYourWrites = 5 * time.Second
+	router.MaxLag = time.Second
+	now := time.Now()
+	router.now = func() time.Time { return now }
+	if err := db.Use(router); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	t.Run("Unchecked Replica Is Not Used", func(t *testing.T) {
+		assert.False(t, replica.Healthy())
+		assert.Equal(t, "primary", source(t, db))
+	})
+
+	router.CheckOnce(ctx)
+
+	t.Run("Reads Go To The Replica", func(t *testing.T) {
+		assert.True(t, replica.Healthy())
+		assert.Equal(t, "replica", source(t, db.WithContext(ctx)))
+
+		var name string
+		assert.NoError(t, db.Raw("SELECT name FROM sources").Scan(&name).Error)
+		assert.Equal(t, "replica", name)
+	})
+
+	t.Run("Writes Go To The Primary", func(t *testing.T) {
+		assert.NoError(t, db.Create(&note{Text: "hello"}).Error)
+		assert.NoError(t, db.Exec("UPDATE notes SET text = ?", "updated").Error)
+
+		var count int64
+		assert.NoError(t, replicaDB.QueryRow("SELECT count(*) FROM notes").Scan(&count))
+		assert.Zero(t, count)
+		var text string
+		assert.NoError(t, db.WithContext(Primary(ctx)).Table("notes").Select("text").Row().Scan(&text))
+		assert.Equal(t, "updated", text)
+	})
+
+	t.Run("Transactions Read From The Primary", func(t *testing.T) {
+		assert.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+			assert.Equal(t, "primary", source(t, tx))
+			return nil
+		}))
+	})
+
+	t.Run("Read Your Writes", func(t *testing.T) {
+		alice := WithUser(ctx, 1)
+		bob := WithUser(ctx, 2)
+		assert.Equal(t, "replica", source(t, db.WithContext(alice)))
+
+		assert.NoError(t, db.WithContext(alice).Create(&note{Text: "mine"}).Error)
+		assert.Equal(t, "primary", source(t, db.WithContext(alice)))
+		assert.Equal(t, "replica", source(t, db.WithContext(bob)))
+
+		now = now.Add(5 * time.Second)
+		assert.Equal(t, "replica", source(t, db.WithContext(alice)))
+
+		router.CheckOnce(ctx)
+		assert.Empty(t, router.writes)
+	})
+
+	t.Run("Failed Writes Are Not Recorded", func(t *testing.T) {
+		alice := WithUser(ctx, 1)
+		assert.Error(t, db.WithContext(alice).Exec("INSERT INTO missing VALUES (1)").Error)
+		assert.Equal(t, "replica", source(t, db.WithContext(alice)))
+	})
+
+	t.Run("Lagging Replica Falls Back To The Primary", func(t *testing.T) {
+		lag = 2 * time.Second
+		router.CheckOnce(ctx)
+		assert.False(t, replica.Healthy())
+		assert.Equal(t, "primary", source(t, db))
+
+		lag = 0
+		router.CheckOnce(ctx)
+		assert.Equal(t, "replica", source(t, db))
+
+		lagErr = errors.New("replication is not running")
+		router.CheckOnce(ctx)
+		assert.Equal(t, "primary", source(t, db))
+	})
+}
+
+func TestRouter_SharedWrites(t *testing.T) {
+	writes := cache.NewLRU(10)
+	instance := func(name string) *gorm.DB {
+		db := openDatabase(t, name)
+		replicaDB, _ := openDatabase(t, name+"_replica").DB()
+		router := NewRouter([]*Replica{{Name: name, DB: replicaDB}})
+		router.ReadYourWrites = 5 * time.Second
+		router.Writes = writes
+		if err := db.Use(router); err != nil {
+			t.Fatal(err)
+		}
+		router.CheckOnce(context.Background())
+		return db
+	}
+	first, second := instance("first"), instance("second")
+	alice := WithUser(context.Background(), 1)
+
+	assert.Equal(t, "second_replica", source(t, second.WithContext(alice)))
+	assert.NoError(t, first.WithContext(alice).Create(&note{Text: "mine"}).Error)
+	assert.Equal(t, "second", source(t, second.WithContext(alice)))
+	assert.Equal(t, "second_replica", source(t, second.WithContext(WithUser(context.Background(), 2))))
+}
+
+func TestRouter_WithoutReplicas(t *testing.T) {
+	db := openDatabase(t, "primary")
+	router := NewRouter(nil)
+	assert.NoError(t, db.Use(router))
+	router.CheckOnce(context.Background())
+
+	assert.NoError(t, db.Create(&note{Text: "hello"}).Error)
+	assert.Equal(t, "primary", source(t, db))
+}
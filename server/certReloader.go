@@ -0,0 +1,82 @@
+package server
+
+import (
+	"crypto/tls"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertReloader serves a certificate from files and loads it again once the
+// files change, e.g. after a renewal. A change that does not load, like a
+// certificate written before its key, keeps the previous certificate until
+// the pair is complete.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	// checkEvery limits how often the files are looked at.
+	checkEvery time.Duration
+	now        func() time.Time
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	loaded  [2]time.Time
+	checked time.Time
+}
+
+// NewCertReloader loads the certificate, which must be valid.
+func NewCertReloader(certFile string, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile, checkEvery: 10 * time.Second, now: time.Now}
+	modified, err := r.modified()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(modified); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate is meant for tls.Config.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if now := r.now(); now.Sub(r.checked) >= r.checkEvery {
+		r.checked = now
+		modified, err := r.modified()
+		if err == nil && modified != r.loaded {
+			err = r.load(modified)
+			if err == nil {
+				log.Printf("Reloaded TLS certificate %s", r.certFile)
+			}
+		}
+		if err != nil {
+			log.Printf("Keeping the current TLS certificate: %v", err)
+		}
+	}
+	return r.cert, nil
+}
+
+func (r *CertReloader) load(modified [2]time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert = &cert
+	r.loaded = modified
+	return nil
+}
+
+func (r *CertReloader) modified() ([2]time.Time, error) {
+	var modified [2]time.Time
+	for i, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return modified, err
+		}
+		modified[i] = info.ModTime()
+	}
+	return modified, nil
+}
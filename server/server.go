@@ -0,0 +1,50 @@
+// Package server runs the HTTP server with production settings.
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"golang/config"
+	"net/http"
+)
+
+// New returns a server for handler with the address, timeouts and header
+// limit of cfg. With a certificate configured it serves HTTPS, reloading the
+// certificate files when they change.
+func New(cfg config.ServerConfig, handler http.Handler) (*http.Server, error) {
+	srv := &http.Server{
+		Addr:              cfg.Addr(),
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+	if cfg.TLSEnabled() {
+		certificates, err := NewCertReloader(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		srv.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certificates.GetCertificate,
+		}
+	}
+	return srv, nil
+}
+
+// ListenAndServe serves HTTPS when srv has a TLS config and plain HTTP
+// otherwise, until the server is shut down.
+func ListenAndServe(srv *http.Server) error {
+	var err error
+	if srv.TLSConfig != nil {
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
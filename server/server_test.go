@@ -0,0 +1,151 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"golang/config"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeCertificate writes a self-signed certificate with the serial number
+// and its key, dated modified.
+func writeCertificate(t *testing.T, certFile string, keyFile string, serial int64, modified time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for file, block := range map[string]*pem.Block{
+		certFile: {Type: "CERTIFICATE", Bytes: der},
+		keyFile:  {Type: "EC PRIVATE KEY", Bytes: keyDER},
+	} {
+		if err := os.WriteFile(file, pem.EncodeToMemory(block), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Run("Settings", func(t *testing.T) {
+		srv, err := New(config.ServerConfig{
+			Address:           "127.0.0.1:9000",
+			ReadTimeout:       time.Second,
+			ReadHeaderTimeout: 2 * time.Second,
+			WriteTimeout:      3 * time.Second,
+			IdleTimeout:       4 * time.Second,
+			MaxHeaderBytes:    1024,
+		}, http.NotFoundHandler())
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "127.0.0.1:9000", srv.Addr)
+		assert.Equal(t, time.Second, srv.ReadTimeout)
+		assert.Equal(t, 2*time.Second, srv.ReadHeaderTimeout)
+		assert.Equal(t, 3*time.Second, srv.WriteTimeout)
+		assert.Equal(t, 4*time.Second, srv.IdleTimeout)
+		assert.Equal(t, 1024, srv.MaxHeaderBytes)
+		assert.Nil(t, srv.TLSConfig)
+	})
+
+	t.Run("Missing Certificate", func(t *testing.T) {
+		dir := t.TempDir()
+		_, err := New(config.ServerConfig{
+			TLSCertFile: filepath.Join(dir, "cert.pem"),
+			TLSKeyFile:  filepath.Join(dir, "key.pem"),
+		}, http.NotFoundHandler())
+		assert.Error(t, err)
+	})
+
+	t.Run("TLS", func(t *testing.T) {
+		dir := t.TempDir()
+		certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+		writeCertificate(t, certFile, keyFile, 1, time.Now())
+
+		srv, err := New(config.ServerConfig{TLSCertFile: certFile, TLSKeyFile: keyFile}, http.NotFoundHandler())
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, int64(1), serveTLS(t, srv.TLSConfig)())
+	})
+}
+
+// serveTLS starts a TLS server with config and returns a function that
+// connects to it and returns the serial number of the certificate served.
+func serveTLS(t *testing.T, config *tls.Config) func() int64 {
+	ts := httptest.NewUnstartedServer(http.NotFoundHandler())
+	ts.TLS = config
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+
+	return func() int64 {
+		conn, err := tls.Dial("tcp", ts.Listener.Addr().String(), &tls.Config{ServerName: "localhost", InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	modified := time.Now().Add(-time.Hour)
+	writeCertificate(t, certFile, keyFile, 1, modified)
+
+	reloader, err := NewCertReloader(certFile, keyFile)
+	if !assert.NoError(t, err) {
+		return
+	}
+	now := time.Now()
+	reloader.now = func() time.Time { return now }
+	serial := serveTLS(t, &tls.Config{GetCertificate: reloader.GetCertificate})
+	assert.Equal(t, int64(1), serial())
+
+	// The files are looked at once every checkEvery.
+	writeCertificate(t, certFile, keyFile, 2, modified.Add(time.Minute))
+	assert.Equal(t, int64(1), serial())
+	now = now.Add(reloader.checkEvery)
+	assert.Equal(t, int64(2), serial())
+
+	// A half-written renewal keeps the current certificate.
+	writeCertificate(t, certFile, keyFile, 3, modified.Add(2*time.Minute))
+	os.WriteFile(keyFile, []byte("not a key yet"), 0o600)
+	now = now.Add(reloader.checkEvery)
+	assert.Equal(t, int64(2), serial())
+
+	writeCertificate(t, certFile, keyFile, 4, modified.Add(3*time.Minute))
+	now = now.Add(reloader.checkEvery)
+	assert.Equal(t, int64(4), serial())
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"golang/dao"
+	"golang/models"
+	"log"
+	"time"
+)
+
+// Notifier tells a user about something that needs their attention.
+type Notifier interface {
+	NotifyCardExpiring(card models.CreditCard) error
+}
+
+// LogNotifier only logs notifications; it stands in until a real delivery
+// channel is configured.
+type LogNotifier struct{}
+
+func (LogNotifier) NotifyCardExpiring(card models.CreditCard) error {
+	log.Printf("Card %s ending in %s of user %d expires %02d/%d",
+		card.Brand, card.Last4, card.UserID, card.ExpMonth, card.ExpYear)
+	return nil
+}
+
+// CardExpiryReminder notifies users once about cards that expire this month
+// or next month.
+type CardExpiryReminder struct {
+	cardDao  dao.ICardDao
+	notifier Notifier
+	now      func() time.Time
+}
+
+func NewCardExpiryReminder(cardDao dao.ICardDao, notifier Notifier) *CardExpiryReminder {
+	return &CardExpiryReminder{cardDao: cardDao, notifier: notifier, now: time.Now}
+}
+
+// RunOnce sends the reminders that are due and returns how many were sent.
+func (r *CardExpiryReminder) RunOnce(ctx context.Context) (int, error) {
+	now := r.now()
+	next := time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, now.Location())
+
+	cards, err := r.cardDao.FindExpiring(ctx, now.Year(), int(now.Month()), next.Year(), int(next.Month()))
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, card := range cards {
+		if err := r.notifier.NotifyCardExpiring(card); err != nil {
+			log.Printf("Failed to send expiry reminder for card %d: %v", card.ID, err)
+			continue
+		}
+		if err := r.cardDao.MarkReminderSent(ctx, uint64(card.ID), now); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// Start runs the reminder every interval until ctx is cancelled.
+func (r *CardExpiryReminder) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.RunOnce(ctx); err != nil {
+			log.Println("Card expiry reminder failed:", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"golang/dao"
+	"golang/models"
+	"golang/vault"
+)
+
+type ICardService interface {
+	List(ctx context.Context, userID uint64) ([]models.CreditCard, error)
+	Add(ctx context.Context, userID uint64, card *models.CreditCard) error
+	SetDefault(ctx context.Context, userID uint64, cardID uint64) error
+	Remove(ctx context.Context, userID uint64, cardID uint64) error
+}
+
+type CardService struct {
+	cardDao   dao.ICardDao
+	cardVault vault.IVault
+	// Users is invalidated when a user's cards change, since cached users carry them.
+	Users *UserCache
+}
+
+func NewCardService(cardDao dao.ICardDao, cardVault vault.IVault) *CardService {
+	return &CardService{cardDao: cardDao, cardVault: cardVault}
+}
+
+func (s *CardService) List(ctx context.Context, userID uint64) ([]models.CreditCard, error) {
+	return s.cardDao.ListByUser(ctx, userID)
+}
+
+func (s *CardService) Add(ctx context.Context, userID uint64, card *models.CreditCard) error {
+	if card.Number == "" {
+		return fmt.Errorf("%w: number is required", vault.ErrInvalidCard)
+	}
+	if err := tokenizeCard(ctx, s.cardVault, card); err != nil {
+		return err
+	}
+	card.UserID = userID
+	return s.Users.invalidated(userID, s.cardDao.Create(ctx, card))
+}
+
+func (s *CardService) SetDefault(ctx context.Context, userID uint64, cardID uint64) error {
+	return s.Users.invalidated(userID, s.cardDao.SetDefault(ctx, userID, cardID))
+}
+
+func (s *CardService) Remove(ctx context.Context, userID uint64, cardID uint64) error {
+	return s.Users.invalidated(userID, s.cardDao.Delete(ctx, userID, cardID))
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"golang/models"
+	"golang/vault"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Mocking the ICardDao interface
+type MockCardDao struct {
+	mock.Mock
+}
+
+func (m *MockCardDao) ListByUser(ctx context.Context, userID uint64) ([]models.CreditCard, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]models.CreditCard), args.Error(1)
+}
+
+func (m *MockCardDao) Get(ctx context.Context, userID uint64, cardID uint64) (*models.CreditCard, error) {
+	args := m.Called(userID, cardID)
+	return args.Get(0).(*models.CreditCard), args.Error(1)
+}
+
+func (m *MockCardDao) Create(ctx context.Context, card *models.CreditCard) error {
+	args := m.Called(card)
+	return args.Error(0)
+}
+
+func (m *MockCardDao) SetDefault(ctx context.Context, userID uint64, cardID uint64) error {
+	args := m.Called(userID, cardID)
+	return args.Error(0)
+}
+
+func (m *MockCardDao) Delete(ctx context.Context, userID uint64, cardID uint64) error {
+	args := m.Called(userID, cardID)
+	return args.Error(0)
+}
+
+func (m *MockCardDao) FindExpiring(ctx context.Context, fromYear int, fromMonth int, toYear int, toMonth int) ([]models.CreditCard, error) {
+	args := m.Called(fromYear, fromMonth, toYear, toMonth)
+	return args.Get(0).([]models.CreditCard), args.Error(1)
+}
+
+func (m *MockCardDao) MarkReminderSent(ctx context.Context, cardID uint64, sentAt time.Time) error {
+	args := m.Called(cardID, sentAt)
+	return args.Error(0)
+}
+
+type MockNotifier struct {
+	mock.Mock
+}
+
+func (m *MockNotifier) NotifyCardExpiring(card models.CreditCard) error {
+	args := m.Called(card)
+	return args.Error(0)
+}
+
+func TestCardService_Add(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		mockDao := new(MockCardDao)
+		mockVault := new(MockVault)
+		cardService := NewCardService(mockDao, mockVault)
+
+		card := &models.CreditCard{Number: "378282246310005", ExpMonth: 1, ExpYear: time.Now().Year() + 2, HolderName: "John"}
+		mockVault.On("Tokenize", "378282246310005").Return("tok_amex", nil)
+		mockDao.On("Create", card).Return(nil)
+
+		err := cardService.Add(context.Background(), 7, card)
+
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(7), card.UserID)
+		assert.Equal(t, "amex", card.Brand)
+		assert.Equal(t, "tok_amex", card.Token)
+		assert.Empty(t, card.Number)
+		mockDao.AssertExpectations(t)
+	})
+
+	t.Run("Missing Number", func(t *testing.T) {
+		mockDao := new(MockCardDao)
+		cardService := NewCardService(mockDao, new(MockVault))
+
+		err := cardService.Add(context.Background(), 7, &models.CreditCard{Token: "tok_forged"})
+
+		assert.ErrorIs(t, err, vault.ErrInvalidCard)
+		mockDao.AssertNotCalled(t, "Create", mock.Anything)
+	})
+}
+
+func TestCardExpiryReminder_RunOnce(t *testing.T) {
+	mockDao := new(MockCardDao)
+	mockNotifier := new(MockNotifier)
+	reminder := NewCardExpiryReminder(mockDao, mockNotifier)
+	now := time.Date(2026, time.December, 10, 0, 0, 0, 0, time.UTC)
+	reminder.now = func() time.Time { return now }
+
+	card := models.CreditCard{UserID: 1, ExpMonth: 1, ExpYear: 2027}
+	card.ID = 3
+	mockDao.On("FindExpiring", 2026, 12, 2027, 1).Return([]models.CreditCard{card}, nil)
+	mockNotifier.On("NotifyCardExpiring", card).Return(nil)
+	mockDao.On("MarkReminderSent", uint64(3), now).Return(nil)
+
+	sent, err := reminder.RunOnce(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	mockDao.AssertExpectations(t)
+	mockNotifier.AssertExpectations(t)
+}
@@ -0,0 +1,32 @@
+package services
+
+import (
+	"context"
+	"golang/dao"
+	"golang/models"
+	"golang/pagination"
+)
+
+type IExportService interface {
+	StreamUsers(ctx context.Context, page pagination.Request, search dao.Search, fn func([]models.User) error) error
+	StreamNotes(ctx context.Context, page pagination.Request, fn func([]models.Note) error) error
+}
+
+// ExportService reads whole tables for exports. Unlike the list endpoints it
+// is not bounded by pagination.MaxLimit; rows arrive in batches instead.
+type ExportService struct {
+	userDao dao.IUserDao
+	noteDao dao.INoteDao
+}
+
+func NewExportService(userDao dao.IUserDao, noteDao dao.INoteDao) *ExportService {
+	return &ExportService{userDao: userDao, noteDao: noteDao}
+}
+
+func (e *ExportService) StreamUsers(ctx context.Context, page pagination.Request, search dao.Search, fn func([]models.User) error) error {
+	return e.userDao.Stream(ctx, page, search, fn)
+}
+
+func (e *ExportService) StreamNotes(ctx context.Context, page pagination.Request, fn func([]models.Note) error) error {
+	return e.noteDao.Stream(ctx, page, fn)
+}
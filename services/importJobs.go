@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type JobStatus string
+
+const (
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+)
+
+// ImportJob is the progress of an import running in the background.
+type ImportJob struct {
+	ID         string        `json:"id"`
+	Status     JobStatus     `json:"status"`
+	Total      int           `json:"total"`
+	Processed  int           `json:"processed"`
+	Report     *ImportReport `json:"report,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	CreatedAt  time.Time     `json:"created_at"`
+	FinishedAt *time.Time    `json:"finished_at,omitempty"`
+}
+
+// ImportJobs runs imports in the background and keeps their progress in
+// memory, so it is lost on restart. Finished jobs are forgotten after the
+// retention period.
+type ImportJobs struct {
+	importService IUserImportService
+	retention     time.Duration
+	now           func() time.Time
+
+	mu      sync.Mutex
+	jobs    map[string]*ImportJob
+	running sync.WaitGroup
+}
+
+func NewImportJobs(importService IUserImportService) *ImportJobs {
+	return &ImportJobs{
+		importService: importService,
+		retention:     24 * time.Hour,
+		now:           time.Now,
+		jobs:          map[string]*ImportJob{},
+	}
+}
+
+// Start imports rows in the background and returns the new job.
+func (j *ImportJobs) Start(rows []ImportRow) (ImportJob, error) {
+	id, err := newJobID()
+	if err != nil {
+		return ImportJob{}, err
+	}
+
+	j.mu.Lock()
+	j.prune()
+	job := &ImportJob{ID: id, Status: JobRunning, Total: len(rows), CreatedAt: j.now()}
+	j.jobs[id] = job
+	snapshot := *job
+	j.mu.Unlock()
+
+	// The job outlives the request that started it, so it does not run under
+	// the request's context.
+	j.running.Add(1)
+	go func() {
+		defer j.running.Done()
+		report, err := j.importService.Run(context.Background(), rows, false, func(processed int) {
+			j.mu.Lock()
+			job.Processed = processed
+			j.mu.Unlock()
+		})
+
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		finished := j.now()
+		job.FinishedAt = &finished
+		job.Report = report
+		job.Status = JobSucceeded
+		if err != nil {
+			job.Status = JobFailed
+			job.Error = err.Error()
+		}
+	}()
+	return snapshot, nil
+}
+
+// Get returns a copy of the job's current state.
+func (j *ImportJobs) Get(id string) (ImportJob, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	job, ok := j.jobs[id]
+	if !ok {
+		return ImportJob{}, false
+	}
+	return *job, true
+}
+
+// Wait blocks until every started job has finished.
+func (j *ImportJobs) Wait() {
+	j.running.Wait()
+}
+
+// Drain waits like Wait, but gives up when ctx ends and reports how many
+// jobs were still running.
+func (j *ImportJobs) Drain(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		j.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	running := 0
+	for _, job := range j.jobs {
+		if job.Status == JobRunning {
+			running++
+		}
+	}
+	return fmt.Errorf("%d import jobs still running: %w", running, ctx.Err())
+}
+
+func (j *ImportJobs) prune() {
+	cutoff := j.now().Add(-j.retention)
+	for id, job := range j.jobs {
+		if job.FinishedAt != nil && job.FinishedAt.Before(cutoff) {
+			delete(j.jobs, id)
+		}
+	}
+}
+
+func newJobID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
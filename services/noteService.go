@@ -0,0 +1,48 @@
+package services
+
+import (
+	"context"
+	"golang/dao"
+	"golang/models"
+	"golang/pagination"
+)
+
+type INoteService interface {
+	List(ctx context.Context, userID uint64, page pagination.Request) (*pagination.Page[models.Note], error)
+	Get(ctx context.Context, userID uint64, noteID uint64) (*models.Note, error)
+	Create(ctx context.Context, userID uint64, note *models.Note) error
+	Update(ctx context.Context, userID uint64, note *models.Note) error
+	Delete(ctx context.Context, userID uint64, noteID uint64, version uint64) error
+}
+
+type NoteService struct {
+	noteDao dao.INoteDao
+	// Users is invalidated when a user's notes change, since cached users carry them.
+	Users *UserCache
+}
+
+func NewNoteService(noteDao dao.INoteDao) *NoteService {
+	return &NoteService{noteDao: noteDao}
+}
+
+func (s *NoteService) List(ctx context.Context, userID uint64, page pagination.Request) (*pagination.Page[models.Note], error) {
+	return s.noteDao.ListByUser(ctx, userID, page.Normalize())
+}
+
+func (s *NoteService) Get(ctx context.Context, userID uint64, noteID uint64) (*models.Note, error) {
+	return s.noteDao.Get(ctx, userID, noteID)
+}
+
+func (s *NoteService) Create(ctx context.Context, userID uint64, note *models.Note) error {
+	note.UserID = userID
+	return s.Users.invalidated(userID, s.noteDao.Create(ctx, note))
+}
+
+func (s *NoteService) Update(ctx context.Context, userID uint64, note *models.Note) error {
+	note.UserID = userID
+	return s.Users.invalidated(userID, s.noteDao.Update(ctx, note))
+}
+
+func (s *NoteService) Delete(ctx context.Context, userID uint64, noteID uint64, version uint64) error {
+	return s.Users.invalidated(userID, s.noteDao.Delete(ctx, userID, noteID, version))
+}
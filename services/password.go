@@ -0,0 +1,42 @@
+package services
+
+import (
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrPasswordTooLong rejects passwords bcrypt would silently truncate.
+var ErrPasswordTooLong = errors.New("password is longer than 72 bytes")
+
+// hashPassword returns the bcrypt hash of password. An empty password stays
+// empty, so the user cannot log in with a password.
+func hashPassword(password string, cost int) (string, error) {
+	if password == "" {
+		return "", nil
+	}
+	if len(password) > 72 {
+		return "", ErrPasswordTooLong
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	return string(hash), err
+}
+
+// CheckPassword reports whether password matches the stored hash. Users
+// without a password never match.
+func CheckPassword(hash string, password string) bool {
+	return hash != "" && bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// HashStoredPassword hashes a password stored in plaintext before passwords
+// were hashed. It reports false for passwords that are already hashed.
+func HashStoredPassword(stored string) (string, bool, error) {
+	if stored == "" {
+		return "", false, nil
+	}
+	if _, err := bcrypt.Cost([]byte(stored)); err == nil {
+		return stored, false, nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(stored), bcrypt.DefaultCost)
+	return string(hash), err == nil, err
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"context"
+	"golang/dao"
+)
+
+type IPrivacyService interface {
+	Export(ctx context.Context, userID uint64) (*dao.SubjectData, error)
+	Erase(ctx context.Context, userID uint64, mode dao.EraseMode) error
+}
+
+// PrivacyService answers data subject requests: a copy of everything stored
+// about a user, and erasure of it.
+type PrivacyService struct {
+	privacyDao dao.IPrivacyDao
+	// Users is invalidated when a user is erased.
+	Users *UserCache
+}
+
+func NewPrivacyService(privacyDao dao.IPrivacyDao) *PrivacyService {
+	return &PrivacyService{privacyDao: privacyDao}
+}
+
+func (p *PrivacyService) Export(ctx context.Context, userID uint64) (*dao.SubjectData, error) {
+	return p.privacyDao.Collect(ctx, userID)
+}
+
+// Erase defaults to anonymizing when no mode is given.
+func (p *PrivacyService) Erase(ctx context.Context, userID uint64, mode dao.EraseMode) error {
+	if mode == "" {
+		mode = dao.EraseAnonymize
+	}
+	return p.Users.invalidated(userID, p.privacyDao.Erase(ctx, userID, mode))
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"golang/dao"
+	"golang/vault"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Tx holds DAOs bound to one transaction. Whatever they write is committed
+// or rolled back together.
+type Tx struct {
+	Users   dao.IUserDao
+	Notes   dao.INoteDao
+	Cards   dao.ICardDao
+	Charges dao.IChargeDao
+	Privacy dao.IPrivacyDao
+	Vault   vault.IVault
+}
+
+type ITransactionManager interface {
+	// Run calls fn inside a transaction that is committed when fn returns
+	// nil and rolled back otherwise. The ctx passed to fn carries the
+	// transaction; a Run under it becomes a savepoint, so a failing inner
+	// call only undoes its own writes.
+	Run(ctx context.Context, fn func(ctx context.Context, tx Tx) error) error
+}
+
+type txKey struct{}
+
+// TransactionManager runs units of work on the database. A transaction that
+// fails because of a concurrent one is run again from the start, so fn must
+// not keep state from a previous attempt.
+type TransactionManager struct {
+	db *gorm.DB
+	// MaxAttempts is how often a transaction is tried in total.
+	MaxAttempts int
+	// RetryDelay is the wait before the first retry; it doubles with every
+	// further one.
+	RetryDelay time.Duration
+}
+
+func NewTransactionManager(db *gorm.DB) *TransactionManager {
+	return &TransactionManager{db: db, MaxAttempts: 3, RetryDelay: 20 * time.Millisecond}
+}
+
+func (m *TransactionManager) Run(ctx context.Context, fn func(ctx context.Context, tx Tx) error) error {
+	if outer, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		// Only the outermost transaction is retried; a serialization failure
+		// aborts all of it.
+		return outer.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return m.call(ctx, tx, fn)
+		})
+	}
+
+	delay := m.RetryDelay
+	for attempt := 1; ; attempt++ {
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return m.call(ctx, tx, fn)
+		})
+		if err == nil || attempt >= m.MaxAttempts || !dao.IsSerializationFailure(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func (m *TransactionManager) call(ctx context.Context, tx *gorm.DB, fn func(ctx context.Context, tx Tx) error) error {
+	return fn(context.WithValue(ctx, txKey{}, tx), Tx{
+		Users:   dao.NewUserDao(tx),
+		Notes:   dao.NewNoteDao(tx),
+		Cards:   dao.NewCardDao(tx),
+		Charges: dao.NewChargeDao(tx),
+		Privacy: dao.NewPrivacyDao(tx),
+		Vault:   vault.NewVault(dao.NewVaultDao(tx)),
+	})
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"golang/dao"
+	"golang/encryption"
+	"golang/migrations"
+	"golang/models"
+	"golang/vault"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupTransactionDB opens an in-memory SQLite database the same way the
+// DAO tests do.
+func setupTransactionDB(t *testing.T) *gorm.DB {
+	key := make([]byte, encryption.MasterKeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	provider, err := encryption.NewLocalKeyProvider("test", map[string][]byte{"test": key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryption.SetKeyProvider(provider)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to in-memory database: %v", err)
+	}
+	migrator, err := migrations.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("Failed to migrate database schema: %v", err)
+	}
+	t.Cleanup(func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	})
+	return db
+}
+
+func countRows(t *testing.T, db *gorm.DB, model interface{}) int64 {
+	var count int64
+	if err := db.Model(model).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestTransactionManager_Run(t *testing.T) {
+	db := setupTransactionDB(t)
+	manager := NewTransactionManager(db)
+	manager.RetryDelay = 0
+	ctx := context.Background()
+
+	t.Run("Commits Every DAO", func(t *testing.T) {
+		err := manager.Run(ctx, func(ctx context.Context, tx Tx) error {
+			user := &models.User{Username: "alice", Password: "secret", Role: models.RoleUser}
+			if err := tx.Users.Create(ctx, user); err != nil {
+				return err
+			}
+			if err := tx.Notes.Create(ctx, &models.Note{UserID: user.ID, Name: "first", Content: "hello"}); err != nil {
+				return err
+			}
+			token, err := tx.Vault.Tokenize(ctx, "4111111111111111")
+			if err != nil {
+				return err
+			}
+			return tx.Cards.Create(ctx, &models.CreditCard{UserID: user.ID, Token: token, Last4: "1111", ExpMonth: 12, ExpYear: 2099})
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), countRows(t, db, &models.User{}))
+		assert.Equal(t, int64(1), countRows(t, db, &models.Note{}))
+		assert.Equal(t, int64(1), countRows(t, db, &models.CreditCard{}))
+		assert.Equal(t, int64(1), countRows(t, db, &models.VaultEntry{}))
+	})
+
+	t.Run("Rolls Back On Error", func(t *testing.T) {
+		failure := errors.New("card declined")
+		err := manager.Run(ctx, func(ctx context.Context, tx Tx) error {
+			user := &models.User{Username: "bob", Password: "secret", Role: models.RoleUser}
+			if err := tx.Users.Create(ctx, user); err != nil {
+				return err
+			}
+			if _, err := tx.Vault.Tokenize(ctx, "5555555555554444"); err != nil {
+				return err
+			}
+			return failure
+		})
+
+		assert.ErrorIs(t, err, failure)
+		assert.Equal(t, int64(1), countRows(t, db, &models.User{}))
+		assert.Equal(t, int64(1), countRows(t, db, &models.VaultEntry{}))
+	})
+
+	t.Run("Nested Run Is A Savepoint", func(t *testing.T) {
+		err := manager.Run(ctx, func(ctx context.Context, tx Tx) error {
+			if err := tx.Users.Create(ctx, &models.User{Username: "carol", Role: models.RoleUser}); err != nil {
+				return err
+			}
+			inner := manager.Run(ctx, func(ctx context.Context, tx Tx) error {
+				if err := tx.Users.Create(ctx, &models.User{Username: "dave", Role: models.RoleUser}); err != nil {
+					return err
+				}
+				return errors.New("inner failure")
+			})
+			assert.EqualError(t, inner, "inner failure")
+			return nil
+		})
+
+		assert.NoError(t, err)
+		taken, err := dao.NewUserDao(db).UsernamesTaken(ctx, []string{"carol", "dave"})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]bool{"carol": true}, taken)
+	})
+
+	t.Run("Retries Serialization Failures", func(t *testing.T) {
+		attempts := 0
+		err := manager.Run(ctx, func(ctx context.Context, tx Tx) error {
+			attempts++
+			if err := tx.Users.Create(ctx, &models.User{Username: fmt.Sprintf("erin-%d", attempts), Role: models.RoleUser}); err != nil {
+				return err
+			}
+			if attempts < 3 {
+				return sqlite3.Error{Code: sqlite3.ErrBusy}
+			}
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, attempts)
+		taken, err := dao.NewUserDao(db).UsernamesTaken(ctx, []string{"erin-1", "erin-2", "erin-3"})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]bool{"erin-3": true}, taken)
+	})
+
+	t.Run("Gives Up After MaxAttempts", func(t *testing.T) {
+		attempts := 0
+		err := manager.Run(ctx, func(ctx context.Context, tx Tx) error {
+			attempts++
+			return sqlite3.Error{Code: sqlite3.ErrBusy}
+		})
+
+		assert.True(t, dao.IsSerializationFailure(err))
+		assert.Equal(t, manager.MaxAttempts, attempts)
+	})
+
+	t.Run("Does Not Retry Other Errors", func(t *testing.T) {
+		attempts := 0
+		err := manager.Run(ctx, func(ctx context.Context, tx Tx) error {
+			attempts++
+			return errors.New("boom")
+		})
+
+		assert.EqualError(t, err, "boom")
+		assert.Equal(t, 1, attempts)
+	})
+}
+
+func TestUserService_CreateInTransaction(t *testing.T) {
+	db := setupTransactionDB(t)
+	cardVault := vault.NewVault(dao.NewVaultDao(db))
+	service := NewUserService(dao.NewUserDao(db), cardVault)
+	service.Transactions = NewTransactionManager(db)
+	ctx := context.Background()
+
+	newUser := func() *models.User {
+		return &models.User{
+			Username:    "frank",
+			Role:        models.RoleUser,
+			Notes:       []models.Note{{Name: "first", Content: "hello"}},
+			CreditCards: []models.CreditCard{{Number: "4111 1111 1111 1111", ExpMonth: 12, ExpYear: 2099}},
+		}
+	}
+
+	user := newUser()
+	assert.NoError(t, service.Create(ctx, user))
+	assert.NotZero(t, user.ID)
+	assert.NotEmpty(t, user.CreditCards[0].Token)
+	assert.Empty(t, user.CreditCards[0].Number)
+
+	// The duplicate username fails the insert after the card was vaulted;
+	// the vault entry goes with it.
+	duplicate := newUser()
+	assert.ErrorIs(t, service.Create(ctx, duplicate), dao.ErrUsernameTaken)
+	assert.Zero(t, duplicate.ID)
+	assert.Equal(t, "4111 1111 1111 1111", duplicate.CreditCards[0].Number)
+	assert.Equal(t, int64(1), countRows(t, db, &models.VaultEntry{}))
+	assert.Equal(t, int64(1), countRows(t, db, &models.Note{}))
+}
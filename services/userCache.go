@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"golang/cache"
+	"golang/models"
+	"golang/replicas"
+	"log"
+	"time"
+)
+
+// invalidateTimeout bounds dropping cached users after a write. It does not
+// use the request's context, which may end right after the write.
+const invalidateTimeout = 2 * time.Second
+
+// UserCache caches users by ID: with their notes and cards for GetByID, and
+// without them for authentication. Every service that changes a user, their
+// notes or their cards invalidates it. A nil *UserCache caches nothing.
+type UserCache struct {
+	loader *cache.Loader[models.User]
+}
+
+func NewUserCache(store cache.Cache, ttl time.Duration) *UserCache {
+	return &UserCache{loader: cache.NewLoader[models.User](store, ttl)}
+}
+
+func (c *UserCache) get(ctx context.Context, key string, load func(ctx context.Context) (*models.User, error)) (*models.User, error) {
+	if c == nil {
+		return load(ctx)
+	}
+	return c.loader.Get(ctx, key, func(ctx context.Context) (*models.User, error) {
+		// A lagging replica would keep its stale copy cached for the
+		// whole ttl.
+		user, err := load(replicas.Primary(ctx))
+		if err != nil {
+			return nil, err
+		}
+		// Nothing reads the hash from these lookups, so it is not copied
+		// to a shared cache.
+		user.Password = ""
+		return user, nil
+	})
+}
+
+// Invalidate drops the cached copies of the user. Failures are only logged:
+// the write before it has happened, and the entries expire on their own.
+func (c *UserCache) Invalidate(userID uint64) {
+	if c == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), invalidateTimeout)
+	defer cancel()
+	if err := c.loader.Invalidate(ctx, userKey(userID), accountKey(userID)); err != nil {
+		log.Printf("Failed to drop cached user %d: %v", userID, err)
+	}
+}
+
+// invalidated invalidates the user when err, from a write, is nil, and
+// returns err.
+func (c *UserCache) invalidated(userID uint64, err error) error {
+	if err == nil {
+		c.Invalidate(userID)
+	}
+	return err
+}
+
+func (c *UserCache) Stats() cache.Stats {
+	if c == nil {
+		return cache.Stats{}
+	}
+	return c.loader.Stats()
+}
+
+func userKey(userID uint64) string {
+	return fmt.Sprintf("users:%d", userID)
+}
+
+func accountKey(userID uint64) string {
+	return fmt.Sprintf("users:%d:account", userID)
+}
@@ -0,0 +1,336 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang/dao"
+	"golang/models"
+	"io"
+	"log"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type ImportFormat string
+
+const (
+	ImportCSV        ImportFormat = "csv"
+	ImportJSONLines  ImportFormat = "jsonl"
+	MaxImportRows                 = 100000
+	DefaultBatchSize              = 500
+)
+
+var (
+	ErrUnsupportedImportFormat = errors.New("unsupported import format")
+	ErrTooManyImportRows       = fmt.Errorf("an import may contain at most %d rows", MaxImportRows)
+)
+
+// Inviter asks a newly created user to choose a password.
+type Inviter interface {
+	NotifyInvited(user models.User) error
+}
+
+func (LogNotifier) NotifyInvited(user models.User) error {
+	log.Printf("Invitation sent to %s (user %d)", user.Username, user.ID)
+	return nil
+}
+
+// ImportRow is one user of an import file. Line is the line the row starts
+// on, counting the CSV header.
+type ImportRow struct {
+	Line     int    `json:"-"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+
+	// invalid is set when the line could not be decoded at all.
+	invalid string
+}
+
+type RowError struct {
+	Line     int    `json:"line"`
+	Username string `json:"username,omitempty"`
+	Error    string `json:"error"`
+}
+
+type ImportReport struct {
+	DryRun  bool       `json:"dry_run"`
+	Rows    int        `json:"rows"`
+	Created int        `json:"created"`
+	Updated int        `json:"updated"`
+	Invited int        `json:"invited"`
+	Errors  []RowError `json:"errors,omitempty"`
+}
+
+// ParseImport reads every row of an import file. Rows that cannot be decoded
+// are kept so Validate can report them with their line; only a file that
+// cannot be read at all is an error.
+func ParseImport(format ImportFormat, r io.Reader) ([]ImportRow, error) {
+	switch format {
+	case ImportCSV:
+		return parseCSV(r)
+	case ImportJSONLines:
+		return parseJSONLines(r)
+	}
+	return nil, ErrUnsupportedImportFormat
+}
+
+func parseCSV(r io.Reader) ([]ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "username", "password", "role":
+		default:
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		if _, ok := columns[name]; ok {
+			return nil, fmt.Errorf("duplicate column %q", name)
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["username"]; !ok {
+		return nil, errors.New("missing column \"username\"")
+	}
+
+	var rows []ImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rows = append(rows, ImportRow{Line: parseErr.StartLine, invalid: parseErr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == MaxImportRows {
+			return nil, ErrTooManyImportRows
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := ImportRow{Line: line}
+		if len(record) != len(header) {
+			row.invalid = fmt.Sprintf("expected %d fields, got %d", len(header), len(record))
+		} else {
+			row.Username = record[columns["username"]]
+			if i, ok := columns["password"]; ok {
+				row.Password = record[i]
+			}
+			if i, ok := columns["role"]; ok {
+				row.Role = record[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+func parseJSONLines(r io.Reader) ([]ImportRow, error) {
+	var rows []ImportRow
+	reader := bufio.NewReader(r)
+	for number := 1; ; number++ {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if strings.TrimSpace(line) == "" {
+			if err == io.EOF {
+				return rows, nil
+			}
+			continue
+		}
+		if len(rows) == MaxImportRows {
+			return nil, ErrTooManyImportRows
+		}
+
+		row := ImportRow{Line: number}
+		strict := json.NewDecoder(strings.NewReader(line))
+		strict.DisallowUnknownFields()
+		if err := strict.Decode(&row); err != nil {
+			row = ImportRow{Line: number, invalid: "invalid JSON: " + err.Error()}
+		}
+		rows = append(rows, row)
+		if err == io.EOF {
+			return rows, nil
+		}
+	}
+}
+
+// Validate reports every row that cannot be imported. An import only runs
+// when there are none.
+func Validate(rows []ImportRow) []RowError {
+	var errs []RowError
+	seen := map[string]int{}
+	for _, row := range rows {
+		fail := func(format string, args ...interface{}) {
+			errs = append(errs, RowError{Line: row.Line, Username: row.Username, Error: fmt.Sprintf(format, args...)})
+		}
+		username := strings.TrimSpace(row.Username)
+		switch {
+		case row.invalid != "":
+			fail("%s", row.invalid)
+		case username == "":
+			fail("username is required")
+		case len(username) > 64:
+			fail("username is longer than 64 characters")
+		case len(row.Password) > 72:
+			// bcrypt ignores everything past 72 bytes.
+			fail("password is longer than 72 bytes")
+		case row.Role != "" && !validRole(row.Role):
+			fail("unknown role %q", row.Role)
+		case seen[username] != 0:
+			fail("username also on line %d", seen[username])
+		default:
+			seen[username] = row.Line
+		}
+	}
+	return errs
+}
+
+func validRole(role string) bool {
+	return strings.EqualFold(role, models.RoleAdmin.String()) || strings.EqualFold(role, models.RoleUser.String())
+}
+
+type IUserImportService interface {
+	Validate(rows []ImportRow) []RowError
+	Run(ctx context.Context, rows []ImportRow, dryRun bool, progress func(processed int)) (*ImportReport, error)
+}
+
+// UserImportService creates or updates users in bulk, matching existing users
+// by username.
+type UserImportService struct {
+	userDao   dao.IUserDao
+	inviter   Inviter
+	batchSize int
+	hashCost  int
+	// Users is invalidated for every existing user an import updates.
+	Users *UserCache
+}
+
+func NewUserImportService(userDao dao.IUserDao, inviter Inviter) *UserImportService {
+	return &UserImportService{userDao: userDao, inviter: inviter, batchSize: DefaultBatchSize, hashCost: bcrypt.DefaultCost}
+}
+
+func (s *UserImportService) Validate(rows []ImportRow) []RowError {
+	return Validate(rows)
+}
+
+// Run imports rows in batches, each in its own transaction, and calls
+// progress after every batch. If any row is invalid nothing is written and
+// the report lists the errors. A dry run only reports what would happen.
+//
+// Existing users keep their role when the role column is empty, and their
+// password when the password column is. Passwords are stored as bcrypt
+// hashes. New users without a password are stored without one, so they
+// cannot log in before they accept the invitation they are sent. A failing
+// batch stops the import; the batches before it stay committed and are
+// counted in the returned report.
+func (s *UserImportService) Run(ctx context.Context, rows []ImportRow, dryRun bool, progress func(processed int)) (*ImportReport, error) {
+	report := &ImportReport{DryRun: dryRun, Rows: len(rows), Errors: Validate(rows)}
+	if len(report.Errors) > 0 {
+		return report, nil
+	}
+
+	for start := 0; start < len(rows); start += s.batchSize {
+		end := start + s.batchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		var err error
+		if dryRun {
+			err = s.planBatch(ctx, rows[start:end], report)
+		} else {
+			err = s.importBatch(ctx, rows[start:end], report)
+		}
+		if err != nil {
+			return report, err
+		}
+		if progress != nil {
+			progress(end)
+		}
+	}
+	return report, nil
+}
+
+func (s *UserImportService) planBatch(ctx context.Context, rows []ImportRow, report *ImportReport) error {
+	usernames := make([]string, len(rows))
+	for i, row := range rows {
+		usernames[i] = strings.TrimSpace(row.Username)
+	}
+	taken, err := s.userDao.UsernamesTaken(ctx, usernames)
+	if err != nil {
+		return err
+	}
+	for i, row := range rows {
+		switch {
+		case taken[usernames[i]]:
+			report.Updated++
+		case row.Password == "":
+			report.Created++
+			report.Invited++
+		default:
+			report.Created++
+		}
+	}
+	return nil
+}
+
+func (s *UserImportService) importBatch(ctx context.Context, rows []ImportRow, report *ImportReport) error {
+	users := make([]*models.User, len(rows))
+	// An empty role column keeps the role of an existing user.
+	setRole := make([]bool, len(rows))
+	for i, row := range rows {
+		setRole[i] = row.Role != ""
+		user := &models.User{Username: strings.TrimSpace(row.Username), Role: models.RoleUser}
+		if strings.EqualFold(row.Role, models.RoleAdmin.String()) {
+			user.Role = models.RoleAdmin
+		}
+		hash, err := hashPassword(row.Password, s.hashCost)
+		if err != nil {
+			return err
+		}
+		user.Password = hash
+		users[i] = user
+	}
+
+	created, err := s.userDao.ImportBatch(ctx, users, setRole)
+	if err != nil {
+		return err
+	}
+	for i, user := range users {
+		if !created[i] {
+			s.Users.Invalidate(user.ID)
+			report.Updated++
+			continue
+		}
+		report.Created++
+		if rows[i].Password != "" {
+			continue
+		}
+		if err := s.inviter.NotifyInvited(*user); err != nil {
+			log.Printf("Failed to invite user %d: %v", user.ID, err)
+			continue
+		}
+		report.Invited++
+	}
+	return nil
+}
@@ -1,32 +1,65 @@
 package services
 
 import (
+	"context"
 	"golang/dao"
 	"golang/models"
+	"golang/pagination"
+	"golang/vault"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 type IUserService interface {
-	Create(user *models.User) error
-	GetByID(id uint64) (*models.User, error)
-	GetAll(page int, pageSize int, searchQuery string) ([]models.User, error)
-	Update(user *models.User) error
-	Delete(id uint64) error
+	Create(ctx context.Context, user *models.User) error
+	GetByID(ctx context.Context, id uint64) (*models.User, error)
+	GetAll(ctx context.Context, page pagination.Request, search dao.Search) (*pagination.Page[models.User], error)
+	Update(ctx context.Context, user *models.User) error
+	Patch(ctx context.Context, id uint64, version uint64, fields map[string]interface{}) (*models.User, error)
+	Delete(ctx context.Context, id uint64, version uint64) error
+	Restore(ctx context.Context, id uint64) (*models.User, error)
 }
 
 type UserService struct {
-	userDao dao.IUserDao
+	userDao   dao.IUserDao
+	cardVault vault.IVault
+	// Transactions, when set, stores a user together with the vault entries
+	// of its cards, so a failed write leaves no orphaned card numbers.
+	Transactions ITransactionManager
+	// Cache, when set, serves GetByID and FindByID.
+	Cache *UserCache
+
+	hashCost int
 }
 
-func NewUserService(userDao dao.IUserDao) *UserService {
-	return &UserService{userDao: userDao}
+func NewUserService(userDao dao.IUserDao, cardVault vault.IVault) *UserService {
+	return &UserService{userDao: userDao, cardVault: cardVault, hashCost: bcrypt.DefaultCost}
 }
 
-func (u *UserService) Create(user *models.User) error {
-	return u.userDao.Create(user)
+// Create stores a new user. Like every write of a password, it stores the
+// password's bcrypt hash.
+func (u *UserService) Create(ctx context.Context, user *models.User) error {
+	hash, err := hashPassword(user.Password, u.hashCost)
+	if err != nil {
+		return err
+	}
+	user.Password = hash
+	return u.saveWithCards(ctx, user, dao.IUserDao.Create)
 }
 
-func (u *UserService) GetByID(id uint64) (*models.User, error) {
-	return u.userDao.GetByID(id)
+func (u *UserService) GetByID(ctx context.Context, id uint64) (*models.User, error) {
+	return u.Cache.get(ctx, userKey(id), func(ctx context.Context) (*models.User, error) {
+		return u.userDao.GetByID(ctx, id)
+	})
+}
+
+// FindByID returns the user without notes and cards. It looks up the user of
+// every authenticated request.
+func (u *UserService) FindByID(ctx context.Context, id uint64) (*models.User, error) {
+	return u.Cache.get(ctx, accountKey(id), func(ctx context.Context) (*models.User, error) {
+		return u.userDao.FindByID(ctx, id)
+	})
 }
 
 // func (u *UserService) GetAll() ([]models.User, error) {
@@ -41,17 +74,128 @@ func (u *UserService) GetByID(id uint64) (*models.User, error) {
 // 	return users, err
 // }
 
-func (u *UserService) GetAll(page int, pageSize int, searchQuery string) ([]models.User, error) {
-	offset := (page - 1) * pageSize
+// GetAll returns one page of users. The page size is bounded here so no
+// caller can ask the database for an unlimited listing.
+func (u *UserService) GetAll(ctx context.Context, page pagination.Request, search dao.Search) (*pagination.Page[models.User], error) {
+	return u.userDao.GetAll(ctx, page.Normalize(), search)
+}
+
+func (u *UserService) Update(ctx context.Context, user *models.User) error {
+	hash, err := hashPassword(user.Password, u.hashCost)
+	if err != nil {
+		return err
+	}
+	user.Password = hash
+	return u.Cache.invalidated(user.ID, u.saveWithCards(ctx, user, dao.IUserDao.Update))
+}
+
+// Patch updates the given fields of a stored user and returns the result. A
+// non-zero version must match the stored one, even when nothing changes.
+func (u *UserService) Patch(ctx context.Context, id uint64, version uint64, fields map[string]interface{}) (*models.User, error) {
+	if password, ok := fields["password"].(string); ok {
+		hash, err := hashPassword(password, u.hashCost)
+		if err != nil {
+			return nil, err
+		}
+		hashed := make(map[string]interface{}, len(fields))
+		for column, value := range fields {
+			hashed[column] = value
+		}
+		hashed["password"] = hash
+		fields = hashed
+	}
+	if len(fields) > 0 || version != 0 {
+		if err := u.Cache.invalidated(id, u.userDao.UpdateFields(ctx, id, version, fields)); err != nil {
+			return nil, err
+		}
+	}
+	return u.userDao.GetByID(ctx, id)
+}
 
-	users, err := u.userDao.GetAll(offset, pageSize, searchQuery)
-	return users, err
+func (u *UserService) Delete(ctx context.Context, id uint64, version uint64) error {
+	return u.Cache.invalidated(id, u.userDao.Delete(ctx, id, version))
 }
 
-func (u *UserService) Update(user *models.User) error {
-	return u.userDao.Update(user)
+// Restore undeletes a user with everything deleted along with it and returns
+// the restored user.
+func (u *UserService) Restore(ctx context.Context, id uint64) (*models.User, error) {
+	if err := u.Cache.invalidated(id, u.userDao.Restore(ctx, id)); err != nil {
+		return nil, err
+	}
+	return u.userDao.GetByID(ctx, id)
 }
 
-func (u *UserService) Delete(id uint64) error {
-	return u.userDao.Delete(id)
+// saveWithCards tokenizes the user's new cards and saves the user with save.
+// Within a transaction every attempt starts from a copy of user, which is
+// only updated once the transaction has committed.
+func (u *UserService) saveWithCards(ctx context.Context, user *models.User, save func(dao.IUserDao, context.Context, *models.User) error) error {
+	if u.Transactions == nil {
+		if err := tokenizeCards(ctx, u.cardVault, user.CreditCards); err != nil {
+			return err
+		}
+		return save(u.userDao, ctx, user)
+	}
+
+	var saved models.User
+	err := u.Transactions.Run(ctx, func(ctx context.Context, tx Tx) error {
+		saved = *user
+		saved.Notes = append([]models.Note(nil), user.Notes...)
+		saved.CreditCards = append([]models.CreditCard(nil), user.CreditCards...)
+		if err := tokenizeCards(ctx, tx.Vault, saved.CreditCards); err != nil {
+			return err
+		}
+		return save(tx.Users, ctx, &saved)
+	})
+	if err != nil {
+		return err
+	}
+	*user = saved
+	return nil
+}
+
+// tokenizeCards moves newly submitted card numbers into the vault and makes
+// sure exactly one of the cards is the default.
+func tokenizeCards(ctx context.Context, cardVault vault.IVault, cards []models.CreditCard) error {
+	defaultIndex := -1
+	for i := range cards {
+		if err := tokenizeCard(ctx, cardVault, &cards[i]); err != nil {
+			return err
+		}
+		if cards[i].IsDefault && defaultIndex == -1 {
+			defaultIndex = i
+		}
+		cards[i].IsDefault = false
+	}
+	if defaultIndex == -1 && len(cards) > 0 {
+		defaultIndex = 0
+	}
+	if defaultIndex != -1 {
+		cards[defaultIndex].IsDefault = true
+	}
+	return nil
+}
+
+// tokenizeCard validates a newly submitted card number, moves it into the
+// vault and keeps only the token and display details on the card.
+func tokenizeCard(ctx context.Context, cardVault vault.IVault, card *models.CreditCard) error {
+	if card.Number == "" {
+		return nil
+	}
+
+	number := vault.NormalizeNumber(card.Number)
+	brand, err := vault.ValidateCard(number, card.ExpMonth, card.ExpYear, time.Now())
+	if err != nil {
+		return err
+	}
+
+	token, err := cardVault.Tokenize(ctx, number)
+	if err != nil {
+		return err
+	}
+
+	card.Token = token
+	card.Brand = string(brand)
+	card.Last4 = vault.Last4(number)
+	card.Number = ""
+	return nil
 }
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"golang/cache"
+	"golang/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type recordingInviter struct {
+	invited []string
+}
+
+func (r *recordingInviter) NotifyInvited(user models.User) error {
+	r.invited = append(r.invited, user.Username)
+	return nil
+}
+
+func newTestImportService(userDao *MockUserDao, inviter Inviter) *UserImportService {
+	service := NewUserImportService(userDao, inviter)
+	service.batchSize = 2
+	service.hashCost = bcrypt.MinCost
+	return service
+}
+
+func TestParseImport(t *testing.T) {
+	t.Run("CSV", func(t *testing.T) {
+		rows, err := ParseImport(ImportCSV, strings.NewReader("Username,role,password\nalice,RoleAdmin,secret\n\"bob\",,\ncarol\n"))
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Len(t, rows, 3)
+		assert.Equal(t, ImportRow{Line: 2, Username: "alice", Password: "secret", Role: "RoleAdmin"}, rows[0])
+		assert.Equal(t, ImportRow{Line: 3, Username: "bob"}, rows[1])
+		assert.Equal(t, []RowError{{Line: 4, Username: "", Error: "expected 3 fields, got 1"}}, Validate(rows))
+	})
+
+	t.Run("CSV Unknown Column", func(t *testing.T) {
+		_, err := ParseImport(ImportCSV, strings.NewReader("username,email\nalice,a@example.com\n"))
+		assert.EqualError(t, err, `unknown column "email"`)
+	})
+
+	t.Run("JSON Lines", func(t *testing.T) {
+		rows, err := ParseImport(ImportJSONLines, strings.NewReader(
+			"{\"username\":\"alice\",\"password\":\"secret\"}\n\n{\"username\":\"bob\",\"admin\":true}\n{\"username\":\"carol\"}"))
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Len(t, rows, 3)
+		assert.Equal(t, ImportRow{Line: 1, Username: "alice", Password: "secret"}, rows[0])
+		assert.Equal(t, ImportRow{Line: 4, Username: "carol"}, rows[2])
+
+		errs := Validate(rows)
+		assert.Len(t, errs, 1)
+		assert.Equal(t, 3, errs[0].Line)
+		assert.Contains(t, errs[0].Error, "invalid JSON")
+	})
+
+	t.Run("Unsupported Format", func(t *testing.T) {
+		_, err := ParseImport("xml", strings.NewReader(""))
+		assert.ErrorIs(t, err, ErrUnsupportedImportFormat)
+	})
+}
+
+func TestValidate(t *testing.T) {
+	rows := []ImportRow{
+		{Line: 2, Username: "alice"},
+		{Line: 3, Username: " "},
+		{Line: 4, Username: "bob", Role: "RoleRoot"},
+		{Line: 5, Username: "alice "},
+		{Line: 6, Username: "carol", Password: strings.Repeat("x", 73)},
+		{Line: 7, Username: "dave", Role: "roleadmin"},
+	}
+
+	assert.Equal(t, []RowError{
+		{Line: 3, Username: " ", Error: "username is required"},
+		{Line: 4, Username: "bob", Error: `unknown role "RoleRoot"`},
+		{Line: 5, Username: "alice ", Error: "username also on line 2"},
+		{Line: 6, Username: "carol", Error: "password is longer than 72 bytes"},
+	}, Validate(rows))
+}
+
+func TestUserImportService_Run(t *testing.T) {
+	rows := []ImportRow{
+		{Line: 1, Username: "alice", Password: "secret", Role: "RoleAdmin"},
+		{Line: 2, Username: "bob"},
+		{Line: 3, Username: "carol"},
+	}
+
+	t.Run("Dry Run", func(t *testing.T) {
+		mockDao := new(MockUserDao)
+		mockDao.On("UsernamesTaken", []string{"alice", "bob"}).Return(map[string]bool{"alice": true}, nil).Once()
+		mockDao.On("UsernamesTaken", []string{"carol"}).Return(map[string]bool{}, nil).Once()
+
+		var progress []int
+		report, err := newTestImportService(mockDao, &recordingInviter{}).Run(context.Background(), rows, true, func(processed int) {
+			progress = append(progress, processed)
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, &ImportReport{DryRun: true, Rows: 3, Created: 2, Updated: 1, Invited: 2}, report)
+		assert.Equal(t, []int{2, 3}, progress)
+		mockDao.AssertExpectations(t)
+		mockDao.AssertNotCalled(t, "ImportBatch", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Import", func(t *testing.T) {
+		mockDao := new(MockUserDao)
+		inviter := &recordingInviter{}
+		mockDao.On("ImportBatch", mock.MatchedBy(func(users []*models.User) bool {
+			return len(users) == 2 && users[0].Username == "alice"
+		}), []bool{true, false}).Return([]bool{false, true}, nil).Once().Run(func(args mock.Arguments) {
+			users := args.Get(0).([]*models.User)
+			assert.Equal(t, models.RoleAdmin, users[0].Role)
+			assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(users[0].Password), []byte("secret")))
+			assert.Equal(t, models.RoleUser, users[1].Role)
+			assert.Empty(t, users[1].Password)
+		})
+		mockDao.On("ImportBatch", mock.Anything, mock.Anything).Return([]bool{true}, nil).Once()
+
+		report, err := newTestImportService(mockDao, inviter).Run(context.Background(), rows, false, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &ImportReport{Rows: 3, Created: 2, Updated: 1, Invited: 2}, report)
+		assert.Equal(t, []string{"bob", "carol"}, inviter.invited)
+		mockDao.AssertExpectations(t)
+	})
+
+	t.Run("Updated Users Leave The Cache", func(t *testing.T) {
+		mockDao := new(MockUserDao)
+		mockDao.On("ImportBatch", mock.Anything, mock.Anything).Return([]bool{false}, nil).Once().Run(func(args mock.Arguments) {
+			args.Get(0).([]*models.User)[0].ID = 7
+		})
+		service := newTestImportService(mockDao, &recordingInviter{})
+		service.Users = NewUserCache(cache.NewLRU(10), time.Minute)
+		loads := 0
+		load := func(context.Context) (*models.User, error) {
+			loads++
+			return &models.User{ID: 7, Username: "alice"}, nil
+		}
+
+		_, err := service.Users.get(context.Background(), userKey(7), load)
+		assert.NoError(t, err)
+		_, err = service.Run(context.Background(), rows[:1], false, nil)
+		assert.NoError(t, err)
+		_, err = service.Users.get(context.Background(), userKey(7), load)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, loads)
+	})
+
+	t.Run("Invalid Rows Write Nothing", func(t *testing.T) {
+		mockDao := new(MockUserDao)
+		report, err := newTestImportService(mockDao, &recordingInviter{}).Run(context.Background(), append(rows, ImportRow{Line: 4, Username: "bob"}), false, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []RowError{{Line: 4, Username: "bob", Error: "username also on line 2"}}, report.Errors)
+		mockDao.AssertNotCalled(t, "ImportBatch", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Failing Batch Stops The Import", func(t *testing.T) {
+		mockDao := new(MockUserDao)
+		mockDao.On("ImportBatch", mock.Anything, mock.Anything).Return([]bool{true, true}, nil).Once()
+		mockDao.On("ImportBatch", mock.Anything, mock.Anything).Return(nil, errors.New("database is down")).Once()
+
+		report, err := newTestImportService(mockDao, &recordingInviter{}).Run(context.Background(), rows, false, nil)
+
+		assert.EqualError(t, err, "database is down")
+		assert.Equal(t, 2, report.Created)
+	})
+}
+
+func TestImportJobs(t *testing.T) {
+	mockDao := new(MockUserDao)
+	mockDao.On("ImportBatch", mock.Anything, mock.Anything).Return([]bool{true, true}, nil).Once()
+	mockDao.On("ImportBatch", mock.Anything, mock.Anything).Return([]bool{false}, nil).Once()
+	jobs := NewImportJobs(newTestImportService(mockDao, &recordingInviter{}))
+
+	job, err := jobs.Start([]ImportRow{
+		{Line: 1, Username: "alice", Password: "a"},
+		{Line: 2, Username: "bob", Password: "b"},
+		{Line: 3, Username: "carol", Password: "c"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, JobRunning, job.Status)
+	assert.Equal(t, 3, job.Total)
+
+	jobs.Wait()
+	finished, ok := jobs.Get(job.ID)
+	assert.True(t, ok)
+	assert.Equal(t, JobSucceeded, finished.Status)
+	assert.Equal(t, 3, finished.Processed)
+	assert.Equal(t, &ImportReport{Rows: 3, Created: 2, Updated: 1}, finished.Report)
+	assert.NotNil(t, finished.FinishedAt)
+
+	t.Run("Drain", func(t *testing.T) {
+		release := make(chan time.Time)
+		mockDao.On("ImportBatch", mock.Anything, mock.Anything).Return([]bool{true}, nil).WaitUntil(release).Once()
+		_, err := jobs.Start([]ImportRow{{Line: 1, Username: "dave", Password: "d"}})
+		assert.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err = jobs.Drain(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.EqualError(t, err, "1 import jobs still running: context deadline exceeded")
+
+		close(release)
+		assert.NoError(t, jobs.Drain(context.Background()))
+	})
+
+	t.Run("Forgets Old Jobs", func(t *testing.T) {
+		jobs.now = func() time.Time { return time.Now().Add(25 * time.Hour) }
+		jobs.mu.Lock()
+		jobs.prune()
+		jobs.mu.Unlock()
+
+		_, ok := jobs.Get(job.ID)
+		assert.False(t, ok)
+	})
+}
@@ -2,105 +2,225 @@
 package services
 
 import (
+	"context"
+	"golang/cache"
+	"golang/dao"
+	"golang/dao/daotest"
 	"golang/models"
+	"golang/pagination"
+	"golang/vault"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
 )
 
-// Mocking the IUserDao interface
+// Mocking the IUserDao interface. Create, Update, UpdateFields, Delete and
+// Restore come from the generic repository mock.
 type MockUserDao struct {
-	mock.Mock
+	daotest.MockRepository[models.User]
 }
 
-func (m *MockUserDao) Create(user *models.User) error {
-	args := m.Called(user)
-	return args.Error(0)
+func (m *MockUserDao) GetByID(ctx context.Context, id uint64) (*models.User, error) {
+	args := m.Called(id)
+	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (m *MockUserDao) GetByID(id uint64) (*models.User, error) {
+func (m *MockUserDao) FindByID(ctx context.Context, id uint64) (*models.User, error) {
 	args := m.Called(id)
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (m *MockUserDao) GetAll(page int, pageSize int, searchQuery string) ([]models.User, error) {
-	args := m.Called(page, pageSize, searchQuery)
-	return args.Get(0).([]models.User), args.Error(1)
+func (m *MockUserDao) GetAll(ctx context.Context, page pagination.Request, search dao.Search) (*pagination.Page[models.User], error) {
+	args := m.Called(page, search)
+	users, _ := args.Get(0).(*pagination.Page[models.User])
+	return users, args.Error(1)
 }
 
-func (m *MockUserDao) Update(user *models.User) error {
-	args := m.Called(user)
-	return args.Error(0)
-}
-
-func (m *MockUserDao) Delete(id uint64) error {
-	args := m.Called(id)
+func (m *MockUserDao) Stream(ctx context.Context, page pagination.Request, search dao.Search, fn func([]models.User) error) error {
+	args := m.Called(page, search, fn)
 	return args.Error(0)
 }
 
 // FindByEmail implements dao.IUserDao.
-func (m *MockUserDao) FindByEmail(userName string) (*models.User, error) {
+func (m *MockUserDao) FindByEmail(ctx context.Context, userName string) (*models.User, error) {
 	args := m.Called(userName)
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
+// UsernamesTaken implements dao.IUserDao.
+func (m *MockUserDao) UsernamesTaken(ctx context.Context, usernames []string) (map[string]bool, error) {
+	args := m.Called(usernames)
+	taken, _ := args.Get(0).(map[string]bool)
+	return taken, args.Error(1)
+}
+
+// ImportBatch implements dao.IUserDao.
+func (m *MockUserDao) ImportBatch(ctx context.Context, users []*models.User, setRole []bool) ([]bool, error) {
+	args := m.Called(users, setRole)
+	created, _ := args.Get(0).([]bool)
+	return created, args.Error(1)
+}
+
 func TestUserService_Create(t *testing.T) {
 	mockDao := new(MockUserDao)
-	userService := NewUserService(mockDao)
+	userService := NewUserService(mockDao, nil)
 
 	user := &models.User{Username: "john", Password: "password"}
 
 	mockDao.On("Create", user).Return(nil)
 
-	err := userService.Create(user)
+	err := userService.Create(context.Background(), user)
 
 	assert.NoError(t, err)
 	mockDao.AssertExpectations(t)
 }
 
+func TestUserService_Passwords(t *testing.T) {
+	mockDao := new(MockUserDao)
+	userService := NewUserService(mockDao, nil)
+	userService.hashCost = bcrypt.MinCost
+	ctx := context.Background()
+
+	t.Run("Create Stores A Hash", func(t *testing.T) {
+		user := &models.User{Username: "john", Password: "secret"}
+		mockDao.On("Create", user).Return(nil).Once()
+
+		assert.NoError(t, userService.Create(ctx, user))
+		assert.True(t, CheckPassword(user.Password, "secret"))
+		assert.False(t, CheckPassword(user.Password, "guess"))
+	})
+
+	t.Run("Patch Stores A Hash", func(t *testing.T) {
+		fields := map[string]interface{}{"password": "changed"}
+		mockDao.On("UpdateFields", uint64(1), uint64(0), mock.MatchedBy(func(fields map[string]interface{}) bool {
+			return CheckPassword(fields["password"].(string), "changed")
+		})).Return(nil).Once()
+		mockDao.On("GetByID", uint64(1)).Return(&models.User{ID: 1}, nil).Once()
+
+		_, err := userService.Patch(ctx, 1, 0, fields)
+		assert.NoError(t, err)
+		assert.Equal(t, "changed", fields["password"])
+	})
+
+	t.Run("Too Long", func(t *testing.T) {
+		err := userService.Create(ctx, &models.User{Username: "john", Password: strings.Repeat("x", 73)})
+		assert.ErrorIs(t, err, ErrPasswordTooLong)
+	})
+
+	t.Run("No Password Never Matches", func(t *testing.T) {
+		hash, err := hashPassword("", userService.hashCost)
+		assert.NoError(t, err)
+		assert.False(t, CheckPassword(hash, ""))
+	})
+
+	t.Run("Stored Plaintext", func(t *testing.T) {
+		hash, changed, err := HashStoredPassword("legacy")
+		assert.NoError(t, err)
+		assert.True(t, changed)
+		assert.True(t, CheckPassword(hash, "legacy"))
+
+		_, changed, err = HashStoredPassword(hash)
+		assert.NoError(t, err)
+		assert.False(t, changed)
+	})
+	mockDao.AssertExpectations(t)
+}
+
 func TestUserService_GetByID(t *testing.T) {
 	mockDao := new(MockUserDao)
-	userService := NewUserService(mockDao)
+	userService := NewUserService(mockDao, nil)
 
 	user := &models.User{ID: 1, Username: "john", Password: "password"}
 
 	mockDao.On("GetByID", uint64(1)).Return(user, nil)
 
-	fetchedUser, err := userService.GetByID(1)
+	fetchedUser, err := userService.GetByID(context.Background(), 1)
 
 	assert.NoError(t, err)
 	assert.Equal(t, user, fetchedUser)
 	mockDao.AssertExpectations(t)
 }
 
+func TestUserService_Cache(t *testing.T) {
+	mockDao := new(MockUserDao)
+	userService := NewUserService(mockDao, nil)
+	userService.Cache = NewUserCache(cache.NewLRU(10), time.Minute)
+	notes := NewNoteService(dao.NewNoteDao(setupTransactionDB(t)))
+	notes.Users = userService.Cache
+	ctx := context.Background()
+
+	user := &models.User{ID: 1, Username: "john", Notes: []models.Note{{ID: 1, Name: "first"}}}
+	account := &models.User{ID: 1, Username: "john"}
+	mockDao.On("GetByID", uint64(1)).Return(user, nil).Twice()
+	mockDao.On("FindByID", uint64(1)).Return(account, nil).Twice()
+	mockDao.On("Update", mock.Anything).Return(nil).Once()
+
+	for i := 0; i < 2; i++ {
+		fetched, err := userService.GetByID(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, user, fetched)
+		found, err := userService.FindByID(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, account, found)
+	}
+
+	// Writes to the user drop both cached copies.
+	assert.NoError(t, userService.Update(ctx, &models.User{ID: 1, Username: "john"}))
+	_, err := userService.GetByID(ctx, 1)
+	assert.NoError(t, err)
+	_, err = userService.FindByID(ctx, 1)
+	assert.NoError(t, err)
+	mockDao.AssertExpectations(t)
+	assert.Equal(t, cache.Stats{Hits: 2, Misses: 4, Loads: 4}, userService.Cache.Stats())
+
+	// So do writes to the user's notes.
+	assert.NoError(t, notes.Create(ctx, 1, &models.Note{Name: "second"}))
+	mockDao.On("GetByID", uint64(1)).Return(user, nil).Once()
+	_, err = userService.GetByID(ctx, 1)
+	assert.NoError(t, err)
+	mockDao.AssertExpectations(t)
+}
+
 func TestUserService_GetAll(t *testing.T) {
 	mockDao := new(MockUserDao)
-	userService := NewUserService(mockDao)
+	userService := NewUserService(mockDao, nil)
 
-	users := []models.User{
+	users := &pagination.Page[models.User]{Items: []models.User{
 		{ID: 1, Username: "john", Password: "password"},
 		{ID: 2, Username: "jane", Password: "password"},
-	}
-
-	mockDao.On("GetAll", 0, 10, "").Return(users, nil)
+	}}
 
-	fetchedUsers, err := userService.GetAll(0, 10, "")
+	mockDao.On("GetAll", pagination.Request{Limit: 10}, dao.Search{}).Return(users, nil).Once()
+	mockDao.On("GetAll", pagination.Request{Limit: pagination.DefaultLimit}, dao.Search{}).Return(users, nil).Once()
+	mockDao.On("GetAll", pagination.Request{Limit: pagination.MaxLimit}, dao.Search{}).Return(users, nil).Once()
 
+	fetchedUsers, err := userService.GetAll(context.Background(), pagination.Request{Limit: 10}, dao.Search{})
 	assert.NoError(t, err)
 	assert.Equal(t, users, fetchedUsers)
+
+	// Missing, negative and oversized values are bounded before they reach
+	// the database.
+	_, err = userService.GetAll(context.Background(), pagination.Request{Offset: -10}, dao.Search{})
+	assert.NoError(t, err)
+	_, err = userService.GetAll(context.Background(), pagination.Request{Limit: 1 << 20}, dao.Search{})
+	assert.NoError(t, err)
+
 	mockDao.AssertExpectations(t)
 }
 
 func TestUserService_Update(t *testing.T) {
 	mockDao := new(MockUserDao)
-	userService := NewUserService(mockDao)
+	userService := NewUserService(mockDao, nil)
 
 	user := &models.User{ID: 1, Username: "john", Password: "password"}
 
 	mockDao.On("Update", user).Return(nil)
 
-	err := userService.Update(user)
+	err := userService.Update(context.Background(), user)
 
 	assert.NoError(t, err)
 	mockDao.AssertExpectations(t)
@@ -108,12 +228,98 @@ func TestUserService_Update(t *testing.T) {
 
 func TestUserService_Delete(t *testing.T) {
 	mockDao := new(MockUserDao)
-	userService := NewUserService(mockDao)
+	userService := NewUserService(mockDao, nil)
 
-	mockDao.On("Delete", uint64(1)).Return(nil)
+	mockDao.On("Delete", uint64(1), uint64(0)).Return(nil)
 
-	err := userService.Delete(1)
+	err := userService.Delete(context.Background(), 1, 0)
 
 	assert.NoError(t, err)
 	mockDao.AssertExpectations(t)
 }
+
+func TestUserService_Restore(t *testing.T) {
+	mockDao := new(MockUserDao)
+	userService := NewUserService(mockDao, nil)
+
+	restored := &models.User{ID: 1, Username: "alice"}
+	mockDao.On("Restore", uint64(1)).Return(nil).Once()
+	mockDao.On("GetByID", uint64(1)).Return(restored, nil).Once()
+	mockDao.On("Restore", uint64(2)).Return(dao.ErrUsernameTaken).Once()
+
+	user, err := userService.Restore(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, restored, user)
+
+	_, err = userService.Restore(context.Background(), 2)
+	assert.ErrorIs(t, err, dao.ErrUsernameTaken)
+	mockDao.AssertExpectations(t)
+}
+
+// Mocking the vault.IVault interface
+type MockVault struct {
+	mock.Mock
+}
+
+func (m *MockVault) Tokenize(ctx context.Context, number string) (string, error) {
+	args := m.Called(number)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockVault) Detokenize(ctx context.Context, token string, access vault.Access) (string, error) {
+	args := m.Called(token, access)
+	return args.String(0), args.Error(1)
+}
+
+func TestUserService_CreateWithCard(t *testing.T) {
+	t.Run("Card Is Tokenized", func(t *testing.T) {
+		mockDao := new(MockUserDao)
+		mockVault := new(MockVault)
+		userService := NewUserService(mockDao, mockVault)
+
+		user := &models.User{
+			Username: "john",
+			Password: "password",
+			CreditCards: []models.CreditCard{
+				{Number: "4111 1111 1111 1111", ExpMonth: 12, ExpYear: time.Now().Year() + 1},
+				{Number: "5500000000000004", ExpMonth: 12, ExpYear: time.Now().Year() + 1},
+			},
+		}
+
+		mockVault.On("Tokenize", "4111111111111111").Return("tok_john", nil)
+		mockVault.On("Tokenize", "5500000000000004").Return("tok_john_2", nil)
+		mockDao.On("Create", user).Return(nil)
+
+		err := userService.Create(context.Background(), user)
+
+		assert.NoError(t, err)
+		card := user.CreditCards[0]
+		assert.Empty(t, card.Number)
+		assert.Equal(t, "tok_john", card.Token)
+		assert.Equal(t, "visa", card.Brand)
+		assert.Equal(t, "1111", card.Last4)
+		assert.True(t, card.IsDefault)
+		assert.False(t, user.CreditCards[1].IsDefault)
+		mockVault.AssertExpectations(t)
+		mockDao.AssertExpectations(t)
+	})
+
+	t.Run("Invalid Card", func(t *testing.T) {
+		mockDao := new(MockUserDao)
+		mockVault := new(MockVault)
+		userService := NewUserService(mockDao, mockVault)
+
+		user := &models.User{
+			Username: "john",
+			CreditCards: []models.CreditCard{
+				{Number: "4111111111111112", ExpMonth: 12, ExpYear: time.Now().Year() + 1},
+			},
+		}
+
+		err := userService.Create(context.Background(), user)
+
+		assert.ErrorIs(t, err, vault.ErrInvalidCard)
+		mockVault.AssertNotCalled(t, "Tokenize", mock.Anything)
+		mockDao.AssertNotCalled(t, "Create", mock.Anything)
+	})
+}
@@ -0,0 +1,122 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type Brand string
+
+const (
+	Visa       Brand = "visa"
+	Mastercard Brand = "mastercard"
+	Amex       Brand = "amex"
+	Discover   Brand = "discover"
+)
+
+var ErrInvalidCard = errors.New("invalid card")
+
+// cardLengths lists the valid number lengths per brand.
+var cardLengths = map[Brand][]int{
+	Visa:       {13, 16, 19},
+	Mastercard: {16},
+	Amex:       {15},
+	Discover:   {16, 19},
+}
+
+// NormalizeNumber strips the spaces and dashes people type into card numbers.
+func NormalizeNumber(number string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(number)
+}
+
+// DetectBrand returns the brand for a card number's prefix, or "" when the
+// prefix is not one we accept.
+func DetectBrand(number string) Brand {
+	prefix := func(n int) int {
+		if len(number) < n {
+			return -1
+		}
+		value := 0
+		for _, r := range number[:n] {
+			value = value*10 + int(r-'0')
+		}
+		return value
+	}
+
+	switch {
+	case strings.HasPrefix(number, "4"):
+		return Visa
+	case prefix(2) >= 51 && prefix(2) <= 55, prefix(4) >= 2221 && prefix(4) <= 2720:
+		return Mastercard
+	case prefix(2) == 34, prefix(2) == 37:
+		return Amex
+	case prefix(4) == 6011, prefix(2) == 65, prefix(3) >= 644 && prefix(3) <= 649:
+		return Discover
+	}
+	return ""
+}
+
+// Luhn reports whether number passes the Luhn checksum.
+func Luhn(number string) bool {
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		digit := int(number[i] - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+func Last4(number string) string {
+	if len(number) < 4 {
+		return number
+	}
+	return number[len(number)-4:]
+}
+
+// ValidateCard checks the number's digits, brand, length and checksum and
+// that the card has not expired at now. It returns the detected brand.
+func ValidateCard(number string, expMonth int, expYear int, now time.Time) (Brand, error) {
+	if number == "" || strings.Trim(number, "0123456789") != "" {
+		return "", fmt.Errorf("%w: number must contain only digits", ErrInvalidCard)
+	}
+
+	brand := DetectBrand(number)
+	if brand == "" {
+		return "", fmt.Errorf("%w: unsupported card brand", ErrInvalidCard)
+	}
+
+	validLength := false
+	for _, length := range cardLengths[brand] {
+		if len(number) == length {
+			validLength = true
+			break
+		}
+	}
+	if !validLength {
+		return "", fmt.Errorf("%w: invalid length for %s", ErrInvalidCard, brand)
+	}
+
+	if !Luhn(number) {
+		return "", fmt.Errorf("%w: checksum failed", ErrInvalidCard)
+	}
+
+	if expMonth < 1 || expMonth > 12 {
+		return "", fmt.Errorf("%w: invalid expiry month", ErrInvalidCard)
+	}
+	// A card is valid until the end of its expiry month.
+	if now.Year() > expYear || (now.Year() == expYear && int(now.Month()) > expMonth) {
+		return "", fmt.Errorf("%w: card has expired", ErrInvalidCard)
+	}
+
+	return brand, nil
+}
@@ -0,0 +1,99 @@
+package vault
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"golang/dao"
+	"golang/models"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTokenNotFound  = errors.New("token not found")
+	ErrReasonRequired = errors.New("a reason is required to detokenize a card")
+)
+
+// Access describes who is asking to see a card number and why. Every
+// detokenize call is recorded with it.
+type Access struct {
+	ActorID  uint64
+	Reason   string
+	ClientIP string
+}
+
+// IVault stores card numbers and hands out opaque tokens for them. Nothing
+// outside the vault should hold a full card number.
+type IVault interface {
+	Tokenize(ctx context.Context, number string) (string, error)
+	Detokenize(ctx context.Context, token string, access Access) (string, error)
+}
+
+type Vault struct {
+	vaultDao dao.IVaultDao
+}
+
+func NewVault(vaultDao dao.IVaultDao) *Vault {
+	return &Vault{vaultDao: vaultDao}
+}
+
+func (v *Vault) Tokenize(ctx context.Context, number string) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	entry := &models.VaultEntry{Token: token, Number: NormalizeNumber(number)}
+	if err := v.vaultDao.CreateEntry(ctx, entry); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Detokenize returns the card number behind token. The attempt is audited
+// whether or not it succeeds, and fails if it cannot be audited.
+func (v *Vault) Detokenize(ctx context.Context, token string, access Access) (string, error) {
+	var number string
+	var err error
+	if access.Reason == "" {
+		err = ErrReasonRequired
+	} else {
+		number, err = v.lookup(ctx, token)
+	}
+
+	audit := &models.VaultAuditEntry{
+		Token:    token,
+		ActorID:  access.ActorID,
+		Reason:   access.Reason,
+		ClientIP: access.ClientIP,
+		Success:  err == nil,
+	}
+	if auditErr := v.vaultDao.CreateAuditEntry(ctx, audit); auditErr != nil {
+		log.Println("Failed to write vault audit entry:", auditErr)
+		return "", auditErr
+	}
+
+	return number, err
+}
+
+func (v *Vault) lookup(ctx context.Context, token string) (string, error) {
+	entry, err := v.vaultDao.FindEntry(ctx, token)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", ErrTokenNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return entry.Number, nil
+}
+
+func newToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "tok_" + hex.EncodeToString(buf), nil
+}
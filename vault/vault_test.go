@@ -0,0 +1,132 @@
+package vault
+
+import (
+	"context"
+	"golang/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+// Mocking the IVaultDao interface
+type MockVaultDao struct {
+	mock.Mock
+}
+
+func (m *MockVaultDao) CreateEntry(ctx context.Context, entry *models.VaultEntry) error {
+	args := m.Called(entry)
+	return args.Error(0)
+}
+
+func (m *MockVaultDao) FindEntry(ctx context.Context, token string) (*models.VaultEntry, error) {
+	args := m.Called(token)
+	return args.Get(0).(*models.VaultEntry), args.Error(1)
+}
+
+func (m *MockVaultDao) CreateAuditEntry(ctx context.Context, entry *models.VaultAuditEntry) error {
+	args := m.Called(entry)
+	return args.Error(0)
+}
+
+func TestValidateCard(t *testing.T) {
+	now := time.Date(2026, time.June, 15, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		number   string
+		expMonth int
+		expYear  int
+		brand    Brand
+		valid    bool
+	}{
+		{"Visa", "4111111111111111", 12, 2027, Visa, true},
+		{"Mastercard", "5500000000000004", 1, 2030, Mastercard, true},
+		{"Mastercard 2-Series", "2221000000000009", 1, 2030, Mastercard, true},
+		{"Amex", "378282246310005", 1, 2030, Amex, true},
+		{"Discover", "6011111111111117", 1, 2030, Discover, true},
+		{"Expires This Month", "4111111111111111", 6, 2026, Visa, true},
+		{"Expired", "4111111111111111", 5, 2026, "", false},
+		{"Bad Checksum", "4111111111111112", 12, 2027, "", false},
+		{"Bad Length For Amex", "3782822463100050", 12, 2027, "", false},
+		{"Unknown Brand", "9111111111111111", 12, 2027, "", false},
+		{"Non Digits", "4111-1111-1111-1111", 12, 2027, "", false},
+		{"Invalid Month", "4111111111111111", 13, 2027, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			brand, err := ValidateCard(tt.number, tt.expMonth, tt.expYear, now)
+			if tt.valid {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.brand, brand)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidCard)
+			}
+		})
+	}
+}
+
+func TestVault_Tokenize(t *testing.T) {
+	mockDao := new(MockVaultDao)
+	cardVault := NewVault(mockDao)
+
+	mockDao.On("CreateEntry", mock.MatchedBy(func(entry *models.VaultEntry) bool {
+		return entry.Number == "4111111111111111"
+	})).Return(nil)
+
+	token, err := cardVault.Tokenize(context.Background(), "4111 1111 1111 1111")
+
+	assert.NoError(t, err)
+	assert.Regexp(t, "^tok_[0-9a-f]{32}$", token)
+	mockDao.AssertExpectations(t)
+}
+
+func TestVault_Detokenize(t *testing.T) {
+	access := Access{ActorID: 1, Reason: "chargeback #42", ClientIP: "10.0.0.1"}
+
+	t.Run("Success Is Audited", func(t *testing.T) {
+		mockDao := new(MockVaultDao)
+		cardVault := NewVault(mockDao)
+
+		mockDao.On("FindEntry", "tok_a").Return(&models.VaultEntry{Token: "tok_a", Number: "4111111111111111"}, nil)
+		mockDao.On("CreateAuditEntry", mock.MatchedBy(func(entry *models.VaultAuditEntry) bool {
+			return entry.Token == "tok_a" && entry.ActorID == 1 && entry.Reason == "chargeback #42" && entry.Success
+		})).Return(nil)
+
+		number, err := cardVault.Detokenize(context.Background(), "tok_a", access)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "4111111111111111", number)
+		mockDao.AssertExpectations(t)
+	})
+
+	t.Run("Unknown Token Is Audited", func(t *testing.T) {
+		mockDao := new(MockVaultDao)
+		cardVault := NewVault(mockDao)
+
+		mockDao.On("FindEntry", "tok_missing").Return(&models.VaultEntry{}, gorm.ErrRecordNotFound)
+		mockDao.On("CreateAuditEntry", mock.MatchedBy(func(entry *models.VaultAuditEntry) bool {
+			return entry.Token == "tok_missing" && !entry.Success
+		})).Return(nil)
+
+		_, err := cardVault.Detokenize(context.Background(), "tok_missing", access)
+
+		assert.ErrorIs(t, err, ErrTokenNotFound)
+		mockDao.AssertExpectations(t)
+	})
+
+	t.Run("Reason Required", func(t *testing.T) {
+		mockDao := new(MockVaultDao)
+		cardVault := NewVault(mockDao)
+
+		mockDao.On("CreateAuditEntry", mock.Anything).Return(nil)
+
+		_, err := cardVault.Detokenize(context.Background(), "tok_a", Access{ActorID: 1})
+
+		assert.ErrorIs(t, err, ErrReasonRequired)
+		mockDao.AssertNotCalled(t, "FindEntry", "tok_a")
+	})
+}